@@ -7,22 +7,25 @@ import (
 
 	"github.com/labbsr0x/bindman-dns-webhook/src/hook"
 	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
-	manager := DummyManager{DNSRecords: make(map[string]types.DNSRecord), TTL: 3600}
+	manager := DummyManager{DNSRecords: make(map[string]types.DNSRecord)}
 
-	// get ttl from env
-	if ttl, err := strconv.Atoi(strings.Trim(os.Getenv("BINDMAN_DNS_TTL"), " ")); err == nil {
-		manager.TTL = ttl
+	// get the default ttl from env
+	ttl := 3600
+	if envTTL, err := strconv.Atoi(strings.Trim(os.Getenv("BINDMAN_DNS_TTL"), " ")); err == nil {
+		ttl = envTTL
+	}
+	if err := hook.Initialize(&manager, "1", hook.WithTTL(ttl, 0, 0)); err != nil {
+		logrus.Fatalf("Error initializing the DNS Manager Webhook: %v", err)
 	}
-	hook.Initialize(&manager, "1")
 }
 
 // DummyManager holds the information for managing a dummy dns server
 type DummyManager struct {
 	DNSRecords map[string]types.DNSRecord
-	TTL        int
 }
 
 // GetDNSRecords retrieves all the dns records being managed
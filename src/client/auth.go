@@ -0,0 +1,74 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// WithBearerToken authenticates every request with the 'Authorization: Bearer <token>' header
+func WithBearerToken(token string) Option {
+	return func(s *settings) {
+		s.wrap(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+token)
+				return next.RoundTrip(r)
+			})
+		})
+	}
+}
+
+// WithHMACSigning signs every request with the secret identified by keyID, as expected by the hook HMACAuthenticator
+func WithHMACSigning(keyID, secret string) Option {
+	return func(s *settings) {
+		s.wrap(func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				r = r.Clone(r.Context())
+				var body []byte
+				if r.Body != nil {
+					var err error
+					if body, err = ioutil.ReadAll(r.Body); err != nil {
+						return nil, err
+					}
+					r.Body.Close()
+					r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				}
+				nonce, err := newNonce()
+				if err != nil {
+					return nil, err
+				}
+				timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+				r.Header.Set(types.HeaderKeyID, keyID)
+				r.Header.Set(types.HeaderTimestamp, timestamp)
+				r.Header.Set(types.HeaderNonce, nonce)
+				r.Header.Set(types.HeaderSignature, types.Sign([]byte(secret), r.Method, r.URL.RequestURI(), timestamp, nonce, body))
+				return next.RoundTrip(r)
+			})
+		})
+	}
+}
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements the http.RoundTripper interface
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newNonce returns a random hex encoded value
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestWithBearerToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, nil, WithBearerToken("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveRecord("test", "A"); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer token" {
+		t.Errorf("expected the bearer token on the request, got '%s'", authorization)
+	}
+	if http.DefaultClient.Transport != nil {
+		t.Error("the default client must not be modified")
+	}
+}
+
+func TestWithHMACSigning(t *testing.T) {
+	var valid bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		valid = r.Header.Get(types.HeaderKeyID) == "team-a" &&
+			types.VerifySignature(r.Header.Get(types.HeaderSignature), []byte("secret"), r.Method, r.URL.RequestURI(),
+				r.Header.Get(types.HeaderTimestamp), r.Header.Get(types.HeaderNonce), body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, &http.Client{Timeout: time.Second}, WithHMACSigning("team-a", "secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateRecord(&types.DNSRecord{Name: "test.com", Type: "A", Value: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Error("expected a valid signature on the request")
+	}
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// ApplyBatch applies the operations all or nothing and returns the outcome of each one. When the batch is not applied
+// the result is returned along with the error, telling which operations failed
+func (l *DNSWebhookClient) ApplyBatch(operations ...types.BatchOperation) (types.BatchResult, error) {
+	return l.ApplyBatchContext(context.Background(), operations...)
+}
+
+// ApplyBatchContext applies the operations all or nothing, see ApplyBatch. ctx bounds the request
+func (l *DNSWebhookClient) ApplyBatchContext(ctx context.Context, operations ...types.BatchOperation) (result types.BatchResult, err error) {
+	batch := types.Batch{Operations: make([]types.BatchOperation, len(operations))}
+	for i, operation := range operations {
+		operation.Record = l.names.Record(operation.Record)
+		operation.Record.SyncValue()
+		batch.Operations[i] = operation
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return
+	}
+	resp, data, err := l.request(ctx, http.MethodPost, recordsPath+":batch", body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(data, &result) == nil && result.Error != nil {
+			return result, result.Error
+		}
+		return types.BatchResult{}, parseResponseBodyToError(resp, data)
+	}
+	err = json.Unmarshal(data, &result)
+	return
+}
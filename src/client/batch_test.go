@@ -0,0 +1,69 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_ApplyBatch(t *testing.T) {
+	applied := types.BatchResult{Applied: true, Results: []types.BatchOperationResult{
+		{Op: types.BatchAdd, Name: "a.test.com", Type: "A", Status: http.StatusCreated},
+	}}
+	failed := types.BatchResult{Results: []types.BatchOperationResult{
+		{Op: types.BatchAdd, Name: "a.test.com", Type: "A", Status: http.StatusConflict,
+			Error: &types.Error{Message: "Record already exists", Code: http.StatusConflict, ErrorCode: types.CodeConflict}},
+	}, Error: &types.Error{Message: "The batch was not applied", Code: http.StatusConflict, ErrorCode: types.CodeConflict}}
+
+	tests := []struct {
+		name       string
+		code       int
+		response   interface{}
+		want       types.BatchResult
+		wantStatus int
+	}{
+		{"applied", http.StatusOK, applied, applied, 0},
+		{"not applied", http.StatusConflict, failed, failed, http.StatusConflict},
+		{"plain error", http.StatusUnauthorized, types.Error{Message: "Unauthorized", Code: http.StatusUnauthorized}, types.BatchResult{}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path string
+			var batch types.Batch
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path = r.Method, r.URL.Path
+				_ = json.NewDecoder(r.Body).Decode(&batch)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.code)
+				_ = json.NewEncoder(w).Encode(tt.response)
+			}))
+			defer server.Close()
+			c, err := New(server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.ApplyBatch(types.BatchOperation{Op: types.BatchAdd, Record: types.DNSRecord{Name: "A.test.com.", Type: "a", Value: "10.0.0.1"}})
+			if method != http.MethodPost || path != "/records:batch" || len(batch.Operations) != 1 ||
+				batch.Operations[0].Record.Name != "a.test.com" || batch.Operations[0].Record.Type != "A" {
+				t.Errorf("unexpected request %s %s %+v", method, path, batch)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want the result %+v, got %+v", tt.want, got)
+			}
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("unexpected error %v", err)
+				}
+				return
+			}
+			if e := types.AsError(err); e == nil || e.Code != tt.wantStatus {
+				t.Errorf("want an error of status %d, got %v", tt.wantStatus, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const (
+	// minChangePollInterval is how long WaitForChange first waits between polls of a change
+	minChangePollInterval = 100 * time.Millisecond
+
+	// maxChangePollInterval is the longest WaitForChange waits between polls of a change, doubling the interval up to it
+	maxChangePollInterval = 2 * time.Second
+)
+
+// AddDNSRecordAsync adds a DNS record asking the manager to answer before the change is applied. The returned change
+// is pending until the manager applies it, see WaitForChange. Managers not running changes asynchronously apply it
+// right away, returning an applied change without ID
+func (l *DNSWebhookClient) AddDNSRecordAsync(record *types.DNSRecord) (types.Change, error) {
+	return l.AddDNSRecordAsyncContext(context.Background(), record)
+}
+
+// AddDNSRecordAsyncContext adds a DNS record asynchronously, see AddDNSRecordAsync. ctx bounds the request
+func (l *DNSWebhookClient) AddDNSRecordAsyncContext(ctx context.Context, record *types.DNSRecord) (types.Change, error) {
+	return l.addOrUpdateRecordAsync(ctx, record, http.MethodPost, types.BatchAdd)
+}
+
+// UpdateRecordAsync updates a DNS record asking the manager to answer before the change is applied, see
+// AddDNSRecordAsync
+func (l *DNSWebhookClient) UpdateRecordAsync(record *types.DNSRecord) (types.Change, error) {
+	return l.UpdateRecordAsyncContext(context.Background(), record)
+}
+
+// UpdateRecordAsyncContext updates a DNS record asynchronously, see UpdateRecordAsync. ctx bounds the request
+func (l *DNSWebhookClient) UpdateRecordAsyncContext(ctx context.Context, record *types.DNSRecord) (types.Change, error) {
+	return l.addOrUpdateRecordAsync(ctx, record, http.MethodPut, types.BatchUpdate)
+}
+
+// RemoveRecordAsync removes a DNS record asking the manager to answer before the change is applied, see
+// AddDNSRecordAsync
+func (l *DNSWebhookClient) RemoveRecordAsync(name, recordType string) (types.Change, error) {
+	return l.RemoveRecordAsyncContext(context.Background(), name, recordType)
+}
+
+// RemoveRecordAsyncContext removes a DNS record asynchronously, see RemoveRecordAsync. ctx bounds the request
+func (l *DNSWebhookClient) RemoveRecordAsyncContext(ctx context.Context, name, recordType string) (types.Change, error) {
+	applied := types.Change{Op: types.BatchDelete, Name: l.names.Name(name), Type: l.names.Type(recordType), Status: types.ChangeApplied}
+	return l.submitChange(ctx, http.MethodDelete, l.recordPath(name, recordType), nil, applied)
+}
+
+// GetChange gets the status of a change applied asynchronously
+func (l *DNSWebhookClient) GetChange(id string) (types.Change, error) {
+	return l.GetChangeContext(context.Background(), id)
+}
+
+// GetChangeContext gets the status of a change applied asynchronously. ctx bounds the request
+func (l *DNSWebhookClient) GetChangeContext(ctx context.Context, id string) (result types.Change, err error) {
+	resp, data, err := l.request(ctx, http.MethodGet, "/changes/"+id, nil)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = parseResponseBodyToError(resp, data)
+		return
+	}
+	err = json.Unmarshal(data, &result)
+	return
+}
+
+// WaitForChange polls the change until it is applied or fails, for up to timeout. The error of a failed change is
+// returned along with it. When the timeout expires the last status of the change is returned with the error of the
+// context
+func (l *DNSWebhookClient) WaitForChange(change types.Change, timeout time.Duration) (types.Change, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.WaitForChangeContext(ctx, change)
+}
+
+// WaitForChangeContext polls the change until it is applied or fails, see WaitForChange. ctx bounds how long it waits
+func (l *DNSWebhookClient) WaitForChangeContext(ctx context.Context, change types.Change) (types.Change, error) {
+	interval := minChangePollInterval
+	for !change.Done() {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return change, ctx.Err()
+		case <-timer.C:
+		}
+		current, err := l.GetChangeContext(ctx, change.ID)
+		if err != nil {
+			return change, err
+		}
+		change = current
+		if interval *= 2; interval > maxChangePollInterval {
+			interval = maxChangePollInterval
+		}
+	}
+	if change.Status == types.ChangeFailed && change.Error != nil {
+		return change, change.Error
+	}
+	return change, nil
+}
+
+// addOrUpdateRecordAsync validates the record and sends it asynchronously with the given method
+func (l *DNSWebhookClient) addOrUpdateRecordAsync(ctx context.Context, record *types.DNSRecord, method, op string) (types.Change, error) {
+	synced, body, err := l.encodeRecord(record)
+	if err != nil {
+		return types.Change{}, err
+	}
+	applied := types.Change{Op: op, Name: synced.Name, Type: synced.Type, Status: types.ChangeApplied}
+	return l.submitChange(ctx, method, recordsPath, body, applied)
+}
+
+// submitChange sends a change asking to be answered asynchronously, returning the accepted change, or applied when
+// the manager applied it right away
+func (l *DNSWebhookClient) submitChange(ctx context.Context, method, path string, body []byte, applied types.Change) (result types.Change, err error) {
+	resp, data, err := l.requestWithHeader(ctx, method, path, http.Header{"Prefer": {"respond-async"}}, body)
+	if err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		err = json.Unmarshal(data, &result)
+	case http.StatusNoContent:
+		result = applied
+	default:
+		err = parseResponseBodyToError(resp, data)
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// changeServer accepts changes asynchronously, completing them with the given outcome after some polls
+type changeServer struct {
+	mu      sync.Mutex
+	polls   int
+	pending int
+	outcome *types.Error
+	prefer  string
+}
+
+func (s *changeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change := types.Change{ID: "c1", Op: types.BatchDelete, Name: "test.com", Type: "A", Status: types.ChangePending}
+	if r.Method != http.MethodGet {
+		s.prefer = r.Header.Get("Prefer")
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(change)
+		return
+	}
+	if r.URL.Path != "/changes/c1" {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(types.NotFoundError("Change not found", nil))
+		return
+	}
+	s.polls++
+	if s.pending >= 0 && s.polls > s.pending {
+		change.Status, change.Error = types.ChangeApplied, s.outcome
+		if s.outcome != nil {
+			change.Status = types.ChangeFailed
+		}
+	}
+	_ = json.NewEncoder(w).Encode(change)
+}
+
+func TestDNSWebhookClient_AsyncChanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		pending      int
+		outcome      *types.Error
+		timeout      time.Duration
+		wantStatus   string
+		wantNotFound bool
+		wantTimeout  bool
+	}{
+		{"applied", 2, nil, 5 * time.Second, types.ChangeApplied, false, false},
+		{"failed", 0, types.NotFoundError("Record not found", nil).WithErrorCode(types.CodeRecordNotFound), 5 * time.Second, types.ChangeFailed, true, false},
+		{"timed out", -1, nil, 150 * time.Millisecond, types.ChangePending, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &changeServer{pending: tt.pending, outcome: tt.outcome}
+			server := httptest.NewServer(s)
+			defer server.Close()
+			c, err := New(server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			change, err := c.RemoveRecordAsync("test.com", "A")
+			if err != nil || change.ID != "c1" || change.Status != types.ChangePending || s.prefer != "respond-async" {
+				t.Fatalf("unexpected accepted change %+v (%v), Prefer: %s", change, err, s.prefer)
+			}
+			change, err = c.WaitForChange(change, tt.timeout)
+			if change.Status != tt.wantStatus {
+				t.Errorf("want status %s, got %+v", tt.wantStatus, change)
+			}
+			if IsNotFound(err) != tt.wantNotFound || (err == context.DeadlineExceeded) != tt.wantTimeout {
+				t.Errorf("unexpected error %v", err)
+			}
+			if tt.wantStatus == types.ChangeApplied && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestDNSWebhookClient_AsyncChanges_AppliedRightAway(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			t.Errorf("unexpected poll of %s", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := c.AddDNSRecordAsync(&types.DNSRecord{Name: "Test.com.", Type: "a", Value: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := types.Change{Op: types.BatchAdd, Name: "test.com", Type: "A", Status: types.ChangeApplied}
+	if change != want {
+		t.Errorf("want %+v, got %+v", want, change)
+	}
+	if change, err = c.WaitForChange(change, time.Second); err != nil || change != want {
+		t.Errorf("expected the applied change to be returned right away, got %+v (%v)", change, err)
+	}
+
+	if _, err := c.UpdateRecordAsync(&types.DNSRecord{Name: "test.com", Type: "A", Value: "::1"}); err == nil || !strings.Contains(err.Error(), "invalid DNS Record") {
+		t.Errorf("expected the invalid record to be rejected, got %v", err)
+	}
+}
@@ -1,12 +1,15 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/labbsr0x/bindman-dns-webhook/src/types"
 	"github.com/labbsr0x/goh/gohclient"
+	"mime"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -15,13 +18,36 @@ const recordsPath = "/records"
 // DNSWebhookClient defines the basic structure of a DNS Listener
 type DNSWebhookClient struct {
 	ClientAPI gohclient.API
+
+	// names normalizes the names and types before they are sent
+	names types.NameNormalizer
+
+	// retries defines how failed requests are retried
+	retries retryPolicy
+
+	// basePath prefixes the path of every request, see WithBasePath
+	basePath string
 }
 
 // New builds the client to communicate with the dns manager
-func New(managerAddress string, httpClient *http.Client) (*DNSWebhookClient, error) {
+func New(managerAddress string, httpClient *http.Client, options ...Option) (*DNSWebhookClient, error) {
 	if strings.TrimSpace(managerAddress) == "" {
 		return nil, errors.New("managerAddress parameter must be a non-empty string")
 	}
+	s := &settings{}
+	for _, option := range options {
+		option(s)
+	}
+	if errs := s.names.Check(); errs != nil {
+		return nil, fmt.Errorf("invalid default zone: %s", strings.Join(errs, ", "))
+	}
+	if err := s.retries.check(); err != nil {
+		return nil, err
+	}
+	httpClient, err := s.httpClient(httpClient, managerAddress)
+	if err != nil {
+		return nil, err
+	}
 	client, err := gohclient.New(httpClient, managerAddress)
 	if err != nil {
 		return nil, err
@@ -31,83 +57,200 @@ func New(managerAddress string, httpClient *http.Client) (*DNSWebhookClient, err
 	client.UserAgent = "bindman-dns-webhook-client"
 
 	return &DNSWebhookClient{
-		ClientAPI: client,
+		ClientAPI: &httpAPI{client},
+		names:     s.names,
+		retries:   s.retries,
+		basePath:  s.basePath,
 	}, nil
 }
 
 // GetRecords communicates with the dns manager and gets the DNS Records
-func (l *DNSWebhookClient) GetRecords() (result []types.DNSRecord, err error) {
-	resp, data, err := l.ClientAPI.Get(recordsPath)
+func (l *DNSWebhookClient) GetRecords() ([]types.DNSRecord, error) {
+	return l.GetRecordsContext(context.Background())
+}
+
+// GetRecordsContext communicates with the dns manager and gets the DNS Records. ctx bounds the request
+func (l *DNSWebhookClient) GetRecordsContext(ctx context.Context) (result []types.DNSRecord, err error) {
+	resp, data, err := l.request(ctx, http.MethodGet, recordsPath, nil)
 	if err != nil {
 		return
 	}
 	if resp.StatusCode == http.StatusOK {
 		err = json.Unmarshal(data, &result)
+		for i := range result {
+			result[i].SyncValue()
+			result[i].SyncUnicodeName()
+		}
 	} else {
-		err = parseResponseBodyToError(data)
+		err = parseResponseBodyToError(resp, data)
 	}
 	return
 }
 
 // GetRecord communicates with the dns manager and gets a DNS Record
-func (l *DNSWebhookClient) GetRecord(name, recordType string) (result types.DNSRecord, err error) {
-	resp, data, err := l.ClientAPI.Get(fmt.Sprintf(recordsPath+"/%s/%s", name, recordType))
+func (l *DNSWebhookClient) GetRecord(name, recordType string) (types.DNSRecord, error) {
+	return l.GetRecordContext(context.Background(), name, recordType)
+}
+
+// GetRecordContext communicates with the dns manager and gets a DNS Record. ctx bounds the request
+func (l *DNSWebhookClient) GetRecordContext(ctx context.Context, name, recordType string) (result types.DNSRecord, err error) {
+	result, _, err = l.getRecord(ctx, name, recordType)
+	return
+}
+
+// getRecord gets a specific DNS Record and its ETag
+func (l *DNSWebhookClient) getRecord(ctx context.Context, name, recordType string) (result types.DNSRecord, etag string, err error) {
+	resp, data, err := l.request(ctx, http.MethodGet, l.recordPath(name, recordType), nil)
 	if err != nil {
 		return
 	}
 	if resp.StatusCode == http.StatusOK {
 		err = json.Unmarshal(data, &result)
+		result.SyncValue()
+		result.SyncUnicodeName()
+		etag = resp.Header.Get("ETag")
 	} else {
-		err = parseResponseBodyToError(data)
+		err = parseResponseBodyToError(resp, data)
 	}
 	return
 }
 
-// AddRecord adds a DNS record
+// AddRecord adds a DNS record with the default TTL of the manager
 func (l *DNSWebhookClient) AddRecord(name string, recordType string, value string) error {
-	return l.addOrUpdateRecord(&types.DNSRecord{Value: value, Name: name, Type: recordType}, l.ClientAPI.Post)
+	return l.AddRecordWithTTL(name, recordType, value, 0)
+}
+
+// AddRecordContext adds a DNS record with the default TTL of the manager. ctx bounds the request
+func (l *DNSWebhookClient) AddRecordContext(ctx context.Context, name string, recordType string, value string) error {
+	return l.AddDNSRecordContext(ctx, &types.DNSRecord{Value: value, Name: name, Type: recordType})
+}
+
+// AddRecordWithTTL adds a DNS record with the given TTL, in seconds
+func (l *DNSWebhookClient) AddRecordWithTTL(name string, recordType string, value string, ttl int) error {
+	return l.AddDNSRecord(&types.DNSRecord{Value: value, Name: name, Type: recordType, TTL: ttl})
+}
+
+// AddDNSRecord adds a DNS record. Records with several fields, like MX, SRV and CAA ones, can be defined either by
+// their structured field or by Value in the presentation format
+func (l *DNSWebhookClient) AddDNSRecord(record *types.DNSRecord) error {
+	return l.AddDNSRecordContext(context.Background(), record)
+}
+
+// AddDNSRecordContext adds a DNS record, see AddDNSRecord. ctx bounds the request
+func (l *DNSWebhookClient) AddDNSRecordContext(ctx context.Context, record *types.DNSRecord) error {
+	return l.addOrUpdateRecord(ctx, record, http.MethodPost, recordsPath, nil)
 }
 
 // UpdateRecord is a function that calls the defined webhook to update a specific dns record
 func (l *DNSWebhookClient) UpdateRecord(record *types.DNSRecord) error {
-	return l.addOrUpdateRecord(record, l.ClientAPI.Put)
+	return l.UpdateRecordContext(context.Background(), record)
 }
 
-// addOrUpdateRecord .
-func (l *DNSWebhookClient) addOrUpdateRecord(record *types.DNSRecord, action func(url string, body []byte) (*http.Response, []byte, error)) error {
-	if errs := record.Check(); errs != nil {
-		return fmt.Errorf("invalid DNS Record: %v", strings.Join(errs, ", "))
-	}
-	mr, err := json.Marshal(record)
+// UpdateRecordContext updates a specific dns record. ctx bounds the request
+func (l *DNSWebhookClient) UpdateRecordContext(ctx context.Context, record *types.DNSRecord) error {
+	return l.addOrUpdateRecord(ctx, record, http.MethodPut, recordsPath, nil)
+}
+
+// UpsertDNSRecord adds the DNS record when missing or updates it when existing
+func (l *DNSWebhookClient) UpsertDNSRecord(record *types.DNSRecord) error {
+	return l.UpsertDNSRecordContext(context.Background(), record)
+}
+
+// UpsertDNSRecordContext adds the DNS record when missing or updates it when existing. ctx bounds the request
+func (l *DNSWebhookClient) UpsertDNSRecordContext(ctx context.Context, record *types.DNSRecord) error {
+	return l.addOrUpdateRecord(ctx, record, http.MethodPut, recordsPath+"?upsert=true", nil)
+}
+
+// addOrUpdateRecord validates the record and sends it to path with the given method and headers
+func (l *DNSWebhookClient) addOrUpdateRecord(ctx context.Context, record *types.DNSRecord, method, path string, header http.Header) error {
+	_, mr, err := l.encodeRecord(record)
 	if err != nil {
 		return err
 	}
-	resp, data, err := action(recordsPath, mr)
+	resp, data, err := l.requestWithHeader(ctx, method, path, header, mr)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode != http.StatusNoContent {
-		return parseResponseBodyToError(data)
+		return parseResponseBodyToError(resp, data)
 	}
 	return nil
 }
 
+// encodeRecord normalizes and validates the record, returning it along with its JSON form
+func (l *DNSWebhookClient) encodeRecord(record *types.DNSRecord) (types.DNSRecord, []byte, error) {
+	synced := l.names.Record(*record)
+	synced.SyncValue()
+	if errs := synced.Check(); errs != nil {
+		return synced, nil, fmt.Errorf("invalid DNS Record: %v", strings.Join(errs, ", "))
+	}
+	data, err := json.Marshal(synced)
+	return synced, data, err
+}
+
 // RemoveRecord is a function that calls the defined webhook to remove a specific dns record
 func (l *DNSWebhookClient) RemoveRecord(name, recordType string) error {
-	resp, data, err := l.ClientAPI.Delete(fmt.Sprintf(recordsPath+"/%s/%s", name, recordType))
+	return l.RemoveRecordContext(context.Background(), name, recordType)
+}
+
+// RemoveRecordContext removes a specific dns record. ctx bounds the request
+func (l *DNSWebhookClient) RemoveRecordContext(ctx context.Context, name, recordType string) error {
+	return l.removeRecord(ctx, name, recordType, nil)
+}
+
+// removeRecord removes a specific dns record, sending the given headers
+func (l *DNSWebhookClient) removeRecord(ctx context.Context, name, recordType string, header http.Header) error {
+	resp, data, err := l.requestWithHeader(ctx, http.MethodDelete, l.recordPath(name, recordType), header, nil)
 	if err != nil {
 		return err
 	}
 	if resp.StatusCode != http.StatusNoContent {
-		return parseResponseBodyToError(data)
+		return parseResponseBodyToError(resp, data)
 	}
 	return err
 }
 
-func parseResponseBodyToError(data []byte) error {
-	var err types.Error
-	if errUnmarshal := json.Unmarshal(data, &err); errUnmarshal != nil {
-		return errUnmarshal
+// recordPath returns the path of the record identified by name and type, escaping both
+func (l *DNSWebhookClient) recordPath(name, recordType string) string {
+	return recordsPath + "/" + url.PathEscape(l.names.Name(name)) + "/" + url.PathEscape(l.names.Type(recordType))
+}
+
+// IsNotFound tells if err reports a missing record or RRSet, telling it apart from failed requests
+func IsNotFound(err error) bool {
+	return errors.Is(err, types.ErrNotFound)
+}
+
+// IsConflict tells if err reports adding a record that already exists. The current record is on the Record field of
+// the types.Error
+func IsConflict(err error) bool {
+	return errors.Is(err, types.ErrConflict)
+}
+
+// maxErrorBodyDetail is the greatest number of bytes of an unexpected response body kept as detail of the error
+const maxErrorBodyDetail = 512
+
+// parseResponseBodyToError decodes the error of a response, sent either as a types.Error or as RFC 7807 problem
+// details. Bodies that are empty or do not hold an error, like the pages of proxies, give an error with the status
+// code of the response
+func parseResponseBodyToError(resp *http.Response, data []byte) error {
+	if mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && mediaType == types.ProblemContentType {
+		var problem types.Problem
+		if err := json.Unmarshal(data, &problem); err == nil && problem.Status != 0 {
+			return problem.ToError()
+		}
+	}
+	var e types.Error
+	if err := json.Unmarshal(data, &e); err == nil && e.Code != 0 {
+		return &e
+	}
+
+	e = types.Error{Message: fmt.Sprintf("unexpected response from the DNS manager: %d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		Code: resp.StatusCode, ErrorCode: types.CodeUnexpectedResponse}
+	if body := strings.TrimSpace(string(data)); body != "" {
+		if len(body) > maxErrorBodyDetail {
+			body = body[:maxErrorBodyDetail] + "..."
+		}
+		e.Details = []string{body}
 	}
-	return &err
+	return &e
 }
@@ -1,11 +1,15 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/labbsr0x/bindman-dns-webhook/src/types"
 	"github.com/labbsr0x/goh/gohclient"
@@ -254,12 +258,12 @@ func TestDNSWebhookClient_UpdateRecord(t *testing.T) {
 		{
 			name:   "request success and 204 status code",
 			fields: fields{&MockHTTPHelperSuccess{Status: http.StatusNoContent}},
-			args:   args{name: "test", recordType: "A", value: "A"},
+			args:   args{name: "test", recordType: "A", value: "127.0.0.1"},
 		},
 		{
 			name:    "error check record - do not execute request",
 			fields:  fields{&MockHTTPHelperSuccess{}},
-			args:    args{recordType: "A", value: "A"},
+			args:    args{recordType: "A", value: "127.0.0.1"},
 			wantErr: true,
 		},
 		{
@@ -309,24 +313,24 @@ func TestDNSWebhookClient_AddRecord(t *testing.T) {
 		{
 			name:   "request success and 204 status code",
 			fields: fields{&MockHTTPHelperSuccess{Status: http.StatusNoContent}},
-			args:   args{name: "test", recordType: "A", value: "A"},
+			args:   args{name: "test", recordType: "A", value: "127.0.0.1"},
 		},
 		{
 			name:    "error check record - do not execute request",
 			fields:  fields{&MockHTTPHelperSuccess{}},
-			args:    args{recordType: "A", value: "A"},
+			args:    args{recordType: "A", value: "127.0.0.1"},
 			wantErr: true,
 		},
 		{
 			name:    "request success and 400 status code",
 			fields:  fields{&MockHTTPHelperSuccess{Status: http.StatusBadRequest, Data: expectedErrorData}},
-			args:    args{name: "test", recordType: "A", value: "A"},
+			args:    args{name: "test", recordType: "A", value: "127.0.0.1"},
 			wantErr: true,
 		},
 		{
 			name:    "request error",
 			fields:  fields{&MockHTTPHelperError{err: &url.Error{Op: "request error add record"}}},
-			args:    args{name: "test", recordType: "A", value: "A"},
+			args:    args{name: "test", recordType: "A", value: "127.0.0.1"},
 			wantErr: true,
 		},
 	}
@@ -342,16 +346,56 @@ func TestDNSWebhookClient_AddRecord(t *testing.T) {
 	}
 }
 
+func TestDNSWebhookClient_AddRecordWithTTL(t *testing.T) {
+	mock := &MockHTTPHelperSuccess{Status: http.StatusNoContent}
+	l := &DNSWebhookClient{ClientAPI: mock}
+	if err := l.AddRecordWithTTL("test.com", "A", "127.0.0.1", 300); err != nil {
+		t.Fatal(err)
+	}
+	var sent types.DNSRecord
+	if err := json.Unmarshal(mock.Sent, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.TTL != 300 {
+		t.Errorf("expected the TTL to be sent, got %v", sent)
+	}
+	if err := l.AddRecordWithTTL("test.com", "A", "127.0.0.1", -1); err == nil {
+		t.Error("expected an error on an invalid TTL")
+	}
+}
+
+func TestDNSWebhookClient_AddDNSRecord(t *testing.T) {
+	mock := &MockHTTPHelperSuccess{Status: http.StatusNoContent}
+	l := &DNSWebhookClient{ClientAPI: mock}
+	record := &types.DNSRecord{Name: "test.com", Type: "MX", MX: &types.MXData{Preference: 10, Host: "mail.test.com"}}
+	if err := l.AddDNSRecord(record); err != nil {
+		t.Fatal(err)
+	}
+	var sent types.DNSRecord
+	if err := json.Unmarshal(mock.Sent, &sent); err != nil {
+		t.Fatal(err)
+	}
+	if sent.Value != "10 mail.test.com" || !reflect.DeepEqual(sent.MX, record.MX) {
+		t.Errorf("expected both the value and the structured field to be sent, got %+v", sent)
+	}
+	if record.Value != "" {
+		t.Error("the given record must not be modified")
+	}
+}
+
 type MockHTTPHelperSuccess struct {
 	Data   []byte
 	Status int
+	Sent   []byte
 }
 
 func (m *MockHTTPHelperSuccess) Put(url string, data []byte) (*http.Response, []byte, error) {
+	m.Sent = data
 	return &http.Response{StatusCode: m.Status}, m.Data, nil
 }
 
 func (m *MockHTTPHelperSuccess) Post(url string, data []byte) (*http.Response, []byte, error) {
+	m.Sent = data
 	return &http.Response{StatusCode: m.Status}, m.Data, nil
 }
 
@@ -381,3 +425,150 @@ func (m MockHTTPHelperError) Get(url string) (*http.Response, []byte, error) {
 func (m MockHTTPHelperError) Delete(url string) (*http.Response, []byte, error) {
 	return nil, nil, m.err
 }
+
+func TestDNSWebhookClient_Context(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetRecordsContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the request to be bounded by the context, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	mock := &DNSWebhookClient{ClientAPI: &MockHTTPHelperSuccess{Status: http.StatusNoContent}}
+	if err := mock.RemoveRecordContext(canceled, "test.com", "A"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a canceled context to be honored by any ClientAPI, got %v", err)
+	}
+}
+
+func Test_parseResponseBodyToError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        *types.Error
+	}{
+		{"error", http.StatusNotFound, "application/json",
+			`{"message":"Record not found","code":404,"errorCode":"RECORD_NOT_FOUND"}`,
+			&types.Error{Message: "Record not found", Code: http.StatusNotFound, ErrorCode: types.CodeRecordNotFound},
+		},
+		{"problem details", http.StatusBadRequest, "application/problem+json; charset=utf-8",
+			`{"type":"urn:bindman:error:INVALID_VALUE","title":"Bad Request","status":400,"detail":"Invalid record","errorCode":"INVALID_VALUE",` +
+				`"details":["out of range"],"fields":[{"field":"ttl","code":"OUT_OF_RANGE","message":"out of range"}]}`,
+			&types.Error{Message: "Invalid record", Code: http.StatusBadRequest, ErrorCode: types.CodeInvalidValue, Details: []string{"out of range"},
+				Fields: []types.FieldError{{Field: "ttl", Code: types.CodeOutOfRange, Message: "out of range"}}},
+		},
+		{"empty body", http.StatusBadGateway, "", "",
+			&types.Error{Message: "unexpected response from the DNS manager: 502 Bad Gateway", Code: http.StatusBadGateway, ErrorCode: types.CodeUnexpectedResponse},
+		},
+		{"html body", http.StatusBadGateway, "text/html", "<html><body>Bad Gateway</body></html>\n",
+			&types.Error{Message: "unexpected response from the DNS manager: 502 Bad Gateway", Code: http.StatusBadGateway, ErrorCode: types.CodeUnexpectedResponse,
+				Details: []string{"<html><body>Bad Gateway</body></html>"}},
+		},
+		{"json body without error", http.StatusServiceUnavailable, "application/json", `{"status":"down"}`,
+			&types.Error{Message: "unexpected response from the DNS manager: 503 Service Unavailable", Code: http.StatusServiceUnavailable, ErrorCode: types.CodeUnexpectedResponse,
+				Details: []string{`{"status":"down"}`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{"Content-Type": []string{tt.contentType}}}
+			err := parseResponseBodyToError(resp, []byte(tt.body))
+			if !reflect.DeepEqual(err, tt.want) {
+				t.Errorf("parseResponseBodyToError() = %#v, want %#v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"not found response", parseResponseBodyToError(&http.Response{StatusCode: http.StatusNotFound}, []byte(`{"message":"Record not found","code":404,"errorCode":"RECORD_NOT_FOUND"}`)), true},
+		{"not found response of an older hook", parseResponseBodyToError(&http.Response{StatusCode: http.StatusNotFound}, []byte(`{"message":"Record not found","code":404}`)), true},
+		{"not found response without body", parseResponseBodyToError(&http.Response{StatusCode: http.StatusNotFound}, nil), false},
+		{"not found response of a proxy", parseResponseBodyToError(&http.Response{StatusCode: http.StatusNotFound}, []byte("404 page not found")), false},
+		{"change not found response", parseResponseBodyToError(&http.Response{StatusCode: http.StatusNotFound}, []byte(`{"message":"Change not found","code":404,"errorCode":"NOT_FOUND"}`)), false},
+		{"other response", parseResponseBodyToError(&http.Response{StatusCode: http.StatusBadGateway}, nil), false},
+		{"request error", errors.New("connection refused"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsNotFound(tt.err); got != tt.want {
+				t.Errorf("IsNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsConflict(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"conflict response", parseResponseBodyToError(&http.Response{StatusCode: http.StatusConflict}, []byte(`{"message":"Record already exists","code":409,"errorCode":"CONFLICT"}`)), true},
+		{"request in progress response", parseResponseBodyToError(&http.Response{StatusCode: http.StatusConflict}, []byte(`{"message":"A request with the same idempotency key is in progress","code":409,"errorCode":"REQUEST_IN_PROGRESS"}`)), false},
+		{"conflict response of a proxy", parseResponseBodyToError(&http.Response{StatusCode: http.StatusConflict}, nil), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConflict(tt.err); got != tt.want {
+				t.Errorf("IsConflict() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSWebhookClient_UpsertDNSRecord(t *testing.T) {
+	existing := types.DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1"}
+	var method, query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, query = r.Method, r.URL.RawQuery
+		if r.Method == http.MethodPost {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(types.ConflictError("Record already exists", &existing, nil))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.UpsertDNSRecord(&existing); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || query != "upsert=true" {
+		t.Errorf("expected a PUT request asking for an upsert, got %s ?%s", method, query)
+	}
+
+	err = c.AddDNSRecord(&existing)
+	var e *types.Error
+	if !IsConflict(err) || !errors.As(err, &e) || e.Record == nil || *e.Record != existing {
+		t.Errorf("expected a conflict with the existing record, got %v", err)
+	}
+}
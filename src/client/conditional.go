@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// maxSwapAttempts is the number of times CompareAndSwapRecord tries to update a record changed concurrently
+const maxSwapAttempts = 5
+
+// GetRecordWithETag gets a DNS Record and the ETag identifying its content, to be given to UpdateRecordIfMatch and
+// RemoveRecordIfMatch
+func (l *DNSWebhookClient) GetRecordWithETag(name, recordType string) (types.DNSRecord, string, error) {
+	return l.GetRecordWithETagContext(context.Background(), name, recordType)
+}
+
+// GetRecordWithETagContext gets a DNS Record and its ETag, see GetRecordWithETag. ctx bounds the request
+func (l *DNSWebhookClient) GetRecordWithETagContext(ctx context.Context, name, recordType string) (types.DNSRecord, string, error) {
+	return l.getRecord(ctx, name, recordType)
+}
+
+// UpdateRecordIfMatch updates a DNS record only if its content still has the given ETag. Otherwise the update fails
+// with an error satisfying IsPreconditionFailed
+func (l *DNSWebhookClient) UpdateRecordIfMatch(record *types.DNSRecord, etag string) error {
+	return l.UpdateRecordIfMatchContext(context.Background(), record, etag)
+}
+
+// UpdateRecordIfMatchContext updates a DNS record only if its content still has the given ETag. ctx bounds the request
+func (l *DNSWebhookClient) UpdateRecordIfMatchContext(ctx context.Context, record *types.DNSRecord, etag string) error {
+	return l.addOrUpdateRecord(ctx, record, http.MethodPut, recordsPath, http.Header{"If-Match": {etag}})
+}
+
+// CreateRecordIfNotExists adds a DNS record only if there is no record with its name and type. Otherwise it fails with
+// an error satisfying IsPreconditionFailed
+func (l *DNSWebhookClient) CreateRecordIfNotExists(record *types.DNSRecord) error {
+	return l.CreateRecordIfNotExistsContext(context.Background(), record)
+}
+
+// CreateRecordIfNotExistsContext adds a DNS record only if there is no record with its name and type. ctx bounds the
+// request
+func (l *DNSWebhookClient) CreateRecordIfNotExistsContext(ctx context.Context, record *types.DNSRecord) error {
+	return l.addOrUpdateRecord(ctx, record, http.MethodPut, recordsPath, http.Header{"If-None-Match": {"*"}})
+}
+
+// RemoveRecordIfMatch removes a DNS record only if its content still has the given ETag. Otherwise the removal fails
+// with an error satisfying IsPreconditionFailed
+func (l *DNSWebhookClient) RemoveRecordIfMatch(name, recordType, etag string) error {
+	return l.RemoveRecordIfMatchContext(context.Background(), name, recordType, etag)
+}
+
+// RemoveRecordIfMatchContext removes a DNS record only if its content still has the given ETag. ctx bounds the request
+func (l *DNSWebhookClient) RemoveRecordIfMatchContext(ctx context.Context, name, recordType, etag string) error {
+	return l.removeRecord(ctx, name, recordType, http.Header{"If-Match": {etag}})
+}
+
+// CompareAndSwapRecord updates a DNS record with the result of update, given its current content. When the record
+// changes between reading and updating it, update is called again with the new content, up to maxSwapAttempts times
+func (l *DNSWebhookClient) CompareAndSwapRecord(name, recordType string, update func(current types.DNSRecord) (types.DNSRecord, error)) error {
+	return l.CompareAndSwapRecordContext(context.Background(), name, recordType, update)
+}
+
+// CompareAndSwapRecordContext updates a DNS record with the result of update, see CompareAndSwapRecord. ctx bounds
+// the requests
+func (l *DNSWebhookClient) CompareAndSwapRecordContext(ctx context.Context, name, recordType string, update func(current types.DNSRecord) (types.DNSRecord, error)) error {
+	var err error
+	for attempt := 0; attempt < maxSwapAttempts; attempt++ {
+		current, etag, getErr := l.getRecord(ctx, name, recordType)
+		if getErr != nil {
+			return getErr
+		}
+		updated, updateErr := update(current)
+		if updateErr != nil {
+			return updateErr
+		}
+		if err = l.UpdateRecordIfMatchContext(ctx, &updated, etag); !IsPreconditionFailed(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// IsPreconditionFailed tells if err reports a record whose content no longer matches the ETag of a conditional request
+func IsPreconditionFailed(err error) bool {
+	var e *types.Error
+	return errors.As(err, &e) && e.Code == http.StatusPreconditionFailed
+}
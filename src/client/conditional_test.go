@@ -0,0 +1,104 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// etagServer serves a single record, honoring If-Match and If-None-Match: * on its updates
+type etagServer struct {
+	mu      sync.Mutex
+	record  *types.DNSRecord
+	updates int
+
+	// beforeUpdate is called before each update, e.g. to simulate a concurrent change
+	beforeUpdate func(s *etagServer)
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("ETag", s.record.ETag())
+		_ = json.NewEncoder(w).Encode(s.record)
+	case http.MethodPut:
+		if s.beforeUpdate != nil {
+			s.beforeUpdate(s)
+		}
+		ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+		if (ifMatch != "" && (s.record == nil || ifMatch != s.record.ETag())) || (ifNoneMatch == "*" && s.record != nil) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_ = json.NewEncoder(w).Encode(types.PreconditionFailedError("Precondition failed", nil))
+			return
+		}
+		var record types.DNSRecord
+		_ = json.NewDecoder(r.Body).Decode(&record)
+		s.record = &record
+		s.updates++
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestDNSWebhookClient_ConditionalRequests(t *testing.T) {
+	s := &etagServer{record: &types.DNSRecord{Name: "test.com", Type: "TXT", Value: "v=1"}}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record, etag, err := c.GetRecordWithETag("test.com", "TXT")
+	if err != nil || etag != s.record.ETag() {
+		t.Fatalf("expected the ETag of the record, got %s, %v", etag, err)
+	}
+	record.Value = "v=2"
+	if err := c.UpdateRecordIfMatch(&record, etag); err != nil {
+		t.Fatal(err)
+	}
+	record.Value = "v=3"
+	if err := c.UpdateRecordIfMatch(&record, etag); !IsPreconditionFailed(err) {
+		t.Errorf("expected a failed precondition with a stale ETag, got %v", err)
+	}
+	if err := c.CreateRecordIfNotExists(&record); !IsPreconditionFailed(err) {
+		t.Errorf("expected a failed precondition creating an existing record, got %v", err)
+	}
+}
+
+func TestDNSWebhookClient_CompareAndSwapRecord(t *testing.T) {
+	concurrentChanges := 2
+	s := &etagServer{
+		record: &types.DNSRecord{Name: "test.com", Type: "TXT", Value: "0"},
+		beforeUpdate: func(s *etagServer) {
+			if concurrentChanges > 0 {
+				concurrentChanges--
+				s.record = &types.DNSRecord{Name: "test.com", Type: "TXT", Value: s.record.Value + "0"}
+			}
+		},
+	}
+	server := httptest.NewServer(s)
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	err = c.CompareAndSwapRecord("test.com", "TXT", func(current types.DNSRecord) (types.DNSRecord, error) {
+		calls++
+		current.Value += "1"
+		return current, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || s.record.Value != "0001" || s.updates != 1 {
+		t.Errorf("expected the update to be retried on the latest content, got %d calls and %+v", calls, s.record)
+	}
+}
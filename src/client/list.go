@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// defaultPageSize is the number of records of the pages fetched by ListRecords when no other size is given
+const defaultPageSize = 100
+
+// ListOptions selects and sorts the records listed by ListRecords and GetRecordsPage
+type ListOptions struct {
+	// Filter the conditions the records must satisfy
+	Filter types.RecordFilter
+
+	// Sort the field sorting the records: name, type or value, preceded by "-" for the descending order. The records
+	// are sorted by name when empty
+	Sort string
+
+	// PageSize the number of records fetched by each request, 100 by default
+	PageSize int
+}
+
+// GetRecordsPage lists a page of the records selected by the options. cursor is the position of the page, empty for
+// the first one; the cursor of the next page is returned, empty on the last page
+func (l *DNSWebhookClient) GetRecordsPage(ctx context.Context, options ListOptions, cursor string) (result []types.DNSRecord, next string, err error) {
+	query := url.Values{}
+	options.Filter.Query(query)
+	if options.Sort != "" {
+		query.Set(types.QuerySort, options.Sort)
+	}
+	pageSize := options.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	query.Set(types.QueryLimit, strconv.Itoa(pageSize))
+	if cursor != "" {
+		query.Set(types.QueryCursor, cursor)
+	}
+
+	resp, data, err := l.request(ctx, http.MethodGet, recordsPath+"?"+query.Encode(), nil)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = parseResponseBodyToError(resp, data)
+		return
+	}
+	if err = json.Unmarshal(data, &result); err != nil {
+		return
+	}
+	for i := range result {
+		result[i].SyncValue()
+		result[i].SyncUnicodeName()
+	}
+	return result, resp.Header.Get(types.HeaderNextCursor), nil
+}
+
+// ListRecords returns an iterator over the records selected by the options, fetching them page by page as needed
+func (l *DNSWebhookClient) ListRecords(options ListOptions) *RecordIterator {
+	return l.ListRecordsContext(context.Background(), options)
+}
+
+// ListRecordsContext returns an iterator over the records selected by the options, see ListRecords. ctx bounds the
+// requests fetching the pages
+func (l *DNSWebhookClient) ListRecordsContext(ctx context.Context, options ListOptions) *RecordIterator {
+	return &RecordIterator{ctx: ctx, client: l, options: options}
+}
+
+// RecordIterator walks all the pages of a listing of records:
+//
+//	it := client.ListRecords(ListOptions{Filter: types.RecordFilter{Zone: "example.com"}})
+//	for it.Next() {
+//		record := it.Record()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type RecordIterator struct {
+	ctx     context.Context
+	client  *DNSWebhookClient
+	options ListOptions
+
+	page   []types.DNSRecord
+	index  int
+	cursor string
+	last   bool
+	record types.DNSRecord
+	err    error
+}
+
+// Next advances to the next record, fetching the next page when needed. Returns false once every record was walked
+// or a request failed, see Err
+func (it *RecordIterator) Next() bool {
+	for it.index >= len(it.page) {
+		if it.last || it.err != nil {
+			return false
+		}
+		it.page, it.cursor, it.err = it.client.GetRecordsPage(it.ctx, it.options, it.cursor)
+		it.index = 0
+		it.last = it.cursor == ""
+		if it.err != nil {
+			return false
+		}
+	}
+	it.record = it.page[it.index]
+	it.index++
+	return true
+}
+
+// Record returns the current record
+func (it *RecordIterator) Record() types.DNSRecord {
+	return it.record
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *RecordIterator) Err() error {
+	return it.err
+}
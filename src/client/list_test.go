@@ -0,0 +1,74 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_ListRecords(t *testing.T) {
+	var records []types.DNSRecord
+	for i := 0; i < 5; i++ {
+		records = append(records, types.DNSRecord{Name: fmt.Sprintf("r%d.example.com", i), Type: "A", Value: "10.0.0.1"})
+	}
+	tests := []struct {
+		name      string
+		failPage  int
+		wantNames int
+		wantErr   bool
+	}{
+		{"every page", -1, 5, false},
+		{"failing page", 1, 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				queries = append(queries, r.URL.RawQuery)
+				start, _ := strconv.Atoi(query.Get(types.QueryCursor))
+				if start/2 == tt.failPage {
+					w.WriteHeader(http.StatusInternalServerError)
+					_ = json.NewEncoder(w).Encode(types.InternalServerError("failed", nil))
+					return
+				}
+				end := start + 2
+				if end < len(records) {
+					w.Header().Set(types.HeaderNextCursor, strconv.Itoa(end))
+				} else {
+					end = len(records)
+				}
+				_ = json.NewEncoder(w).Encode(records[start:end])
+			}))
+			defer server.Close()
+			c, err := New(server.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			it := c.ListRecords(ListOptions{Filter: types.RecordFilter{Types: []string{"A"}}, Sort: "-name", PageSize: 2})
+			var got []types.DNSRecord
+			for it.Next() {
+				got = append(got, it.Record())
+			}
+			if !reflect.DeepEqual(got, records[:tt.wantNames]) {
+				t.Errorf("want %v, got %v", records[:tt.wantNames], got)
+			}
+			if (it.Err() != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, it.Err())
+			}
+			if queries[0] != "limit=2&sort=-name&type=A" || (len(queries) > 1 && queries[1] != "cursor=2&limit=2&sort=-name&type=A") {
+				t.Errorf("unexpected queries %v", queries)
+			}
+			if it.Next() {
+				t.Error("expected the iteration to be over")
+			}
+		})
+	}
+}
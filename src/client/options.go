@@ -0,0 +1,145 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/tlsconfig"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// TLSConfig defines how the client secures its communication with the hook
+type TLSConfig struct {
+	// CAFile the CA bundle used to verify the hook certificate. The system roots are used when empty
+	CAFile string
+
+	// CertFile the client certificate presented to the hook when it requires mutual TLS
+	CertFile string
+
+	// KeyFile the key of the client certificate
+	KeyFile string
+
+	// ServerName the name expected on the hook certificate. Defaults to the host of the manager address, which must then
+	// have a scheme when CAFile is set
+	ServerName string
+}
+
+// Option defines a function that customizes a DNSWebhookClient on its creation
+type Option func(*settings)
+
+// settings groups the values defined by the options
+type settings struct {
+	names    types.NameNormalizer
+	tls      *TLSConfig
+	wrappers []func(http.RoundTripper) http.RoundTripper
+	retries  retryPolicy
+	basePath string
+}
+
+// WithTLS configures the CA bundle, client certificate and server name used on HTTPS connections to the hook.
+// The files are reloaded when they change
+func WithTLS(config TLSConfig) Option {
+	return func(s *settings) {
+		s.tls = &config
+	}
+}
+
+// WithDefaultZone makes names not ending with a dot relative to the zone, e.g. "app" becomes "app.example.com" on the
+// "example.com" zone. See types.NameNormalizer
+func WithDefaultZone(zone string) Option {
+	return func(s *settings) {
+		s.names.DefaultZone = zone
+	}
+}
+
+// WithBasePath prefixes the path of every request with the given one, matching a hook served with hook.WithBasePath,
+// e.g. "/dns" sends the requests to "/dns/records"
+func WithBasePath(path string) Option {
+	return func(s *settings) {
+		s.basePath = "/" + strings.Trim(path, "/")
+		if s.basePath == "/" {
+			s.basePath = ""
+		}
+	}
+}
+
+// WithRetries retries the requests failing with a network error or a 429, 502, 503 or 504 status, up to attempts more
+// times. The first retry waits backoff, which must be positive, doubled on each later one up to 30 seconds, unless the
+// response tells how long to wait on its Retry-After header. Requests changing records carry an Idempotency-Key header,
+// the same on all their attempts, so the hook applies them once. Without a ClientAPI implementing RequestAPI, which can
+// send the key, only GET requests are retried
+func WithRetries(attempts int, backoff time.Duration) Option {
+	return func(s *settings) {
+		s.retries = retryPolicy{attempts: attempts, backoff: backoff}
+	}
+}
+
+// wrap adds a wrapper to the transport of the http.Client, e.g. to authenticate the requests
+func (s *settings) wrap(wrapper func(http.RoundTripper) http.RoundTripper) {
+	s.wrappers = append(s.wrappers, wrapper)
+}
+
+// httpClient derives the http.Client used by the DNSWebhookClient from the one given to New, which is never modified
+func (s *settings) httpClient(base *http.Client, managerAddress string) (*http.Client, error) {
+	if s.tls == nil && len(s.wrappers) == 0 {
+		return base, nil
+	}
+	if base == nil {
+		base = http.DefaultClient
+	}
+	httpClient := *base
+
+	if s.tls != nil {
+		transport, err := s.tlsTransport(httpClient.Transport, managerAddress)
+		if err != nil {
+			return nil, err
+		}
+		httpClient.Transport = transport
+	}
+
+	if len(s.wrappers) > 0 {
+		transport := httpClient.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		for _, wrapper := range s.wrappers {
+			transport = wrapper(transport)
+		}
+		httpClient.Transport = transport
+	}
+	return &httpClient, nil
+}
+
+// tlsTransport derives from base a transport configured with the TLS settings
+func (s *settings) tlsTransport(base http.RoundTripper, managerAddress string) (http.RoundTripper, error) {
+	serverName := s.tls.ServerName
+	if serverName == "" {
+		if u, err := url.Parse(managerAddress); err == nil {
+			serverName = u.Hostname()
+		}
+	}
+	if serverName == "" && s.tls.CAFile != "" {
+		return nil, fmt.Errorf("no server name to verify the hook certificate: set TLSConfig.ServerName or give the scheme "+
+			"of the manager address, e.g. https://%s", managerAddress)
+	}
+	tlsConfig, err := tlsconfig.Client(s.tls.CAFile, s.tls.CertFile, s.tls.KeyFile, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	transport, ok := base.(*http.Transport)
+	if base == nil {
+		transport, ok = http.DefaultTransport.(*http.Transport)
+	}
+	if ok {
+		transport = transport.Clone()
+	} else {
+		transport = &http.Transport{Proxy: http.ProxyFromEnvironment, DialContext: (&net.Dialer{}).DialContext}
+	}
+	transport.TLSClientConfig = tlsConfig
+	return transport, nil
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettings_httpClient(t *testing.T) {
+	t.Run("keep the given client when no option changes it", func(t *testing.T) {
+		base := &http.Client{}
+		got, err := (&settings{}).httpClient(base, "http://localhost:7070")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != base {
+			t.Error("expected the given client to be used")
+		}
+	})
+	t.Run("invalid TLS files", func(t *testing.T) {
+		s := &settings{}
+		WithTLS(TLSConfig{CAFile: "missing.pem"})(s)
+		if _, err := s.httpClient(http.DefaultClient, "https://localhost:7070"); err == nil {
+			t.Error("expected an error loading a missing CA bundle")
+		}
+	})
+	t.Run("CA bundle without server name", func(t *testing.T) {
+		s := &settings{}
+		WithTLS(TLSConfig{CAFile: "ca.pem"})(s)
+		if _, err := s.httpClient(http.DefaultClient, "localhost:7070"); err == nil || !strings.Contains(err.Error(), "server name") {
+			t.Errorf("expected an error for the missing server name, got %v", err)
+		}
+	})
+	t.Run("TLS does not change the given client", func(t *testing.T) {
+		s := &settings{}
+		WithTLS(TLSConfig{})(s)
+		got, err := s.httpClient(http.DefaultClient, "https://localhost:7070")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got == http.DefaultClient || http.DefaultClient.Transport != nil {
+			t.Fatal("the default client must not be modified")
+		}
+		transport, ok := got.Transport.(*http.Transport)
+		if !ok || transport == http.DefaultTransport || transport.TLSClientConfig.ServerName != "localhost" {
+			t.Errorf("unexpected transport %#v", got.Transport)
+		}
+	})
+}
+
+func TestWithDefaultZone(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path+" "+string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, nil, WithDefaultZone("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddRecord("App", "a", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RemoveRecord("App.Example.com.", "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddRecord("Café.", "A", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`/records {"name":"app.example.com","value":"10.0.0.1","type":"A"}`,
+		"/records/app.example.com/A ",
+		`/records {"name":"xn--caf-dma","value":"10.0.0.1","type":"A"}`,
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("want the requests %q, got %q", want, paths)
+	}
+
+	if _, err := New(server.URL, nil, WithDefaultZone("exa mple.com")); err == nil {
+		t.Error("expected an error for an invalid default zone")
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.EscapedPath())
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	for _, basePath := range []string{"/dns", "dns/"} {
+		paths = nil
+		c, err := New(server.URL, nil, WithBasePath(basePath))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = c.GetRecords()
+		_, _ = c.GetRecord("a/b?.test.com", "A")
+		_ = c.RemoveRRSet("a#b.test.com", "A")
+		_, _ = c.Watch(context.Background())
+		want := []string{
+			"GET /dns/records",
+			"GET /dns/records/a%2Fb%3F.test.com/A",
+			"DELETE /dns/rrsets/a%23b.test.com/A",
+			"GET /dns/records/watch",
+		}
+		if !reflect.DeepEqual(paths, want) {
+			t.Errorf("%q: want the requests %q, got %q", basePath, want, paths)
+		}
+	}
+}
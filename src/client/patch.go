@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// PatchRecord changes some fields of a DNS record with a JSON Merge Patch, e.g. map[string]interface{}{"ttl": 600},
+// and returns the resulting record, empty when the caller may not get it. Fields set to nil are removed
+func (l *DNSWebhookClient) PatchRecord(name, recordType string, patch interface{}) (types.DNSRecord, error) {
+	return l.PatchRecordContext(context.Background(), name, recordType, patch)
+}
+
+// PatchRecordContext changes some fields of a DNS record with a JSON Merge Patch, see PatchRecord. ctx bounds the
+// request
+func (l *DNSWebhookClient) PatchRecordContext(ctx context.Context, name, recordType string, patch interface{}) (types.DNSRecord, error) {
+	return l.patchRecord(ctx, name, recordType, types.MergePatchContentType, patch)
+}
+
+// PatchRecordOperations changes a DNS record with the operations of a JSON Patch, applied atomically, and returns the
+// resulting record, empty when the caller may not get it
+func (l *DNSWebhookClient) PatchRecordOperations(name, recordType string, operations ...types.PatchOperation) (types.DNSRecord, error) {
+	return l.PatchRecordOperationsContext(context.Background(), name, recordType, operations...)
+}
+
+// PatchRecordOperationsContext changes a DNS record with the operations of a JSON Patch, see PatchRecordOperations.
+// ctx bounds the request
+func (l *DNSWebhookClient) PatchRecordOperationsContext(ctx context.Context, name, recordType string, operations ...types.PatchOperation) (types.DNSRecord, error) {
+	return l.patchRecord(ctx, name, recordType, types.JSONPatchContentType, operations)
+}
+
+// patchRecord sends the patch with the given content type
+func (l *DNSWebhookClient) patchRecord(ctx context.Context, name, recordType, contentType string, patch interface{}) (result types.DNSRecord, err error) {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return
+	}
+	resp, data, err := l.requestWithHeader(ctx, http.MethodPatch, l.recordPath(name, recordType), http.Header{"Content-Type": {contentType}}, body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		// the policy of the webhook does not allow the caller to get the record
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		err = parseResponseBodyToError(resp, data)
+		return
+	}
+	err = json.Unmarshal(data, &result)
+	result.SyncValue()
+	result.SyncUnicodeName()
+	return
+}
@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_PatchRecord(t *testing.T) {
+	record := types.DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1", TTL: 600}
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		method, path, contentType, body = r.Method, r.URL.Path, r.Header.Get("Content-Type"), string(data)
+		_ = json.NewEncoder(w).Encode(record)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		call            func() (types.DNSRecord, error)
+		wantContentType string
+		wantBody        string
+	}{
+		{"merge patch", func() (types.DNSRecord, error) {
+			return c.PatchRecord("Test.com.", "a", map[string]interface{}{"ttl": 600})
+		}, types.MergePatchContentType, `{"ttl":600}`},
+		{"json patch", func() (types.DNSRecord, error) {
+			return c.PatchRecordOperations("test.com", "A", types.PatchOperation{Op: "replace", Path: "/ttl", Value: json.RawMessage("600")})
+		}, types.JSONPatchContentType, `[{"op":"replace","path":"/ttl","value":600}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != record {
+				t.Errorf("want %+v, got %+v", record, got)
+			}
+			if method != http.MethodPatch || path != "/records/test.com/A" || contentType != tt.wantContentType || body != tt.wantBody {
+				t.Errorf("unexpected request %s %s (%s) %s", method, path, contentType, body)
+			}
+		})
+	}
+}
+
+func TestDNSWebhookClient_PatchRecord_NoContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := c.PatchRecord("test.com", "A", map[string]interface{}{"ttl": 600}); err != nil || got != (types.DNSRecord{}) {
+		t.Errorf("expected an empty record when the caller may not get it, got %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/labbsr0x/goh/gohclient"
+)
+
+// RequestAPI extends gohclient.API with requests of any method, like PATCH, carrying a context and custom headers.
+// A ClientAPI that does not implement it only supports GET, POST, PUT and DELETE requests
+type RequestAPI interface {
+	gohclient.API
+
+	// Request sends a request to the path, relative to the manager address, and returns the response and its body
+	Request(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, []byte, error)
+}
+
+// httpAPI is the RequestAPI built by New, sending the requests the same way as gohclient.Default
+type httpAPI struct {
+	*gohclient.Default
+}
+
+// StreamAPI extends gohclient.API with requests whose response body is read as it arrives, like the stream of
+// changes followed by Watch
+type StreamAPI interface {
+	gohclient.API
+
+	// Stream sends a request to the path, relative to the manager address, and returns the response without reading its
+	// body, which the caller must close
+	Stream(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error)
+}
+
+// Request sends a request with the user agent, content type and accept headers of the client, followed by header
+func (a *httpAPI) Request(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	resp, err := a.Stream(ctx, method, path, header, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+// Stream sends a request the same way as Request, leaving the body of the response unread
+func (a *httpAPI) Stream(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	u, err := a.BaseURL.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	if body != nil && strings.TrimSpace(a.ContentType) != "" {
+		req.Header.Set("Content-Type", a.ContentType)
+	}
+	if strings.TrimSpace(a.Accept) != "" {
+		req.Header.Set("Accept", a.Accept)
+	}
+	if strings.TrimSpace(a.UserAgent) != "" {
+		req.Header.Set("User-Agent", a.UserAgent)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return a.HTTPClient.Do(req)
+}
+
+// request sends a request through the ClientAPI, using RequestAPI when it is implemented. Otherwise ctx is only checked
+// before sending the request
+func (l *DNSWebhookClient) request(ctx context.Context, method, path string, body []byte) (*http.Response, []byte, error) {
+	return l.requestWithHeader(ctx, method, path, nil, body)
+}
+
+// requestWithHeader sends a request with custom headers, which requires a ClientAPI implementing RequestAPI. The request
+// is retried as defined by WithRetries
+func (l *DNSWebhookClient) requestWithHeader(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	if l.retries.attempts <= 0 {
+		return l.send(ctx, method, path, header, body)
+	}
+	return l.sendWithRetries(ctx, method, path, header, body)
+}
+
+// send sends a single request to the path, prefixed with the base path of the client, see requestWithHeader
+func (l *DNSWebhookClient) send(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	path = l.basePath + path
+	if api, ok := l.ClientAPI.(RequestAPI); ok {
+		return api.Request(ctx, method, path, header, body)
+	}
+	if len(header) > 0 {
+		return nil, nil, fmt.Errorf("the ClientAPI does not support custom headers; it must implement RequestAPI")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	switch method {
+	case http.MethodGet:
+		return l.ClientAPI.Get(path)
+	case http.MethodPost:
+		return l.ClientAPI.Post(path, body)
+	case http.MethodPut:
+		return l.ClientAPI.Put(path, body)
+	case http.MethodDelete:
+		return l.ClientAPI.Delete(path)
+	}
+	return nil, nil, fmt.Errorf("the ClientAPI does not support %s requests; it must implement RequestAPI", method)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// maxBackoff bounds the wait between two attempts, however many times the backoff got doubled
+const maxBackoff = 30 * time.Second
+
+// retryPolicy defines how many times a failed request is retried and how long it waits first, see WithRetries
+type retryPolicy struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// check returns an error when the policy retries without waiting between the attempts or has a negative number of them
+func (p retryPolicy) check() error {
+	if p.attempts < 0 {
+		return fmt.Errorf("invalid retries: the attempts must not be negative, got %d", p.attempts)
+	}
+	if p.attempts > 0 && p.backoff <= 0 {
+		return fmt.Errorf("invalid retries: the backoff must be positive, got %s", p.backoff)
+	}
+	return nil
+}
+
+// next returns the backoff following delay: its double, up to maxBackoff
+func (p retryPolicy) next(delay time.Duration) time.Duration {
+	if delay *= 2; delay > maxBackoff || delay <= 0 {
+		return maxBackoff
+	}
+	return delay
+}
+
+// sendWithRetries sends the request, retrying it when it fails with a network error or a transient status, or while
+// an earlier attempt is still being served. Requests changing records get an Idempotency-Key header shared by all
+// their attempts
+func (l *DNSWebhookClient) sendWithRetries(ctx context.Context, method, path string, header http.Header, body []byte) (*http.Response, []byte, error) {
+	if _, ok := l.ClientAPI.(RequestAPI); !ok && (method != http.MethodGet || len(header) > 0) {
+		// without the key, a retry could apply the change twice
+		return l.send(ctx, method, path, header, body)
+	}
+	if method != http.MethodGet {
+		if header.Get(types.HeaderIdempotencyKey) == "" {
+			key, err := newNonce()
+			if err != nil {
+				return nil, nil, err
+			}
+			header = header.Clone()
+			if header == nil {
+				header = http.Header{}
+			}
+			header.Set(types.HeaderIdempotencyKey, key)
+		}
+	}
+
+	delay := l.retries.backoff
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		resp, data, err := l.send(ctx, method, path, header, body)
+		if attempt == l.retries.attempts || !retryable(ctx, resp, data, err) {
+			return resp, data, err
+		}
+		timer := time.NewTimer(retryDelay(resp, delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return resp, data, err
+		case <-timer.C:
+		}
+		delay = l.retries.next(delay)
+	}
+}
+
+// retryable tells if a request that got the response or the error may succeed when sent again. A 409 is retried only
+// when an earlier attempt with the same idempotency key is still being served
+func retryable(ctx context.Context, resp *http.Response, data []byte, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	case http.StatusConflict:
+		return types.AsError(parseResponseBodyToError(resp, data)).ErrorCode == types.CodeRequestInProgress
+	}
+	return false
+}
+
+// retryDelay returns how long to wait before retrying: the seconds of the Retry-After header of the response, if any,
+// or delay otherwise
+func retryDelay(resp *http.Response, delay time.Duration) time.Duration {
+	if resp == nil {
+		return delay
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return delay
+}
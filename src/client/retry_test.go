@@ -0,0 +1,134 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     []int
+		attempts     int
+		call         func(c *DNSWebhookClient) error
+		wantRequests int
+		wantErr      bool
+		wantKey      bool
+	}{
+		{"retried change", []int{http.StatusServiceUnavailable, 0}, 3, func(c *DNSWebhookClient) error {
+			return c.AddRecord("test.com", "A", "10.0.0.1")
+		}, 3, false, true},
+		{"retried get", []int{http.StatusBadGateway}, 3, func(c *DNSWebhookClient) error {
+			_, err := c.GetRecords()
+			return err
+		}, 2, false, false},
+		{"attempts exhausted", []int{http.StatusTooManyRequests, http.StatusGatewayTimeout, http.StatusServiceUnavailable}, 2, func(c *DNSWebhookClient) error {
+			return c.RemoveRecord("test.com", "A")
+		}, 3, true, true},
+		{"retried while in progress", []int{http.StatusConflict}, 3, func(c *DNSWebhookClient) error {
+			return c.RemoveRecord("test.com", "A")
+		}, 2, false, true},
+		{"not retryable", []int{http.StatusBadRequest}, 3, func(c *DNSWebhookClient) error {
+			return c.RemoveRecord("test.com", "A")
+		}, 1, true, true},
+		{"retries disabled", []int{http.StatusServiceUnavailable}, 0, func(c *DNSWebhookClient) error {
+			return c.RemoveRecord("test.com", "A")
+		}, 1, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var keys []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				attempt := len(keys)
+				keys = append(keys, r.Header.Get(types.HeaderIdempotencyKey))
+				mu.Unlock()
+				if attempt < len(tt.failures) {
+					switch tt.failures[attempt] {
+					case 0:
+						// closes the connection without a response
+						panic(http.ErrAbortHandler)
+					case http.StatusServiceUnavailable:
+						w.Header().Set("Retry-After", "0")
+					case http.StatusConflict:
+						w.Header().Set("Retry-After", "0")
+						w.WriteHeader(http.StatusConflict)
+						_, _ = w.Write([]byte(`{"message":"A request with the same idempotency key is in progress","code":409,"errorCode":"REQUEST_IN_PROGRESS"}`))
+						return
+					}
+					w.WriteHeader(tt.failures[attempt])
+					return
+				}
+				if r.Method == http.MethodGet {
+					_, _ = w.Write([]byte("[]"))
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+			c, err := New(server.URL, nil, WithRetries(tt.attempts, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.call(c); (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+			if len(keys) != tt.wantRequests {
+				t.Fatalf("want %d requests, got %d", tt.wantRequests, len(keys))
+			}
+			for _, key := range keys {
+				if (key != "") != tt.wantKey || key != keys[0] {
+					t.Errorf("want the same idempotency key on every attempt, got %v", keys)
+				}
+			}
+		})
+	}
+}
+
+func TestWithRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		backoff  time.Duration
+		wantErr  bool
+	}{
+		{"valid", 3, time.Second, false},
+		{"disabled", 0, 0, false},
+		{"zero backoff", 3, 0, true},
+		{"negative backoff", 3, -time.Second, true},
+		{"negative attempts", -1, time.Second, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New("http://localhost", nil, WithRetries(tt.attempts, tt.backoff)); (err != nil) != tt.wantErr {
+				t.Errorf("want error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestRetryPolicy_next(t *testing.T) {
+	tests := []struct {
+		name  string
+		delay time.Duration
+		want  time.Duration
+	}{
+		{"doubled", time.Second, 2 * time.Second},
+		{"capped", 20 * time.Second, maxBackoff},
+		{"overflow", time.Duration(1 << 62), maxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (retryPolicy{attempts: 1, backoff: time.Second}).next(tt.delay); got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const rrSetsPath = "/rrsets"
+
+// GetRRSets communicates with the dns manager and gets the RRSets
+func (l *DNSWebhookClient) GetRRSets() (result []types.RRSet, err error) {
+	resp, data, err := l.request(context.Background(), http.MethodGet, rrSetsPath, nil)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode == http.StatusOK {
+		err = json.Unmarshal(data, &result)
+	} else {
+		err = parseResponseBodyToError(resp, data)
+	}
+	return
+}
+
+// GetRRSet communicates with the dns manager and gets the RRSet identified by name and type
+func (l *DNSWebhookClient) GetRRSet(name, recordType string) (result types.RRSet, err error) {
+	resp, data, err := l.request(context.Background(), http.MethodGet, l.rrSetPath(name, recordType), nil)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode == http.StatusOK {
+		err = json.Unmarshal(data, &result)
+	} else {
+		err = parseResponseBodyToError(resp, data)
+	}
+	return
+}
+
+// ReplaceRRSet creates the RRSet or replaces all the values of an existing one
+func (l *DNSWebhookClient) ReplaceRRSet(set *types.RRSet) error {
+	canonical := l.names.RRSet(*set)
+	set = &canonical
+	if errs := set.Check(); errs != nil {
+		return fmt.Errorf("invalid RRSet: %v", strings.Join(errs, ", "))
+	}
+	body, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	resp, data, err := l.request(context.Background(), http.MethodPut, l.rrSetPath(set.Name, set.Type), body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return parseResponseBodyToError(resp, data)
+	}
+	return nil
+}
+
+// AddRRSetValues adds values to the RRSet, creating it when needed, and returns the resulting set
+func (l *DNSWebhookClient) AddRRSetValues(name, recordType string, values ...string) (types.RRSet, error) {
+	return l.ChangeRRSet(name, recordType, types.RRSetChange{Add: values})
+}
+
+// RemoveRRSetValues removes values from the RRSet and returns the resulting set. The set is removed when no value is
+// left, in which case the returned set has no values
+func (l *DNSWebhookClient) RemoveRRSetValues(name, recordType string, values ...string) (types.RRSet, error) {
+	return l.ChangeRRSet(name, recordType, types.RRSetChange{Remove: values})
+}
+
+// ChangeRRSet adds and removes values of the RRSet atomically and returns the resulting set
+func (l *DNSWebhookClient) ChangeRRSet(name, recordType string, change types.RRSetChange) (result types.RRSet, err error) {
+	body, err := json.Marshal(change)
+	if err != nil {
+		return
+	}
+	resp, data, err := l.request(context.Background(), http.MethodPatch, l.rrSetPath(name, recordType), body)
+	if err != nil {
+		return
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.Unmarshal(data, &result)
+	case http.StatusNoContent:
+		result = types.RRSet{Name: l.names.Name(name), Type: l.names.Type(recordType)}
+	default:
+		err = parseResponseBodyToError(resp, data)
+	}
+	return
+}
+
+// RemoveRRSet removes the RRSet with all its values
+func (l *DNSWebhookClient) RemoveRRSet(name, recordType string) error {
+	resp, data, err := l.request(context.Background(), http.MethodDelete, l.rrSetPath(name, recordType), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return parseResponseBodyToError(resp, data)
+	}
+	return nil
+}
+
+// rrSetPath returns the path of the RRSet identified by name and type, escaping both
+func (l *DNSWebhookClient) rrSetPath(name, recordType string) string {
+	return rrSetsPath + "/" + url.PathEscape(l.names.Name(name)) + "/" + url.PathEscape(l.names.Type(recordType))
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_RRSets(t *testing.T) {
+	set := types.RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.2"}}
+	var method, path, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rrsets":
+			_ = json.NewEncoder(w).Encode([]types.RRSet{set})
+		case r.Method == http.MethodGet, r.Method == http.MethodPatch:
+			_ = json.NewEncoder(w).Encode(set)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		call       func() (interface{}, error)
+		wantMethod string
+		wantPath   string
+		wantBody   string
+		wantResult interface{}
+	}{
+		{"get the sets", func() (interface{}, error) { return c.GetRRSets() },
+			"GET", "/rrsets", "", []types.RRSet{set}},
+		{"get a set", func() (interface{}, error) { return c.GetRRSet("test.com", "A") },
+			"GET", "/rrsets/test.com/A", "", set},
+		{"replace a set", func() (interface{}, error) { return nil, c.ReplaceRRSet(&set) },
+			"PUT", "/rrsets/test.com/A", `{"name":"test.com","type":"A","values":["10.0.0.1","10.0.0.2"]}`, nil},
+		{"add values", func() (interface{}, error) { return c.AddRRSetValues("test.com", "A", "10.0.0.2") },
+			"PATCH", "/rrsets/test.com/A", `{"add":["10.0.0.2"]}`, set},
+		{"remove values", func() (interface{}, error) { return c.RemoveRRSetValues("test.com", "A", "10.0.0.3") },
+			"PATCH", "/rrsets/test.com/A", `{"remove":["10.0.0.3"]}`, set},
+		{"remove a set", func() (interface{}, error) { return nil, c.RemoveRRSet("test.com", "A") },
+			"DELETE", "/rrsets/test.com/A", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.call()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if method != tt.wantMethod || path != tt.wantPath || string(body) != tt.wantBody {
+				t.Errorf("want %s %s %s, got %s %s %s", tt.wantMethod, tt.wantPath, tt.wantBody, method, path, body)
+			}
+			if tt.wantBody != "" && contentType != "application/json" {
+				t.Errorf("want a JSON content type, got '%s'", contentType)
+			}
+			if !reflect.DeepEqual(result, tt.wantResult) {
+				t.Errorf("want the result %+v, got %+v", tt.wantResult, result)
+			}
+		})
+	}
+}
+
+func TestDNSWebhookClient_ReplaceRRSet_Invalid(t *testing.T) {
+	c := &DNSWebhookClient{ClientAPI: &MockHTTPHelperSuccess{Status: http.StatusNoContent}}
+	if err := c.ReplaceRRSet(&types.RRSet{Name: "test.com", Type: "A", Values: []string{"x"}}); err == nil {
+		t.Error("expected an error for an invalid set")
+	}
+}
+
+func TestDNSWebhookClient_ChangeRRSet_UnsupportedAPI(t *testing.T) {
+	c := &DNSWebhookClient{ClientAPI: &MockHTTPHelperSuccess{Status: http.StatusOK}}
+	if _, err := c.AddRRSetValues("test.com", "A", "10.0.0.1"); err == nil {
+		t.Error("expected an error when the ClientAPI cannot send PATCH requests")
+	}
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const subscriptionsPath = "/subscriptions"
+
+// AddSubscription registers a subscription notified of the changes of the records, returning it with its ID. The
+// secret is not returned
+func (l *DNSWebhookClient) AddSubscription(subscription types.Subscription) (types.Subscription, error) {
+	return l.AddSubscriptionContext(context.Background(), subscription)
+}
+
+// AddSubscriptionContext registers a subscription, see AddSubscription. ctx bounds the request
+func (l *DNSWebhookClient) AddSubscriptionContext(ctx context.Context, subscription types.Subscription) (result types.Subscription, err error) {
+	if errs := subscription.Check(); errs != nil {
+		err = fmt.Errorf("invalid subscription: %v", strings.Join(errs, ", "))
+		return
+	}
+	body, err := json.Marshal(subscription)
+	if err != nil {
+		return
+	}
+	resp, data, err := l.request(ctx, http.MethodPost, subscriptionsPath, body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusCreated {
+		err = parseResponseBodyToError(resp, data)
+		return
+	}
+	err = json.Unmarshal(data, &result)
+	return
+}
+
+// GetSubscriptions gets the subscriptions, without their secrets
+func (l *DNSWebhookClient) GetSubscriptions() ([]types.Subscription, error) {
+	return l.GetSubscriptionsContext(context.Background())
+}
+
+// GetSubscriptionsContext gets the subscriptions, without their secrets. ctx bounds the request
+func (l *DNSWebhookClient) GetSubscriptionsContext(ctx context.Context) (result []types.Subscription, err error) {
+	err = l.getJSON(ctx, subscriptionsPath, &result)
+	return
+}
+
+// GetSubscription gets the subscription identified by id, without its secret
+func (l *DNSWebhookClient) GetSubscription(id string) (types.Subscription, error) {
+	return l.GetSubscriptionContext(context.Background(), id)
+}
+
+// GetSubscriptionContext gets the subscription identified by id, without its secret. ctx bounds the request
+func (l *DNSWebhookClient) GetSubscriptionContext(ctx context.Context, id string) (result types.Subscription, err error) {
+	err = l.getJSON(ctx, subscriptionPath(id), &result)
+	return
+}
+
+// RemoveSubscription unregisters the subscription identified by id, along with its dead letters
+func (l *DNSWebhookClient) RemoveSubscription(id string) error {
+	return l.RemoveSubscriptionContext(context.Background(), id)
+}
+
+// RemoveSubscriptionContext unregisters the subscription identified by id, see RemoveSubscription. ctx bounds the
+// request
+func (l *DNSWebhookClient) RemoveSubscriptionContext(ctx context.Context, id string) error {
+	resp, data, err := l.request(ctx, http.MethodDelete, subscriptionPath(id), nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusNoContent {
+		return parseResponseBodyToError(resp, data)
+	}
+	return nil
+}
+
+// GetDeadLetters gets the events that could not be delivered to the subscription identified by id, oldest first
+func (l *DNSWebhookClient) GetDeadLetters(id string) ([]types.DeadLetter, error) {
+	return l.GetDeadLettersContext(context.Background(), id)
+}
+
+// GetDeadLettersContext gets the events that could not be delivered to the subscription, see GetDeadLetters. ctx
+// bounds the request
+func (l *DNSWebhookClient) GetDeadLettersContext(ctx context.Context, id string) (result []types.DeadLetter, err error) {
+	err = l.getJSON(ctx, subscriptionPath(id)+"/dead-letters", &result)
+	return
+}
+
+// getJSON gets the resource of the path, decoding it into result
+func (l *DNSWebhookClient) getJSON(ctx context.Context, path string, result interface{}) error {
+	resp, data, err := l.request(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return parseResponseBodyToError(resp, data)
+	}
+	return json.Unmarshal(data, result)
+}
+
+// subscriptionPath returns the path of the subscription identified by id
+func subscriptionPath(id string) string {
+	return subscriptionsPath + "/" + url.PathEscape(id)
+}
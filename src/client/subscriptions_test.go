@@ -0,0 +1,70 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_Subscriptions(t *testing.T) {
+	subscription := types.Subscription{ID: "s1", URL: "https://cmdb.example.com/dns", Filter: types.SubscriptionFilter{Zones: []string{"example.com"}}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "POST /subscriptions":
+			var received types.Subscription
+			body, _ := ioutil.ReadAll(r.Body)
+			if err := json.Unmarshal(body, &received); err != nil || received.Secret != "secret" {
+				t.Errorf("unexpected subscription %s", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(subscription)
+		case "GET /subscriptions":
+			_ = json.NewEncoder(w).Encode([]types.Subscription{subscription})
+		case "GET /subscriptions/s1":
+			_ = json.NewEncoder(w).Encode(subscription)
+		case "GET /subscriptions/s1/dead-letters":
+			_ = json.NewEncoder(w).Encode([]types.DeadLetter{{Subscription: "s1", Attempts: 5, Error: "the subscriber answered 500"}})
+		case "DELETE /subscriptions/s1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(types.NotFoundError("Subscription not found", nil))
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.AddSubscription(types.Subscription{URL: "https://cmdb.example.com/dns"}); err == nil {
+		t.Errorf("expected a subscription without secret to be refused")
+	}
+	toAdd := subscription
+	toAdd.Secret = "secret"
+	if added, err := c.AddSubscription(toAdd); err != nil || added.ID != "s1" {
+		t.Errorf("unexpected subscription %+v (%v)", added, err)
+	}
+	if subscriptions, err := c.GetSubscriptions(); err != nil || len(subscriptions) != 1 {
+		t.Errorf("unexpected subscriptions %+v (%v)", subscriptions, err)
+	}
+	if got, err := c.GetSubscription("s1"); err != nil || got.Filter.Zones[0] != "example.com" {
+		t.Errorf("unexpected subscription %+v (%v)", got, err)
+	}
+	if _, err := c.GetSubscription("missing"); types.AsError(err).Code != http.StatusNotFound {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+	if deadLetters, err := c.GetDeadLetters("s1"); err != nil || len(deadLetters) != 1 || deadLetters[0].Attempts != 5 {
+		t.Errorf("unexpected dead letters %+v (%v)", deadLetters, err)
+	}
+	if err := c.RemoveSubscription("s1"); err != nil {
+		t.Error(err)
+	}
+	if err := c.RemoveSubscription("missing"); err == nil {
+		t.Errorf("expected an error removing a missing subscription")
+	}
+}
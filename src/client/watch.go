@@ -0,0 +1,182 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// watchPath is the stream of the changes of the records
+	watchPath = recordsPath + "/watch"
+
+	// minReconnectDelay and maxReconnectDelay bound the wait before Watch reconnects, doubled after each failure
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Watcher delivers the changes of the records streamed by Watch
+type Watcher struct {
+	events chan types.RecordEvent
+	err    error
+}
+
+// Events returns the channel of the changes. It is closed once the context given to Watch is done, or when the webhook
+// refuses to reconnect the stream, see Err
+func (w *Watcher) Events() <-chan types.RecordEvent {
+	return w.events
+}
+
+// Err returns the error that ended the watch once the channel of Events is closed: nil when the context given to Watch
+// was done, or the error the webhook answered when reconnecting with a status that retrying does not change, like 401,
+// 403 or 404
+func (w *Watcher) Err() error {
+	return w.err
+}
+
+// Watch streams the changes of the records made through the webhook. The stream reconnects automatically, resuming
+// after the last event received; an event of op types.EventReset is delivered when some events were missed, after
+// which the records must be listed again. Requires a ClientAPI implementing StreamAPI
+func (l *DNSWebhookClient) Watch(ctx context.Context) (*Watcher, error) {
+	api, ok := l.ClientAPI.(StreamAPI)
+	if !ok {
+		return nil, fmt.Errorf("the ClientAPI does not support streaming; it must implement StreamAPI")
+	}
+	body, err := openWatch(ctx, api, l.basePath+watchPath, "")
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{events: make(chan types.RecordEvent)}
+	go func() {
+		defer close(w.events)
+		lastID, delay := "", minReconnectDelay
+		for {
+			received, err := readEvents(ctx, body, w.events, &lastID)
+			body.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				delay = minReconnectDelay
+			}
+			logrus.Infof("Watch stream ended, reconnecting in %v: %v", delay, err)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+				if delay *= 2; delay > maxReconnectDelay {
+					delay = maxReconnectDelay
+				}
+				if body, err = openWatch(ctx, api, l.basePath+watchPath, lastID); err == nil {
+					break
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				if permanent(err) {
+					logrus.Errorf("Error reconnecting the watch stream, giving up: %v", err)
+					w.err = err
+					return
+				}
+				logrus.Errorf("Error reconnecting the watch stream, retrying in %v: %v", delay, err)
+			}
+		}
+	}()
+	return w, nil
+}
+
+// permanent tells if the error answered by the webhook would be answered again on a new attempt: any 4xx status but
+// 408 Request Timeout and 429 Too Many Requests
+func permanent(err error) bool {
+	var e *types.Error
+	if !errors.As(err, &e) || e.Code < 400 || e.Code > 499 {
+		return false
+	}
+	return e.Code != http.StatusRequestTimeout && e.Code != http.StatusTooManyRequests
+}
+
+// openWatch opens the stream of the changes on path, resuming after lastID when given
+func openWatch(ctx context.Context, api StreamAPI, path, lastID string) (io.ReadCloser, error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if lastID != "" {
+		header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := api.Stream(ctx, http.MethodGet, path, header, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, parseResponseBodyToError(resp, data)
+	}
+	return resp.Body, nil
+}
+
+// readEvents delivers the Server-Sent Events of the stream until it ends, keeping the ID of the last one. Tells if any
+// event was received
+func readEvents(ctx context.Context, body io.Reader, events chan<- types.RecordEvent, lastID *string) (bool, error) {
+	reader := bufio.NewReader(body)
+	received := false
+	var id string
+	var data []string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return received, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			field, value := line, ""
+			if i := strings.IndexByte(line, ':'); i >= 0 {
+				field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+			}
+			switch field {
+			case "id":
+				id = value
+			case "data":
+				data = append(data, value)
+			}
+			continue
+		}
+		if data == nil {
+			continue
+		}
+		var event types.RecordEvent
+		if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event); err != nil {
+			logrus.Errorf("Error decoding the watch event '%s': %v", id, err)
+		} else {
+			if event.Record != nil {
+				event.Record.SyncValue()
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return received, ctx.Err()
+			}
+		}
+		received = true
+		if id != "" {
+			*lastID = id
+		}
+		id, data = "", nil
+	}
+}
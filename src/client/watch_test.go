@@ -0,0 +1,175 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhookClient_Watch(t *testing.T) {
+	var mu sync.Mutex
+	var lastIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/records/watch" || r.Header.Get("Accept") != "text/event-stream" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		mu.Lock()
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		connection := len(lastIDs)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connection {
+		case 1:
+			fmt.Fprint(w, ": keepalive\n\n")
+			fmt.Fprint(w, "id: e-1\nevent: add\ndata: {\"id\":\"e-1\",\"op\":\"add\",\"name\":\"a.test.com\",\"type\":\"A\",\"record\":{\"name\":\"a.test.com\",\"type\":\"A\",\"value\":\"10.0.0.1\"}}\n\n")
+			fmt.Fprint(w, "id: e-2\nevent: delete\ndata: {\"id\":\"e-2\",\"op\":\"delete\",\"name\":\"a.test.com\",\"type\":\"A\"}\n\n")
+		case 2:
+			fmt.Fprint(w, "id: e-3\nevent: reset\ndata: {\"id\":\"e-3\",\"op\":\"reset\"}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watcher, err := c.Watch(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := watcher.Events()
+	for _, want := range []string{types.BatchAdd, types.BatchDelete, types.EventReset} {
+		select {
+		case event := <-events:
+			if event.Op != want {
+				t.Errorf("want a %s event, got %+v", want, event)
+			}
+			if event.Op == types.BatchAdd && (event.Record == nil || event.Record.Value != "10.0.0.1") {
+				t.Errorf("unexpected record %+v", event.Record)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected a %s event", want)
+		}
+	}
+	mu.Lock()
+	if len(lastIDs) != 2 || lastIDs[0] != "" || lastIDs[1] != "e-2" {
+		t.Errorf("expected the stream to resume after e-2, got %q", lastIDs)
+	}
+	mu.Unlock()
+
+	cancel()
+	select {
+	case _, open := <-events:
+		if open {
+			t.Errorf("expected no more events")
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("expected the channel to be closed")
+	}
+	if err := watcher.Err(); err != nil {
+		t.Errorf("expected no error once the context is done, got %v", err)
+	}
+}
+
+func TestDNSWebhookClient_Watch_Refused(t *testing.T) {
+	var mu sync.Mutex
+	connections := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		connection := connections
+		mu.Unlock()
+		if connection > 1 {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"message":"Forbidden","code":403,"errorCode":"POLICY_DENIED"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: e-1\nevent: add\ndata: {\"id\":\"e-1\",\"op\":\"add\",\"name\":\"a.test.com\",\"type\":\"A\"}\n\n")
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := c.Watch(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := 0
+	timeout := time.After(10 * time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-watcher.Events():
+			if open {
+				received++
+			}
+		case <-timeout:
+			t.Fatal("expected the channel to be closed once the webhook refuses the stream")
+		}
+	}
+	if received != 1 {
+		t.Errorf("want 1 event, got %d", received)
+	}
+	if e := types.AsError(watcher.Err()); e == nil || e.Code != http.StatusForbidden {
+		t.Errorf("expected the forbidden error, got %v", watcher.Err())
+	}
+	mu.Lock()
+	if connections != 2 {
+		t.Errorf("expected no reconnection after the refusal, got %d connections", connections)
+	}
+	mu.Unlock()
+}
+
+func TestDNSWebhookClient_Watch_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"message":"Forbidden","code":403,"errorCode":"FORBIDDEN"}`)
+	}))
+	defer server.Close()
+	c, err := New(server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Watch(context.Background()); err == nil {
+		t.Errorf("expected the error of the first connection")
+	}
+	c.ClientAPI = c.ClientAPI.(*httpAPI).Default
+	if _, err := c.Watch(context.Background()); err == nil {
+		t.Errorf("expected an error without a StreamAPI")
+	}
+}
+
+func Test_permanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"unauthorized", &types.Error{Code: http.StatusUnauthorized}, true},
+		{"not found", &types.Error{Code: http.StatusNotFound}, true},
+		{"request timeout", &types.Error{Code: http.StatusRequestTimeout}, false},
+		{"too many requests", &types.Error{Code: http.StatusTooManyRequests}, false},
+		{"unavailable", &types.Error{Code: http.StatusServiceUnavailable}, false},
+		{"network error", fmt.Errorf("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := permanent(tt.err); got != tt.want {
+				t.Errorf("permanent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultMaxClockSkew is how far the timestamp of a signed request may be from the hook clock
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// maxSignedBodySize bounds the size of the bodies read to verify a signature
+const maxSignedBodySize = 1 << 20
+
+// errNoCredentials is returned by authenticators when the request does not carry their kind of credentials
+var errNoCredentials = errors.New("no credentials")
+
+// Authenticator identifies the caller of a request
+type Authenticator interface {
+
+	// Authenticate returns the identity of the caller or an error if the request cannot be authenticated
+	Authenticate(r *http.Request) (string, error)
+}
+
+// callerKey is the context key of the caller identity
+type callerKey struct{}
+
+// CallerFromContext returns the identity of the authenticated caller of the request the context belongs to
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// BearerTokens authenticates requests carrying a token on the 'Authorization: Bearer <token>' header.
+// Maps each accepted token to the identity of its caller
+type BearerTokens map[string]string
+
+// Authenticate implements the Authenticator interface
+func (b BearerTokens) Authenticate(r *http.Request) (string, error) {
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return "", errNoCredentials
+	}
+	if caller, ok := b[strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))]; ok {
+		return caller, nil
+	}
+	return "", errors.New("invalid bearer token")
+}
+
+// HMACAuthenticator authenticates requests signed with types.Sign. The key id of the request identifies the caller.
+// A request is accepted only once, and only if signed within the max clock skew
+type HMACAuthenticator struct {
+	secrets map[string][]byte
+	maxSkew time.Duration
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewHMACAuthenticator builds an HMACAuthenticator from a map of key ids to their secrets. A non-positive maxSkew means
+// DefaultMaxClockSkew
+func NewHMACAuthenticator(secrets map[string]string, maxSkew time.Duration) *HMACAuthenticator {
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+	a := &HMACAuthenticator{secrets: map[string][]byte{}, maxSkew: maxSkew, seen: map[string]time.Time{}}
+	for keyID, secret := range secrets {
+		a.secrets[keyID] = []byte(secret)
+	}
+	return a
+}
+
+// Authenticate implements the Authenticator interface
+func (a *HMACAuthenticator) Authenticate(r *http.Request) (string, error) {
+	keyID := r.Header.Get(types.HeaderKeyID)
+	signature := r.Header.Get(types.HeaderSignature)
+	if keyID == "" && signature == "" {
+		return "", errNoCredentials
+	}
+	secret, ok := a.secrets[keyID]
+	if !ok {
+		return "", fmt.Errorf("unknown key id '%s'", keyID)
+	}
+
+	timestamp := r.Header.Get(types.HeaderTimestamp)
+	nonce := r.Header.Get(types.HeaderNonce)
+	if nonce == "" {
+		return "", errors.New("the request nonce is missing")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("invalid request timestamp")
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := time.Since(signedAt); skew > a.maxSkew || skew < -a.maxSkew {
+		return "", errors.New("the request timestamp is out of the accepted window")
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBodySize))
+	if err != nil {
+		return "", err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	if !types.VerifySignature(signature, secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body) {
+		return "", errors.New("invalid request signature")
+	}
+	if !a.firstUse(keyID+"\n"+nonce, signedAt.Add(a.maxSkew)) {
+		return "", errors.New("the request was already received")
+	}
+	return keyID, nil
+}
+
+// firstUse records the nonce until it expires and tells if it had not been seen yet
+func (a *HMACAuthenticator) firstUse(nonce string, expiresAt time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	for n, expiration := range a.seen {
+		if now.After(expiration) {
+			delete(a.seen, n)
+		}
+	}
+	if _, ok := a.seen[nonce]; ok {
+		return false
+	}
+	a.seen[nonce] = expiresAt
+	return true
+}
+
+// authenticate is a middleware that only lets through requests accepted by one of the authenticators
+func authenticate(authenticators []Authenticator, skip func(r *http.Request) bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var failure error
+		for _, authenticator := range authenticators {
+			caller, err := authenticator.Authenticate(r)
+			if err == nil {
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, caller)))
+				return
+			}
+			if failure == nil || failure == errNoCredentials {
+				failure = err
+			}
+		}
+
+		logrus.Warnf("Unauthenticated request %s %s: %v", r.Method, r.URL.Path, failure)
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, r, types.UnauthorizedError("The request could not be authenticated", failure, failure.Error()))
+	})
+}
@@ -0,0 +1,125 @@
+package hook
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestBearerTokens_Authenticate(t *testing.T) {
+	tokens := BearerTokens{"token-a": "team-a"}
+	tests := []struct {
+		name          string
+		authorization string
+		wantCaller    string
+		wantErr       bool
+	}{
+		{"valid token", "Bearer token-a", "team-a", false},
+		{"unknown token", "Bearer token-b", "", true},
+		{"no token", "", "", true},
+		{"other scheme", "Basic dXNlcjpwYXNz", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/records", nil)
+			r.Header.Set("Authorization", tt.authorization)
+			caller, err := tokens.Authenticate(r)
+			if (err != nil) != tt.wantErr || caller != tt.wantCaller {
+				t.Errorf("Authenticate() = %s, %v, want %s, error %v", caller, err, tt.wantCaller, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHMACAuthenticator_Authenticate(t *testing.T) {
+	authenticator := NewHMACAuthenticator(map[string]string{"team-a": "secret"}, time.Minute)
+	body := []byte(`{"name":"a.test.com","value":"127.0.0.1","type":"A"}`)
+
+	signed := func(keyID, secret, nonce string, signedAt time.Time) *http.Request {
+		r := httptest.NewRequest("POST", "/records?x=1", bytes.NewReader(body))
+		timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+		r.Header.Set(types.HeaderKeyID, keyID)
+		r.Header.Set(types.HeaderTimestamp, timestamp)
+		r.Header.Set(types.HeaderNonce, nonce)
+		r.Header.Set(types.HeaderSignature, types.Sign([]byte(secret), "POST", "/records?x=1", timestamp, nonce, body))
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		wantErr bool
+	}{
+		{"valid signature", signed("team-a", "secret", "1", time.Now()), false},
+		{"replayed request", signed("team-a", "secret", "1", time.Now()), true},
+		{"unknown key id", signed("team-b", "secret", "2", time.Now()), true},
+		{"wrong secret", signed("team-a", "other", "3", time.Now()), true},
+		{"expired timestamp", signed("team-a", "secret", "4", time.Now().Add(-2*time.Minute)), true},
+		{"future timestamp", signed("team-a", "secret", "5", time.Now().Add(2*time.Minute)), true},
+		{"missing nonce", signed("team-a", "secret", "", time.Now()), true},
+		{"unsigned request", httptest.NewRequest("GET", "/records", nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caller, err := authenticator.Authenticate(tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Authenticate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && caller != "team-a" {
+				t.Errorf("expected caller team-a, got %s", caller)
+			}
+		})
+	}
+
+	t.Run("the body is still readable by the handlers", func(t *testing.T) {
+		r := signed("team-a", "secret", "6", time.Now())
+		if _, err := authenticator.Authenticate(r); err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		buf.ReadFrom(r.Body)
+		if buf.String() != string(body) {
+			t.Errorf("expected body %s, got %s", body, buf.String())
+		}
+	})
+}
+
+func TestServer_Authentication(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []Option
+		path    string
+		token   string
+		want    int
+	}{
+		{"authenticated request", []Option{WithAuthentication(BearerTokens{"t": "a"})}, "/records", "t", http.StatusOK},
+		{"unauthenticated request", []Option{WithAuthentication(BearerTokens{"t": "a"})}, "/records", "", http.StatusUnauthorized},
+		{"invalid token", []Option{WithAuthentication(BearerTokens{"t": "a"})}, "/records", "x", http.StatusUnauthorized},
+		{"protected metrics", []Option{WithAuthentication(BearerTokens{"t": "a"})}, "/metrics", "", http.StatusUnauthorized},
+		{"public metrics", []Option{WithAuthentication(BearerTokens{"t": "a"}), WithPublicMetrics()}, "/metrics", "", http.StatusOK},
+		{"public metrics do not open the records", []Option{WithAuthentication(BearerTokens{"t": "a"}), WithPublicMetrics()}, "/records", "", http.StatusUnauthorized},
+		{"no authentication", nil, "/records", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(&SuccessDNSManagerMock{records}, "1", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest("GET", tt.path, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, r)
+			if res.Code != tt.want {
+				t.Errorf("want status %d, got %d", tt.want, res.Code)
+			}
+		})
+	}
+}
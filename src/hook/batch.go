@@ -0,0 +1,261 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxBatchOperations is the greatest number of operations a batch may have
+const MaxBatchOperations = 1000
+
+// batchVerbs maps the operations of a batch to the verbs authorizing them
+var batchVerbs = map[string]string{
+	types.BatchAdd:    VerbAdd,
+	types.BatchUpdate: VerbUpdate,
+	types.BatchDelete: VerbRemove,
+}
+
+// ApplyBatch applies several changes of records all or nothing. Every operation is validated and authorized before any
+// is applied. Managers implementing types.BatchManager apply them atomically; otherwise they are applied one by one,
+// undoing the completed ones when one of them fails
+// Expects a Batch object as a body payload and responds with a BatchResult
+func (m *DNSWebhook) ApplyBatch(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.applyBatch).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) applyBatch(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("ApplyBatch call. Http Request: %v", r)
+
+	var batch types.Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		return types.BadRequestError("Invalid request body. You must pass a JSON formatted batch on request body", err)
+	}
+	if len(batch.Operations) == 0 || len(batch.Operations) > MaxBatchOperations {
+		return types.BadRequestError("Invalid request body. The batch must have between 1 and "+fmt.Sprint(MaxBatchOperations)+" operations", nil)
+	}
+
+	operations, results := m.checkBatch(r, batch.Operations)
+	if failed(results) {
+		return writeBatchFailure(w, results, nil)
+	}
+
+	records := make([]types.DNSRecord, len(operations))
+	for i, operation := range operations {
+		records[i] = operation.Record
+	}
+	unlock := m.locks.lockAll(records)
+	defer unlock()
+
+	previous := m.checkBatchState(r.Context(), operations, results)
+	if failed(results) {
+		return writeBatchFailure(w, results, nil)
+	}
+	if applied, cause := m.applyBatchOperations(r.Context(), operations, previous, results); !applied {
+		return writeBatchFailure(w, results, cause)
+	}
+	return writeJSONResponse(types.BatchResult{Applied: true, Results: results}, http.StatusOK, w)
+}
+
+// checkBatch normalizes, validates and authorizes the operations, reporting the failures on the results
+func (m *DNSWebhook) checkBatch(r *http.Request, operations []types.BatchOperation) ([]types.BatchOperation, []types.BatchOperationResult) {
+	checked := make([]types.BatchOperation, len(operations))
+	results := make([]types.BatchOperationResult, len(operations))
+	changed := map[string]int{}
+	for i, operation := range operations {
+		operation.Op = strings.ToLower(operation.Op)
+		operation.Record = m.Names.Record(operation.Record)
+		checked[i] = operation
+		results[i] = types.BatchOperationResult{Op: operation.Op, Name: operation.Record.Name, Type: operation.Record.Type}
+		if err := m.checkBatchOperation(r, &checked[i]); err != nil {
+			results[i].Status, results[i].Error = statusOf(err)
+			continue
+		}
+		key := lockKey(operation.Record.Name, operation.Record.Type)
+		if j, ok := changed[key]; ok {
+			results[i].Status, results[i].Error = statusOf(types.BadRequestError("Invalid operation. A batch may change each record only once", nil,
+				fmt.Sprintf("the record '%s' of type '%s' is changed by the operation %d as well", operation.Record.Name, operation.Record.Type, j)))
+			continue
+		}
+		changed[key] = i
+	}
+	return checked, results
+}
+
+// checkBatchOperation validates and authorizes the operation, completing its record
+func (m *DNSWebhook) checkBatchOperation(r *http.Request, operation *types.BatchOperation) error {
+	verb, ok := batchVerbs[operation.Op]
+	if !ok {
+		return types.BadRequestError("Invalid operation", nil, fmt.Sprintf("the operation must be add, update or delete, got '%s'", operation.Op))
+	}
+	record := &operation.Record
+	if operation.Op == types.BatchDelete {
+		var errs []types.FieldError
+		for _, field := range []struct{ name, value string }{{"name", record.Name}, {"type", record.Type}} {
+			if strings.TrimSpace(field.value) == "" {
+				errs = append(errs, types.FieldError{Field: field.name, Code: types.CodeRequired,
+					Message: fmt.Sprintf("the value of field '%s' cannot be empty", field.name)})
+			}
+		}
+		if errs != nil {
+			return types.ValidationError("Invalid operation. You must pass the name and type of the record to delete", errs)
+		}
+		return m.authorize(r, verb, record.Name, record.Type)
+	}
+
+	record.SyncValue()
+	if errs := record.FieldErrors(); errs != nil {
+		return types.ValidationError("Invalid operation. You must pass a valid record", errs)
+	}
+	if err := m.authorize(r, verb, record.Name, record.Type); err != nil {
+		return err
+	}
+	return m.TTL.apply(record)
+}
+
+// checkBatchState checks the operations are consistent with the current records, reporting the failures on the results.
+// Returns the current record of each operation. Must be called holding the locks of every record
+func (m *DNSWebhook) checkBatchState(ctx context.Context, operations []types.BatchOperation, results []types.BatchOperationResult) []*types.DNSRecord {
+	previous := make([]*types.DNSRecord, len(operations))
+	for i, operation := range operations {
+		existing, err := m.existingRecord(ctx, operation.Record.Name, operation.Record.Type)
+		switch {
+		case err != nil:
+		case operation.Op == types.BatchAdd && existing != nil:
+			err = m.recordConflict(ctx, *existing)
+		case operation.Op != types.BatchAdd && existing == nil:
+			err = recordNotFound(operation.Record.Name, operation.Record.Type)
+		case operation.Op == types.BatchUpdate:
+			err = m.checkSingleValue(ctx, operation.Record.Name, operation.Record.Type)
+		}
+		if err != nil {
+			results[i].Status, results[i].Error = statusOf(err)
+		}
+		previous[i] = existing
+	}
+	return previous
+}
+
+// applyBatchOperations applies the operations, all at once through a types.BatchManager or one by one otherwise,
+// reporting their outcome on the results. Returns whether they all were applied and, when the failure concerns the
+// whole batch, like the error of a types.BatchManager or a rollback failure, its error
+func (m *DNSWebhook) applyBatchOperations(ctx context.Context, operations []types.BatchOperation, previous []*types.DNSRecord, results []types.BatchOperationResult) (bool, *types.Error) {
+	if manager, ok := m.DNSManager.(types.BatchManager); ok {
+		if err := manager.ApplyBatch(ctx, operations); err != nil {
+			return false, types.AsError(err)
+		}
+	} else {
+		for i, operation := range operations {
+			if err := m.applyBatchOperation(ctx, operation); err != nil {
+				results[i].Status, results[i].Error = statusOf(err)
+				if !m.rollbackBatch(operations[:i], previous, results) {
+					return false, types.InternalServerError("The batch failed and could not be fully rolled back", err)
+				}
+				return false, nil
+			}
+		}
+	}
+	for i, operation := range operations {
+		switch operation.Op {
+		case types.BatchAdd:
+			results[i].Status = http.StatusCreated
+		case types.BatchUpdate:
+			results[i].Status = http.StatusOK
+		default:
+			results[i].Status = http.StatusNoContent
+		}
+		event := types.RecordEvent{Op: operation.Op, Name: operation.Record.Name, Type: operation.Record.Type, Before: previous[i]}
+		if operation.Op != types.BatchDelete {
+			event.Record = &operations[i].Record
+		}
+		m.notify(event)
+	}
+	return true, nil
+}
+
+// applyBatchOperation applies a single operation
+func (m *DNSWebhook) applyBatchOperation(ctx context.Context, operation types.BatchOperation) error {
+	switch operation.Op {
+	case types.BatchAdd:
+		return m.manager().AddDNSRecordContext(ctx, operation.Record)
+	case types.BatchUpdate:
+		return m.manager().UpdateDNSRecordContext(ctx, operation.Record)
+	default:
+		return m.manager().RemoveDNSRecordContext(ctx, operation.Record.Name, operation.Record.Type)
+	}
+}
+
+// rollbackBatch undoes the applied operations, newest first, restoring the previous records. Returns whether all of them
+// were undone
+func (m *DNSWebhook) rollbackBatch(applied []types.BatchOperation, previous []*types.DNSRecord, results []types.BatchOperationResult) bool {
+	// the request context may be canceled already, which must not stop the rollback
+	ctx := context.Background()
+	undone := true
+	for i := len(applied) - 1; i >= 0; i-- {
+		operation := applied[i]
+		var err error
+		switch operation.Op {
+		case types.BatchAdd:
+			err = m.manager().RemoveDNSRecordContext(ctx, operation.Record.Name, operation.Record.Type)
+		case types.BatchUpdate:
+			err = m.manager().UpdateDNSRecordContext(ctx, *previous[i])
+		default:
+			err = m.manager().AddDNSRecordContext(ctx, *previous[i])
+		}
+		if err != nil {
+			logrus.Errorf("Error rolling back the operation %d (%s '%s' of type '%s'): %v", i, operation.Op, operation.Record.Name, operation.Record.Type, err)
+			results[i].Status, results[i].Error = statusOf(types.InternalServerError("The operation could not be rolled back", err))
+			undone = false
+			continue
+		}
+		results[i].Status, results[i].Error = statusOf(&types.Error{
+			Message: "The operation was rolled back because another one failed", Code: http.StatusFailedDependency, ErrorCode: types.CodeFailedDependency,
+		})
+	}
+	return undone
+}
+
+// writeBatchFailure writes the results of a batch that was not applied. The error of the batch is cause, when given, or
+// the one of its first failed operation otherwise, detailed by the failures of every operation. The operations without
+// a result were not applied because of the failures
+func writeBatchFailure(w http.ResponseWriter, results []types.BatchOperationResult, cause *types.Error) error {
+	var details []string
+	for i := range results {
+		result := &results[i]
+		if result.Error == nil {
+			result.Status = http.StatusFailedDependency
+			continue
+		}
+		if result.Error.Code == http.StatusFailedDependency {
+			continue
+		}
+		if cause == nil {
+			cause = &types.Error{Message: "The batch was not applied", Code: result.Error.Code, ErrorCode: result.Error.ErrorCode}
+		}
+		details = append(details, fmt.Sprintf("operation %d (%s '%s' of type '%s'): %s", i, result.Op, result.Name, result.Type, result.Error.Message))
+	}
+	e := *cause
+	e.Details = append(append([]string{}, e.Details...), details...)
+	return writeJSONResponse(types.BatchResult{Results: results, Error: &e}, e.Code, w)
+}
+
+// failed tells if some operation failed
+func failed(results []types.BatchOperationResult) bool {
+	for _, result := range results {
+		if result.Error != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// statusOf returns the status code and the types.Error describing err
+func statusOf(err error) (int, *types.Error) {
+	e := types.AsError(err)
+	return e.Code, e
+}
@@ -0,0 +1,163 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_ApplyBatch(t *testing.T) {
+	a := types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}
+	b := types.DNSRecord{Name: "b.test.com", Type: "A", Value: "10.0.0.2"}
+	tests := []struct {
+		name         string
+		body         string
+		failOn       string
+		wantCode     int
+		wantStatuses []int
+		wantRecords  []string
+	}{
+		{"applied", `{"operations":[
+			{"op":"add","record":{"name":"c.test.com","type":"A","value":"10.0.0.3"}},
+			{"op":"update","record":{"name":"A.test.com.","type":"a","value":"10.0.0.9"}},
+			{"op":"delete","record":{"name":"b.test.com","type":"A"}}]}`,
+			"", http.StatusOK, []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+			[]string{"a.test.com/A=10.0.0.9", "c.test.com/A=10.0.0.3"}},
+		{"invalid record", `{"operations":[
+			{"op":"add","record":{"name":"c.test.com","type":"A","value":"10.0.0.3"}},
+			{"op":"update","record":{"name":"a.test.com","type":"A","value":"::1"}}]}`,
+			"", http.StatusBadRequest, []int{http.StatusFailedDependency, http.StatusBadRequest},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"unknown operation", `{"operations":[{"op":"upsert","record":{"name":"a.test.com","type":"A","value":"10.0.0.3"}}]}`,
+			"", http.StatusBadRequest, []int{http.StatusBadRequest},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"record changed twice", `{"operations":[
+			{"op":"update","record":{"name":"a.test.com","type":"A","value":"10.0.0.3"}},
+			{"op":"delete","record":{"name":"a.test.com","type":"A"}}]}`,
+			"", http.StatusBadRequest, []int{http.StatusFailedDependency, http.StatusBadRequest},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"existing record", `{"operations":[
+			{"op":"delete","record":{"name":"b.test.com","type":"A"}},
+			{"op":"add","record":{"name":"a.test.com","type":"A","value":"10.0.0.3"}}]}`,
+			"", http.StatusConflict, []int{http.StatusFailedDependency, http.StatusConflict},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"missing record", `{"operations":[{"op":"delete","record":{"name":"c.test.com","type":"A"}}]}`,
+			"", http.StatusNotFound, []int{http.StatusNotFound},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"rolled back", `{"operations":[
+			{"op":"update","record":{"name":"a.test.com","type":"A","value":"10.0.0.9"}},
+			{"op":"delete","record":{"name":"b.test.com","type":"A"}},
+			{"op":"add","record":{"name":"c.test.com","type":"A","value":"10.0.0.3"}},
+			{"op":"add","record":{"name":"fail.test.com","type":"A","value":"10.0.0.4"}},
+			{"op":"add","record":{"name":"d.test.com","type":"A","value":"10.0.0.5"}}]}`,
+			"fail.test.com", http.StatusServiceUnavailable,
+			[]int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusFailedDependency, http.StatusServiceUnavailable, http.StatusFailedDependency},
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+		{"empty batch", `{"operations":[]}`, "", http.StatusBadRequest, nil,
+			[]string{"a.test.com/A=10.0.0.1", "b.test.com/A=10.0.0.2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &failingDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(a, b), failOn: tt.failOn}
+			server, err := New(manager, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest("POST", "/records:batch", strings.NewReader(tt.body)))
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			var result types.BatchResult
+			if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+				t.Fatal(err)
+			}
+			var statuses []int
+			for _, r := range result.Results {
+				statuses = append(statuses, r.Status)
+			}
+			if !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("want statuses %v, got %v", tt.wantStatuses, statuses)
+			}
+			if result.Applied != (tt.wantCode == http.StatusOK) {
+				t.Errorf("want applied %v, got %v", tt.wantCode == http.StatusOK, result.Applied)
+			}
+			if got := manager.contents(); !reflect.DeepEqual(got, tt.wantRecords) {
+				t.Errorf("want the records %v, got %v", tt.wantRecords, got)
+			}
+		})
+	}
+}
+
+func TestDNSWebhook_ApplyBatch_BatchManager(t *testing.T) {
+	manager := &batchDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock()}
+	server, err := New(manager, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `{"operations":[{"op":"add","record":{"name":"c.test.com","type":"A","value":"10.0.0.3"}}]}`
+
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("POST", "/records:batch", strings.NewReader(body)))
+	if res.Code != http.StatusOK || len(manager.batches) != 1 || manager.batches[0][0].Record.Name != "c.test.com" {
+		t.Fatalf("expected the batch to be given to the manager, got %d %v", res.Code, manager.batches)
+	}
+
+	manager.err = types.ErrRateLimited
+	res = httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("POST", "/records:batch", strings.NewReader(strings.Replace(body, "c.test", "d.test", 1))))
+	var result types.BatchResult
+	if err := json.Unmarshal(res.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != http.StatusTooManyRequests || result.Applied || result.Results[0].Status != http.StatusFailedDependency {
+		t.Errorf("expected the error of the manager, got %d %+v", res.Code, result)
+	}
+}
+
+// failingDNSManagerMock fails adding the record named failOn
+type failingDNSManagerMock struct {
+	*memoryDNSManagerMock
+	failOn string
+}
+
+func (m *failingDNSManagerMock) AddDNSRecord(record types.DNSRecord) error {
+	if record.Name == m.failOn {
+		return types.ErrBackendUnavailable
+	}
+	return m.memoryDNSManagerMock.AddDNSRecord(record)
+}
+
+// contents lists the records as sorted "name/type=value" strings
+func (m *memoryDNSManagerMock) contents() []string {
+	records, _ := m.GetDNSRecords()
+	var contents []string
+	for _, record := range records {
+		contents = append(contents, record.Name+"/"+record.Type+"="+record.Value)
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+// batchDNSManagerMock records the batches it applies
+type batchDNSManagerMock struct {
+	*memoryDNSManagerMock
+	batches [][]types.BatchOperation
+	err     error
+}
+
+func (m *batchDNSManagerMock) ApplyBatch(ctx context.Context, operations []types.BatchOperation) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.batches = append(m.batches, operations)
+	return nil
+}
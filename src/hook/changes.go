@@ -0,0 +1,256 @@
+package hook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultChangeWorkers is how many asynchronous changes are applied at once when no other limit is configured
+	DefaultChangeWorkers = 4
+
+	// DefaultChangeQueueSize is how many asynchronous changes may wait to be applied when no other limit is configured
+	DefaultChangeQueueSize = 100
+
+	// MaxRetainedChanges is how many completed changes are kept to be reported on /changes/{id}. The oldest ones are
+	// forgotten first
+	MaxRetainedChanges = 1000
+)
+
+// GetChange reports the status of a change applied asynchronously. Its id comes from url params
+func (m *DNSWebhook) GetChange(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getChange).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getChange(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetChange call. Http Request: %v", r)
+	id := mux.Vars(r)["id"]
+
+	var change types.Change
+	found := false
+	if m.changes != nil {
+		change, found = m.changes.get(id)
+	}
+	if !found {
+		return types.NotFoundError("Change not found", nil, fmt.Sprintf("there is no change '%s'", id))
+	}
+	if err := m.authorize(r, VerbGet, change.Name, change.Type); err != nil {
+		return err
+	}
+	return writeJSONResponse(change, http.StatusOK, w)
+}
+
+// changeFunc applies a change of a record, already validated and authorized, with the request asking for it
+type changeFunc func(w http.ResponseWriter, r *http.Request) error
+
+// applyChange applies the change and answers 204 No Content. When asynchronous changes are enabled and the request
+// asks for them with the "Prefer: respond-async" header, the change is queued instead, answering 202 Accepted with
+// the Change whose status is reported on the url of the Location header
+func (m *DNSWebhook) applyChange(w http.ResponseWriter, r *http.Request, op, name, recordType string, apply changeFunc) error {
+	if m.changes == nil || !respondAsync(r) {
+		if err := apply(w, r); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	// the change outlives the request, so it is applied with a copy of the request detached from its context, keeping
+	// only the caller the policy checks apply to
+	detached := r.Clone(context.Background())
+	caller := CallerFromContext(r.Context())
+	change, err := m.changes.submit(op, name, recordType, func(ctx context.Context) error {
+		return apply(&detachedResponse{header: http.Header{}}, detached.WithContext(context.WithValue(ctx, callerKey{}, caller)))
+	})
+	if err != nil {
+		if types.AsError(err).ErrorCode == types.CodeQueueFull {
+			w.Header().Set("Retry-After", "1")
+		}
+		return err
+	}
+	w.Header().Set("Location", changePath(r, change.ID))
+	w.Header().Set("Preference-Applied", "respond-async")
+	return writeJSONResponse(change, http.StatusAccepted, w)
+}
+
+// respondAsync tells if the Prefer header of the request holds the respond-async preference, as defined by RFC 7240
+func respondAsync(r *http.Request) bool {
+	for _, value := range r.Header["Prefer"] {
+		for _, preference := range strings.Split(value, ",") {
+			token := strings.TrimSpace(strings.SplitN(preference, ";", 2)[0])
+			if strings.EqualFold(token, "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// changePath returns the path reporting the change, next to the /records path of the request
+func changePath(r *http.Request, id string) string {
+	path := r.URL.Path
+	if i := strings.LastIndex(path, "/records"); i >= 0 {
+		path = path[:i]
+	}
+	return path + "/changes/" + id
+}
+
+// detachedResponse is the response of a change applied asynchronously, which nobody reads
+type detachedResponse struct {
+	header http.Header
+}
+
+func (d *detachedResponse) Header() http.Header {
+	return d.header
+}
+
+func (d *detachedResponse) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (d *detachedResponse) WriteHeader(int) {}
+
+// changeQueue applies changes asynchronously with a bounded number of workers, keeping their status so it can be
+// reported. Changes wait on a bounded queue, being refused when it is full
+type changeQueue struct {
+	jobs    chan changeJob
+	workers sync.WaitGroup
+
+	mu        sync.Mutex
+	closed    bool
+	changes   map[string]*types.Change
+	completed []string
+}
+
+// changeJob is a change waiting on the queue
+type changeJob struct {
+	id    string
+	apply func(ctx context.Context) error
+}
+
+// newChangeQueue starts the workers of a queue holding up to size changes
+func newChangeQueue(workers, size int) *changeQueue {
+	q := &changeQueue{jobs: make(chan changeJob, size), changes: map[string]*types.Change{}}
+	q.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// submit queues the change and returns its pending status. Fails with 503 Service Unavailable when the queue is full
+// or closed
+func (q *changeQueue) submit(op, name, recordType string, apply func(ctx context.Context) error) (types.Change, error) {
+	id, err := newID()
+	if err != nil {
+		return types.Change{}, err
+	}
+	change := &types.Change{ID: id, Op: op, Name: name, Type: recordType, Status: types.ChangePending, SubmittedAt: time.Now().UTC()}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return types.Change{}, types.ServiceUnavailableError("The webhook is shutting down, try again later", nil).WithErrorCode(types.CodeShuttingDown)
+	}
+	select {
+	case q.jobs <- changeJob{id, apply}:
+	default:
+		return types.Change{}, types.ServiceUnavailableError("Too many pending changes, try again later", nil,
+			fmt.Sprintf("%d changes are waiting to be applied", cap(q.jobs))).WithErrorCode(types.CodeQueueFull)
+	}
+	// the workers need the lock to complete the change, so it is registered before they can do it
+	q.changes[id] = change
+	return *change, nil
+}
+
+// get returns the status of the change identified by id
+func (q *changeQueue) get(id string) (types.Change, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	change, ok := q.changes[id]
+	if !ok {
+		return types.Change{}, false
+	}
+	return *change, true
+}
+
+// close stops accepting changes and waits for the queued ones to be applied. ctx bounds how long it waits
+func (q *changeQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.jobs)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the pending changes: %w", ctx.Err())
+	}
+}
+
+// work applies the queued changes until the queue is closed
+func (q *changeQueue) work() {
+	defer q.workers.Done()
+	for job := range q.jobs {
+		q.complete(job.id, run(job))
+	}
+}
+
+// run applies the change, turning a panic into its error
+func run(job changeJob) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logrus.Errorf("Panic applying the change %s: %v\n%s", job.id, p, debug.Stack())
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return job.apply(context.Background())
+}
+
+// complete records the outcome of the change, forgetting the oldest completed changes beyond MaxRetainedChanges
+func (q *changeQueue) complete(id string, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	change := q.changes[id]
+	now := time.Now().UTC()
+	change.CompletedAt = &now
+	change.Status = types.ChangeApplied
+	if err != nil {
+		logrus.Errorf("Change %s of '%s' of type '%s' failed: %v", id, change.Name, change.Type, err)
+		change.Status, change.Error = types.ChangeFailed, types.AsError(err)
+	}
+
+	q.completed = append(q.completed, id)
+	if len(q.completed) > MaxRetainedChanges {
+		delete(q.changes, q.completed[0])
+		q.completed = q.completed[1:]
+	}
+}
+
+// newID returns a random identifier, like the ones of changes and subscriptions
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,204 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_AsyncChanges(t *testing.T) {
+	existing := types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}
+	tests := []struct {
+		name       string
+		options    []Option
+		method     string
+		path       string
+		body       string
+		prefer     string
+		wantCode   int
+		wantStatus string
+		wantError  int
+	}{
+		{"added", []Option{WithAsyncChanges(1, 10)}, "POST", "/records", `{"name":"b.test.com","type":"A","value":"10.0.0.2"}`,
+			"respond-async", http.StatusAccepted, types.ChangeApplied, 0},
+		{"updated", []Option{WithAsyncChanges(0, 0)}, "PUT", "/records", `{"name":"a.test.com","type":"A","value":"10.0.0.2"}`,
+			"return=minimal, respond-async; wait=10", http.StatusAccepted, types.ChangeApplied, 0},
+		{"removed", []Option{WithAsyncChanges(1, 10)}, "DELETE", "/records/a.test.com/A", "",
+			"respond-async", http.StatusAccepted, types.ChangeApplied, 0},
+		{"failed", []Option{WithAsyncChanges(1, 10)}, "DELETE", "/records/b.test.com/A", "",
+			"respond-async", http.StatusAccepted, types.ChangeFailed, http.StatusNotFound},
+		{"invalid record", []Option{WithAsyncChanges(1, 10)}, "POST", "/records", `{"name":"b.test.com","type":"A","value":"::1"}`,
+			"respond-async", http.StatusBadRequest, "", 0},
+		{"synchronous request", []Option{WithAsyncChanges(1, 10)}, "DELETE", "/records/a.test.com/A", "",
+			"", http.StatusNoContent, "", 0},
+		{"asynchronous changes disabled", nil, "DELETE", "/records/a.test.com/A", "",
+			"respond-async", http.StatusNoContent, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(newMemoryDNSManagerMock(existing), "1", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Shutdown(context.Background())
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.prefer != "" {
+				req.Header.Set("Prefer", tt.prefer)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			if tt.wantStatus == "" {
+				return
+			}
+
+			var change types.Change
+			if err := json.Unmarshal(res.Body.Bytes(), &change); err != nil {
+				t.Fatal(err)
+			}
+			if change.Status != types.ChangePending || res.Header().Get("Location") != "/changes/"+change.ID ||
+				res.Header().Get("Preference-Applied") != "respond-async" {
+				t.Fatalf("unexpected accepted change %+v, headers %v", change, res.Header())
+			}
+			change = waitForChange(t, server, change.ID)
+			if change.Status != tt.wantStatus || change.CompletedAt == nil {
+				t.Errorf("want status %s, got %+v", tt.wantStatus, change)
+			}
+			if (change.Error == nil && tt.wantError != 0) || (change.Error != nil && change.Error.Code != tt.wantError) {
+				t.Errorf("want an error of status %d, got %+v", tt.wantError, change.Error)
+			}
+		})
+	}
+}
+
+func TestDNSWebhook_AsyncChanges_Caller(t *testing.T) {
+	policy, err := NewPolicy(Rule{Callers: []string{"a"}, Verbs: []string{VerbAdd, VerbGet}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}
+	server, err := New(newMemoryDNSManagerMock(existing), "1", WithAsyncChanges(1, 10),
+		WithAuthentication(BearerTokens{"t": "a"}), WithPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown(context.Background())
+	req := httptest.NewRequest("POST", "/records", strings.NewReader(`{"name":"a.test.com","type":"A","value":"10.0.0.2"}`))
+	req.Header.Set("Authorization", "Bearer t")
+	req.Header.Set("Prefer", "respond-async")
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, req)
+	var change types.Change
+	if err := json.Unmarshal(res.Body.Bytes(), &change); err != nil || res.Code != http.StatusAccepted {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+
+	waitFor(t, func() bool {
+		change, _ = server.Hook.changes.get(change.ID)
+		return change.Done()
+	})
+	// the conflict holds the existing record only when the policy allows the caller of the request to get it
+	if change.Error == nil || change.Error.Code != http.StatusConflict || change.Error.Record == nil {
+		t.Errorf("expected a conflict with the existing record, got %+v", change.Error)
+	}
+}
+
+func TestDNSWebhook_GetChange_NotFound(t *testing.T) {
+	for _, options := range [][]Option{nil, {WithAsyncChanges(1, 1)}} {
+		server, err := New(newMemoryDNSManagerMock(), "1", options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/changes/unknown", nil))
+		if res.Code != http.StatusNotFound {
+			t.Errorf("want status 404, got %d", res.Code)
+		}
+		_ = server.Shutdown(context.Background())
+	}
+}
+
+func TestDNSWebhook_AsyncChanges_QueueFull(t *testing.T) {
+	manager := &blockingDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(), release: make(chan struct{})}
+	server, err := New(manager, "1", WithAsyncChanges(1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	accepted := 0
+	var res *httptest.ResponseRecorder
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("POST", "/records", strings.NewReader(fmt.Sprintf(`{"name":"r%d.test.com","type":"A","value":"10.0.0.1"}`, i)))
+		req.Header.Set("Prefer", "respond-async")
+		res = httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, req)
+		if res.Code != http.StatusAccepted {
+			break
+		}
+		accepted++
+	}
+	if accepted > 2 || res.Code != http.StatusServiceUnavailable || res.Header().Get("Retry-After") == "" ||
+		!strings.Contains(res.Body.String(), types.CodeQueueFull) {
+		t.Errorf("expected the queue to be full after 2 changes, got %d accepted and %d %s", accepted, res.Code, res.Body.String())
+	}
+
+	close(manager.release)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if records, _ := manager.GetDNSRecords(); len(records) != accepted {
+		t.Errorf("expected the accepted changes to be applied on shutdown, got %v", records)
+	}
+}
+
+func Test_changeQueue_retention(t *testing.T) {
+	q := &changeQueue{changes: map[string]*types.Change{}}
+	for i := 0; i <= MaxRetainedChanges; i++ {
+		id := fmt.Sprint(i)
+		q.changes[id] = &types.Change{ID: id, Status: types.ChangePending}
+		q.complete(id, nil)
+	}
+	if _, ok := q.get("0"); ok {
+		t.Error("expected the oldest change to be forgotten")
+	}
+	if change, ok := q.get(fmt.Sprint(MaxRetainedChanges)); !ok || change.Status != types.ChangeApplied {
+		t.Errorf("expected the newest change to be applied, got %+v", change)
+	}
+}
+
+// waitForChange polls the change until it is done
+func waitForChange(t *testing.T, server *Server, id string) types.Change {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/changes/"+id, nil))
+		var change types.Change
+		if err := json.Unmarshal(res.Body.Bytes(), &change); err != nil || res.Code != http.StatusOK {
+			t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+		}
+		if change.Done() || time.Now().After(deadline) {
+			return change
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingDNSManagerMock adds records once released
+type blockingDNSManagerMock struct {
+	*memoryDNSManagerMock
+	release chan struct{}
+}
+
+func (m *blockingDNSManagerMock) AddDNSRecord(record types.DNSRecord) error {
+	<-m.release
+	return m.memoryDNSManagerMock.AddDNSRecord(record)
+}
@@ -0,0 +1,64 @@
+package hook
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// checkPreconditions evaluates the If-Match and If-None-Match headers of a request changing a record, as defined by
+// RFC 7232, against the current record, nil when missing. If-Match requires the record to exist with one of the given
+// ETags, "*" matching any one; If-None-Match requires it to have none of them, "*" requiring it to be missing. The
+// ETag of the current record is set on the response when the preconditions fail
+func checkPreconditions(w http.ResponseWriter, r *http.Request, current *types.DNSRecord) error {
+	var etag string
+	if current != nil {
+		etag = current.ETag()
+	}
+	if ifMatch := r.Header["If-Match"]; len(ifMatch) > 0 && (current == nil || !matchesETag(ifMatch, etag, false)) {
+		return preconditionFailed(w, etag, "If-Match")
+	}
+	if ifNoneMatch := r.Header["If-None-Match"]; len(ifNoneMatch) > 0 && current != nil && matchesETag(ifNoneMatch, etag, true) {
+		return preconditionFailed(w, etag, "If-None-Match")
+	}
+	return nil
+}
+
+// notModified tells if the If-None-Match header of a GET request matches the ETag of the current record
+func notModified(r *http.Request, etag string) bool {
+	ifNoneMatch := r.Header["If-None-Match"]
+	return len(ifNoneMatch) > 0 && matchesETag(ifNoneMatch, etag, true)
+}
+
+// createOnly tells if the request only allows creating the record, with the If-None-Match: * header
+func createOnly(r *http.Request) bool {
+	ifNoneMatch := r.Header["If-None-Match"]
+	return len(ifNoneMatch) == 1 && strings.TrimSpace(ifNoneMatch[0]) == "*"
+}
+
+// matchesETag tells if any of the ETags listed on the header values matches etag. "*" matches any ETag. Weak tags only
+// match on a weak comparison
+func matchesETag(values []string, etag string, weak bool) bool {
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if weak {
+				tag = strings.TrimPrefix(tag, "W/")
+			}
+			if tag == "*" || tag == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// preconditionFailed returns the error of a failed precondition, setting the ETag of the current record, if any
+func preconditionFailed(w http.ResponseWriter, etag, header string) error {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	return types.PreconditionFailedError("Precondition failed", nil, fmt.Sprintf("the record does not satisfy the %s header", header))
+}
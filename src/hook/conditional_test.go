@@ -0,0 +1,64 @@
+package hook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_ConditionalRequests(t *testing.T) {
+	current := types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.1"}
+	etag := current.ETag()
+	updated := `{"name":"app.test.com","type":"A","value":"10.0.0.2"}`
+	created := `{"name":"new.test.com","type":"A","value":"10.0.0.3"}`
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		header   string
+		value    string
+		wantCode int
+		wantETag string
+	}{
+		{"get sets the ETag", "GET", "/records/app.test.com/A", "", "", "", http.StatusOK, etag},
+		{"get not modified", "GET", "/records/app.test.com/A", "", "If-None-Match", etag, http.StatusNotModified, etag},
+		{"get modified", "GET", "/records/app.test.com/A", "", "If-None-Match", `"other"`, http.StatusOK, etag},
+		{"update matching", "PUT", "/records", updated, "If-Match", etag, http.StatusNoContent, ""},
+		{"update matching one of the tags", "PUT", "/records", updated, "If-Match", `"other", ` + etag, http.StatusNoContent, ""},
+		{"update matching any", "PUT", "/records", updated, "If-Match", "*", http.StatusNoContent, ""},
+		{"update not matching", "PUT", "/records", updated, "If-Match", `"other"`, http.StatusPreconditionFailed, etag},
+		{"update weak tag", "PUT", "/records", updated, "If-Match", "W/" + etag, http.StatusPreconditionFailed, etag},
+		{"update missing record with If-Match", "PUT", "/records", created, "If-Match", "*", http.StatusPreconditionFailed, ""},
+		{"update with If-None-Match matching", "PUT", "/records", updated, "If-None-Match", etag, http.StatusPreconditionFailed, etag},
+		{"create only existing record", "PUT", "/records", updated, "If-None-Match", "*", http.StatusPreconditionFailed, etag},
+		{"create only missing record", "PUT", "/records", created, "If-None-Match", "*", http.StatusNoContent, ""},
+		{"remove matching", "DELETE", "/records/app.test.com/A", "", "If-Match", etag, http.StatusNoContent, ""},
+		{"remove not matching", "DELETE", "/records/app.test.com/A", "", "If-Match", `"other"`, http.StatusPreconditionFailed, etag},
+		{"remove missing record with If-Match", "DELETE", "/records/new.test.com/A", "", "If-Match", "*", http.StatusPreconditionFailed, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(newMemoryDNSManagerMock(current), "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Errorf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			if got := res.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("want ETag %s, got %s", tt.wantETag, got)
+			}
+		})
+	}
+}
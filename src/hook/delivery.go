@@ -0,0 +1,332 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/hook/metrics"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultDeliveryAttempts is how many times an event is sent to a subscription before it becomes a dead letter,
+	// when no other number is configured
+	DefaultDeliveryAttempts = 5
+
+	// DefaultDeliveryBackoff is the wait before the second attempt to deliver an event when no other is configured.
+	// It doubles after each failed attempt, up to maxDeliveryBackoff
+	DefaultDeliveryBackoff = time.Second
+
+	// MaxDeadLetters is how many undelivered events are kept to be reported on /subscriptions/{id}/dead-letters. The
+	// oldest ones are forgotten first
+	MaxDeadLetters = 1000
+
+	// maxDeliveryBackoff bounds the wait between two attempts to deliver an event
+	maxDeliveryBackoff = 5 * time.Minute
+
+	// deliveryTimeout bounds each attempt to deliver an event
+	deliveryTimeout = 10 * time.Second
+
+	// deliveryQueueSize is how many events may wait to be delivered to each subscription. Further ones become dead
+	// letters right away
+	deliveryQueueSize = 1000
+)
+
+// subscriber delivers the events of a subscription in order, one at a time, so a slow or failing subscription only
+// delays its own events
+type subscriber struct {
+	subscription types.Subscription
+	events       chan types.RecordEvent
+	removed      chan struct{}
+}
+
+// notifier delivers the changes of the records to the subscriptions, retrying failed deliveries with an exponential
+// backoff and keeping the events that could not be delivered as dead letters
+type notifier struct {
+	client   *http.Client
+	attempts int
+	backoff  time.Duration
+	policy   *Policy
+	metrics  *metrics.Prometheus
+
+	stop    chan struct{}
+	workers sync.WaitGroup
+
+	mu          sync.Mutex
+	closed      bool
+	subscribers []*subscriber
+	deadLetters []types.DeadLetter
+}
+
+// newNotifier builds a notifier of the subscriptions and starts delivering their events. Subscriptions owned by a
+// caller are only notified of the changes the policy allows the caller to list
+func newNotifier(subscriptions []types.Subscription, attempts int, backoff time.Duration, policy *Policy, prometheus *metrics.Prometheus) *notifier {
+	n := &notifier{
+		client:   &http.Client{Timeout: deliveryTimeout},
+		attempts: attempts,
+		backoff:  backoff,
+		policy:   policy,
+		metrics:  prometheus,
+		stop:     make(chan struct{}),
+	}
+	for _, subscription := range subscriptions {
+		n.start(subscription)
+	}
+	return n
+}
+
+// start registers the subscription and starts delivering its events. Must be called holding the lock, or before the
+// notifier is shared
+func (n *notifier) start(subscription types.Subscription) {
+	s := &subscriber{
+		subscription: subscription,
+		events:       make(chan types.RecordEvent, deliveryQueueSize),
+		removed:      make(chan struct{}),
+	}
+	n.subscribers = append(n.subscribers, s)
+	n.workers.Add(1)
+	go n.work(s)
+}
+
+// add registers the subscription, whose ID must not be in use
+func (n *notifier) add(subscription types.Subscription) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return types.ServiceUnavailableError("The webhook is shutting down, try again later", nil).WithErrorCode(types.CodeShuttingDown)
+	}
+	if n.index(subscription.ID) >= 0 {
+		return types.ConflictError("Subscription already exists", nil, nil,
+			fmt.Sprintf("there is already a subscription '%s'", subscription.ID))
+	}
+	n.start(subscription)
+	return nil
+}
+
+// remove unregisters the subscription with its dead letters. Its pending deliveries are dropped. Tells if it existed
+func (n *notifier) remove(id string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	i := n.index(id)
+	if i < 0 {
+		return false
+	}
+	s := n.subscribers[i]
+	n.subscribers = append(n.subscribers[:i:i], n.subscribers[i+1:]...)
+	close(s.removed)
+	if !n.closed {
+		close(s.events)
+	}
+	kept := n.deadLetters[:0]
+	for _, deadLetter := range n.deadLetters {
+		if deadLetter.Subscription != id {
+			kept = append(kept, deadLetter)
+		}
+	}
+	n.deadLetters = kept
+	return true
+}
+
+// get returns the subscription identified by id
+func (n *notifier) get(id string) (types.Subscription, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if i := n.index(id); i >= 0 {
+		return n.subscribers[i].subscription, true
+	}
+	return types.Subscription{}, false
+}
+
+// list returns the subscriptions in the order they were registered
+func (n *notifier) list() []types.Subscription {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	subscriptions := make([]types.Subscription, len(n.subscribers))
+	for i, s := range n.subscribers {
+		subscriptions[i] = s.subscription
+	}
+	return subscriptions
+}
+
+// deadLettersOf returns the dead letters of the subscription, oldest first
+func (n *notifier) deadLettersOf(id string) []types.DeadLetter {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	deadLetters := []types.DeadLetter{}
+	for _, deadLetter := range n.deadLetters {
+		if deadLetter.Subscription == id {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	return deadLetters
+}
+
+// index returns the position of the subscription identified by id, or -1. Must be called holding the lock
+func (n *notifier) index(id string) int {
+	for i, s := range n.subscribers {
+		if s.subscription.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// publish queues the delivery of the event to every subscription whose filter matches it. It never blocks: when the
+// queue of a subscription is full, the event becomes one of its dead letters
+func (n *notifier) publish(event types.RecordEvent) {
+	verb := batchVerbs[event.Op]
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	for _, s := range n.subscribers {
+		subscription := s.subscription
+		if !subscription.Filter.Matches(verb, event.Name, event.Type) {
+			continue
+		}
+		if n.policy != nil && subscription.Owner != "" && n.policy.Authorize(subscription.Owner, VerbList, event.Name, event.Type) != nil {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			n.addDeadLetter(subscription.ID, event, 0, "the delivery queue is full")
+		}
+	}
+}
+
+// close stops accepting events and waits for the queued ones. Deliveries are no longer retried, so events failing
+// their next attempt become dead letters. ctx bounds how long it waits
+func (n *notifier) close(ctx context.Context) error {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.stop)
+		for _, s := range n.subscribers {
+			close(s.events)
+		}
+	}
+	n.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		n.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the pending deliveries: %w", ctx.Err())
+	}
+}
+
+// work delivers the queued events of the subscriber until it is removed or the notifier is closed
+func (n *notifier) work(s *subscriber) {
+	defer n.workers.Done()
+	for event := range s.events {
+		select {
+		case <-s.removed:
+			return
+		default:
+		}
+		n.deliver(s, event)
+	}
+}
+
+// deliver sends the event to the subscription until it is accepted, the attempts are exhausted or the subscription
+// is removed
+func (n *notifier) deliver(s *subscriber, event types.RecordEvent) {
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err := n.send(s.subscription, event)
+		if err == nil {
+			return
+		}
+		if attempt >= n.attempts || !n.wait(s, backoff) {
+			select {
+			case <-s.removed:
+				return
+			default:
+			}
+			logrus.Errorf("Error delivering the event '%s' to the subscription '%s' after %d attempts: %v", event.ID, s.subscription.ID, attempt, err)
+			n.mu.Lock()
+			n.addDeadLetter(s.subscription.ID, event, attempt, err.Error())
+			n.mu.Unlock()
+			return
+		}
+		logrus.Warnf("Error delivering the event '%s' to the subscription '%s', retrying in %v: %v", event.ID, s.subscription.ID, backoff, err)
+		if backoff *= 2; backoff > maxDeliveryBackoff {
+			backoff = maxDeliveryBackoff
+		}
+	}
+}
+
+// wait waits for the delay, unless the notifier is closed or the subscriber removed first. Tells if the delay elapsed
+func (n *notifier) wait(s *subscriber, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-n.stop:
+		return false
+	case <-s.removed:
+		return false
+	}
+}
+
+// send POSTs the event to the subscription URL, signed with its secret. Any response other than 2xx is a failure
+func (n *notifier) send(subscription types.Subscription, event types.RecordEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	nonce, err := newID()
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "bindman-dns-webhook")
+	req.Header.Set(types.HeaderKeyID, subscription.ID)
+	req.Header.Set(types.HeaderTimestamp, timestamp)
+	req.Header.Set(types.HeaderNonce, nonce)
+	req.Header.Set(types.HeaderSignature, types.Sign([]byte(subscription.Secret), req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+
+	start := time.Now()
+	resp, err := n.client.Do(req)
+	if err != nil {
+		n.metrics.ObserveDelivery(subscription.ID, "error", time.Since(start), true)
+		return err
+	}
+	resp.Body.Close()
+	failed := resp.StatusCode < 200 || resp.StatusCode > 299
+	n.metrics.ObserveDelivery(subscription.ID, strconv.Itoa(resp.StatusCode), time.Since(start), failed)
+	if failed {
+		return fmt.Errorf("the subscriber answered %s", resp.Status)
+	}
+	return nil
+}
+
+// addDeadLetter keeps the event that could not be delivered to the subscription. Must be called holding the lock
+func (n *notifier) addDeadLetter(id string, event types.RecordEvent, attempts int, reason string) {
+	n.metrics.IncDeadLetters(id)
+	n.deadLetters = append(n.deadLetters, types.DeadLetter{Subscription: id, Event: event, Attempts: attempts,
+		Error: reason, Time: time.Now().UTC()})
+	if len(n.deadLetters) > MaxDeadLetters {
+		n.deadLetters = n.deadLetters[len(n.deadLetters)-MaxDeadLetters:]
+	}
+}
@@ -0,0 +1,163 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/hook/metrics"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// subscriberMock records the notifications it receives, answering with the given statuses in turn and then with 200
+type subscriberMock struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	events   []types.RecordEvent
+	requests int
+}
+
+func (s *subscriberMock) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	if !types.VerifyNotification([]byte("secret"), r, body) || r.Header.Get(types.HeaderKeyID) != "s1" {
+		s.t.Errorf("invalid signature on %v", r.Header)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.requests <= len(s.statuses) {
+		w.WriteHeader(s.statuses[s.requests-1])
+		return
+	}
+	var event types.RecordEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		s.t.Error(err)
+	}
+	s.events = append(s.events, event)
+}
+
+func (s *subscriberMock) received() ([]types.RecordEvent, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]types.RecordEvent{}, s.events...), s.requests
+}
+
+func Test_notifier_deliver(t *testing.T) {
+	tests := []struct {
+		name            string
+		statuses        []int
+		wantRequests    int
+		wantDelivered   int
+		wantDeadLetters int
+	}{
+		{"delivered", nil, 1, 1, 0},
+		{"retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, 1, 0},
+		{"dead letter", []int{500, 500, 500}, 3, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriber := &subscriberMock{t: t, statuses: tt.statuses}
+			server := httptest.NewServer(subscriber)
+			defer server.Close()
+			n := newNotifier([]types.Subscription{{ID: "s1", URL: server.URL + "/dns?team=a", Secret: "secret"}},
+				3, time.Millisecond, nil, metrics.New("1"))
+			defer n.close(context.Background())
+
+			before := &types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}
+			after := &types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.2"}
+			n.publish(types.RecordEvent{ID: "e-1", Op: types.BatchUpdate, Name: "a.test.com", Type: "A", Before: before, Record: after})
+
+			waitFor(t, func() bool {
+				_, requests := subscriber.received()
+				return requests == tt.wantRequests && len(n.deadLettersOf("s1")) == tt.wantDeadLetters
+			})
+			events, _ := subscriber.received()
+			if len(events) != tt.wantDelivered {
+				t.Fatalf("want %d events, got %+v", tt.wantDelivered, events)
+			}
+			if len(events) > 0 && (events[0].ID != "e-1" || events[0].Before.Value != "10.0.0.1" || events[0].Record.Value != "10.0.0.2") {
+				t.Errorf("unexpected event %+v", events[0])
+			}
+			if deadLetters := n.deadLettersOf("s1"); len(deadLetters) > 0 && (deadLetters[0].Attempts != 3 || deadLetters[0].Event.ID != "e-1") {
+				t.Errorf("unexpected dead letter %+v", deadLetters[0])
+			}
+		})
+	}
+}
+
+func Test_notifier_failingSubscriber(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	subscriber := &subscriberMock{t: t}
+	healthy := httptest.NewServer(subscriber)
+	defer healthy.Close()
+	// the failing subscription waits a minute between attempts, so its events must not hold the healthy ones
+	n := newNotifier([]types.Subscription{
+		{ID: "s0", URL: failing.URL, Secret: "secret"},
+		{ID: "s1", URL: healthy.URL, Secret: "secret"},
+	}, 3, time.Minute, nil, metrics.New("1"))
+
+	for i := 0; i < 20; i++ {
+		n.publish(types.RecordEvent{ID: fmt.Sprintf("e-%d", i), Op: types.BatchAdd, Name: "www.test.com", Type: "A"})
+	}
+	waitFor(t, func() bool {
+		events, _ := subscriber.received()
+		return len(events) == 20
+	})
+	if deadLetters := n.deadLettersOf("s1"); len(deadLetters) != 0 {
+		t.Errorf("expected no dead letters of the healthy subscription, got %+v", deadLetters)
+	}
+	if err := n.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if deadLetters := n.deadLettersOf("s0"); len(deadLetters) != 20 {
+		t.Errorf("expected the events of the failing subscription as dead letters on close, got %d", len(deadLetters))
+	}
+}
+
+func Test_notifier_publish(t *testing.T) {
+	subscriber := &subscriberMock{t: t}
+	server := httptest.NewServer(subscriber)
+	defer server.Close()
+	policy, err := NewPolicy(Rule{Callers: []string{"team-a"}, NameSuffixes: []string{"a.test.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := newNotifier([]types.Subscription{
+		{ID: "s1", URL: server.URL, Secret: "secret", Owner: "team-a"},
+		{ID: "s2", URL: server.URL, Secret: "secret", Filter: types.SubscriptionFilter{Verbs: []string{VerbRemove}}},
+	}, 1, time.Millisecond, policy, metrics.New("1"))
+
+	n.publish(types.RecordEvent{ID: "e-1", Op: types.BatchAdd, Name: "www.a.test.com", Type: "A"})
+	n.publish(types.RecordEvent{ID: "e-2", Op: types.BatchAdd, Name: "www.b.test.com", Type: "A"})
+	if err := n.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if events, _ := subscriber.received(); len(events) != 1 || events[0].ID != "e-1" {
+		t.Errorf("expected only the event the owner may list, got %+v", events)
+	}
+	n.publish(types.RecordEvent{ID: "e-3", Op: types.BatchAdd, Name: "www.a.test.com", Type: "A"})
+	if _, requests := subscriber.received(); requests != 1 {
+		t.Errorf("expected no delivery once closed")
+	}
+}
+
+// waitFor waits for the condition to hold, failing the test after some seconds
+func waitFor(t *testing.T, condition func() bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
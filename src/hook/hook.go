@@ -1,116 +1,368 @@
 package hook
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 
 	"github.com/gorilla/mux"
-	"github.com/labbsr0x/bindman-dns-webhook/src/hook/metrics"
 	"github.com/labbsr0x/bindman-dns-webhook/src/types"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
+// UpsertParam is the query parameter asking POST and PUT requests on /records to add the record when missing or to
+// update it when existing, e.g. "/records?upsert=true"
+const UpsertParam = "upsert"
+
 // DNSWebhook defines the basic structure of a DNS Webhook
 type DNSWebhook struct {
 
-	// DNSManager defines the dnsmanager object this webhook will call
+	// DNSManager defines the dnsmanager object this webhook will call. When it implements types.ContextDNSManager, the
+	// context-aware operations are called with the context of the requests
 	DNSManager types.DNSManager
-}
 
-// Initialize starts up a dns manager webhook
-func Initialize(manager types.DNSManager, serviceVersion string) {
-	if manager == nil {
-		panic(errors.New("A non-nil DNSManager is required to initialize the hook"))
-	}
-	hook := &DNSWebhook{manager}
+	// Policy defines what each caller may do. Every operation is allowed when nil
+	Policy *Policy
 
-	prometheus := metrics.New(serviceVersion)
+	// TTL defines the default TTL and the range of TTLs accepted on added and updated records
+	TTL TTLBounds
 
-	router := mux.NewRouter()
-	router.Handle(prometheus.HandleFunc("/records", hook.GetDNSRecords)).Methods("GET")
-	router.HandleFunc(prometheus.HandleFunc("/records/{name}/{type}", hook.GetDNSRecord)).Methods("GET")
-	router.HandleFunc(prometheus.HandleFunc("/records/{name}/{type}", hook.RemoveDNSRecord)).Methods("DELETE")
-	router.HandleFunc(prometheus.HandleFunc("/records", hook.AddDNSRecord)).Methods("POST")
-	router.HandleFunc(prometheus.HandleFunc("/records", hook.UpdateDNSRecord)).Methods("PUT")
+	// Names normalizes the names and types of the requests before they reach the DNSManager
+	Names types.NameNormalizer
 
-	// exposes /metrics endpoint with standard golang metrics used by prometheus
-	router.Handle("/metrics", promhttp.Handler())
+	// locks serializes the changes made to each name and type
+	locks keyLocks
 
-	logrus.Info("Initialized DNS Manager Webhook")
-	err := http.ListenAndServe("0.0.0.0:7070", router)
+	// changes applies the changes asked to be answered asynchronously, nil when disabled, see WithAsyncChanges
+	changes *changeQueue
+
+	// events streams the changes of the records to the watchers of /records/watch
+	events *eventBroker
+
+	// subscriptions delivers the changes of the records to the subscriptions of /subscriptions
+	subscriptions *notifier
+}
+
+// Initialize starts up a dns manager webhook configured by the options, listening on DefaultAddress by default. It
+// blocks until the server stops and returns the reason it stopped; a SIGINT or SIGTERM gracefully shuts the webhook
+// down and makes it return nil
+func Initialize(manager types.DNSManager, serviceVersion string, options ...Option) error {
+	server, err := New(manager, serviceVersion, options...)
 	if err != nil {
-		logrus.Errorf("Error initializing the DNS Manager Webhook: %v", err)
+		return err
 	}
+	ctx, cancel := signalContext(syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	return server.Run(ctx)
 }
 
-// GetDNSRecords lists the registered DNS Records
+// signalContext returns a context that is canceled when the process receives one of the given signals
+func signalContext(signals ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+	go func() {
+		select {
+		case sig := <-ch:
+			logrus.Infof("Received signal %v", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+	}()
+	return ctx, cancel
+}
+
+// GetDNSRecords lists the registered DNS Records. The query parameters filter the records, see types.RecordFilter, sort
+// them and split them in pages of limit records, the X-Next-Cursor and Link headers pointing to the next page
 func (m *DNSWebhook) GetDNSRecords(w http.ResponseWriter, r *http.Request) {
-	defer handleError(w)
+	errorHandler(m.getDNSRecords).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getDNSRecords(w http.ResponseWriter, r *http.Request) error {
 	logrus.Infof("GetDNSRecords call. Http Request: %v", r)
 
-	resp, err := m.DNSManager.GetDNSRecords()
-	types.PanicIfError(err)
-	writeJSONResponse(resp, http.StatusOK, w)
+	if err := m.authorize(r, VerbList, "", ""); err != nil {
+		return err
+	}
+	query, err := parseListQuery(r)
+	if err != nil {
+		return err
+	}
+	resp, err := m.findRecords(r.Context(), query.filter)
+	if err != nil {
+		return err
+	}
+	if m.Policy != nil {
+		resp = m.Policy.Filter(CallerFromContext(r.Context()), VerbList, resp)
+	}
+	page, next := query.page(resp)
+	if next != "" {
+		setNextPage(w, r, next)
+	}
+	return writeJSONResponse(syncValues(page), http.StatusOK, w)
 }
 
-// GetDNSRecord gets a specific DNS Record. DNS Record name and type comes from url params
+// GetDNSRecord gets a specific DNS Record. DNS Record name and type comes from url params. The ETag header of the
+// response identifies the content of the record, answering 304 to requests whose If-None-Match header matches it
 func (m *DNSWebhook) GetDNSRecord(w http.ResponseWriter, r *http.Request) {
-	defer handleError(w)
-	logrus.Infof("GetDNSRecord call. Http Request: %v", r)
+	errorHandler(m.getDNSRecord).ServeHTTP(w, r)
+}
 
-	vars := mux.Vars(r)
+func (m *DNSWebhook) getDNSRecord(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetDNSRecord call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
 
-	resp, err := m.DNSManager.GetDNSRecord(vars["name"], vars["type"])
-	types.PanicIfError(err)
-	writeJSONResponse(resp, http.StatusOK, w)
+	if err := m.authorize(r, VerbGet, name, recordType); err != nil {
+		return err
+	}
+	resp, err := m.existingRecord(r.Context(), name, recordType)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return recordNotFound(name, recordType)
+	}
+	etag := resp.ETag()
+	w.Header().Set("ETag", etag)
+	if notModified(r, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+	record := *resp
+	record.SyncValue()
+	record.SyncUnicodeName()
+	return writeJSONResponse(record, http.StatusOK, w)
 }
 
-// RemoveDNSRecord removes a dns record identified by its name
+// RemoveDNSRecord removes a dns record identified by its name. The If-Match and If-None-Match headers make the
+// removal conditional on the ETag of the current record
 func (m *DNSWebhook) RemoveDNSRecord(w http.ResponseWriter, r *http.Request) {
-	defer handleError(w)
-	logrus.Infof("RemoveDNSRecord call. Http Request: %v", r)
-	vars := mux.Vars(r)
+	errorHandler(m.removeDNSRecord).ServeHTTP(w, r)
+}
 
-	err := m.DNSManager.RemoveDNSRecord(vars["name"], vars["type"])
-	types.PanicIfError(err)
+func (m *DNSWebhook) removeDNSRecord(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("RemoveDNSRecord call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
 
-	w.WriteHeader(http.StatusNoContent)
+	if err := m.authorize(r, VerbRemove, name, recordType); err != nil {
+		return err
+	}
+	return m.applyChange(w, r, types.BatchDelete, name, recordType, func(w http.ResponseWriter, r *http.Request) error {
+		unlock := m.locks.lock(name, recordType)
+		defer unlock()
+		existing, err := m.existingRecord(r.Context(), name, recordType)
+		if err != nil {
+			return err
+		}
+		if err := checkPreconditions(w, r, existing); err != nil {
+			return err
+		}
+		if existing == nil {
+			return recordNotFound(name, recordType)
+		}
+		if err := m.manager().RemoveDNSRecordContext(r.Context(), name, recordType); err != nil {
+			return err
+		}
+		m.notify(types.RecordEvent{Op: types.BatchDelete, Name: name, Type: recordType, Before: existing})
+		return nil
+	})
 }
 
-// AddDNSRecord handles a POST request
+// AddDNSRecord handles a POST request, adding a record. Adding an existing record is a conflict, unless the upsert
+// query parameter is set, see UpsertParam
 // Expects a DNSRecord object as a body payload
 func (m *DNSWebhook) AddDNSRecord(w http.ResponseWriter, r *http.Request) {
-	defer handleError(w)
+	errorHandler(m.addDNSRecord).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) addDNSRecord(w http.ResponseWriter, r *http.Request) error {
 	logrus.Infof("AddDNSRecord call. Http Request: %v", r)
-	err := m.addOrUpdateDNSRecord(w, r, m.DNSManager.AddDNSRecord)
-	types.PanicIfError(err)
+	return m.addOrUpdateDNSRecord(w, r, VerbAdd)
 }
 
-// UpdateDNSRecord updates a dns record
+// UpdateDNSRecord updates a dns record. Updating a missing record is not found, unless the upsert query parameter is
+// set, see UpsertParam. The If-Match and If-None-Match headers make the update conditional on the ETag of the current
+// record, If-None-Match: * adding the record only when missing
 // Expects a DNSRecord object as a body payload
 func (m *DNSWebhook) UpdateDNSRecord(w http.ResponseWriter, r *http.Request) {
-	defer handleError(w)
+	errorHandler(m.updateDNSRecord).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) updateDNSRecord(w http.ResponseWriter, r *http.Request) error {
 	logrus.Infof("UpdateDNSRecord call. Http Request: %v", r)
-	err := m.addOrUpdateDNSRecord(w, r, m.DNSManager.UpdateDNSRecord)
-	types.PanicIfError(err)
+	return m.addOrUpdateDNSRecord(w, r, VerbUpdate)
 }
 
-// actOrUpdateDNSRecord
-func (m *DNSWebhook) addOrUpdateDNSRecord(w http.ResponseWriter, r *http.Request, do func(record types.DNSRecord) error) error {
+// addOrUpdateDNSRecord validates the record of the request body and adds it, when verb is VerbAdd, or updates it,
+// when verb is VerbUpdate. Whether the record exists is checked with the manager while holding its lock, so the
+// semantics hold even for managers that upsert on both operations
+func (m *DNSWebhook) addOrUpdateDNSRecord(w http.ResponseWriter, r *http.Request, verb string) error {
+	upsert, err := upsertRequested(r)
+	if err != nil {
+		return err
+	}
+	if createOnly(r) {
+		// If-None-Match: * fails on existing records, so the request can only add the record
+		verb, upsert = VerbAdd, false
+	}
 	var record types.DNSRecord
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&record); err != nil {
 		return types.BadRequestError("Invalid request body. You must pass a JSON formatted record on request body", err)
 	}
-	if errs := record.Check(); errs != nil {
-		return types.BadRequestError("Invalid request body. You must pass a JSON formatted record on request body", nil, errs...)
+	record = m.Names.Record(record)
+	record.SyncValue()
+	if errs := record.FieldErrors(); errs != nil {
+		return types.ValidationError("Invalid request body. You must pass a JSON formatted record on request body", errs)
 	}
-	// call to BL provider
-	if err := do(record); err != nil {
+	if err := m.authorize(r, verb, record.Name, record.Type); err != nil {
 		return err
 	}
-	w.WriteHeader(http.StatusNoContent)
-	return nil
+	if upsert {
+		// both verbs are required up front, so denials do not tell whether the record exists
+		if err := m.authorize(r, otherVerb(verb), record.Name, record.Type); err != nil {
+			return err
+		}
+	}
+	if err := m.TTL.apply(&record); err != nil {
+		return err
+	}
+	op := types.BatchUpdate
+	if verb == VerbAdd {
+		op = types.BatchAdd
+	}
+	return m.applyChange(w, r, op, record.Name, record.Type, func(w http.ResponseWriter, r *http.Request) error {
+		unlock := m.locks.lock(record.Name, record.Type)
+		defer unlock()
+		existing, err := m.existingRecord(r.Context(), record.Name, record.Type)
+		if err != nil {
+			return err
+		}
+		if err := checkPreconditions(w, r, existing); err != nil {
+			return err
+		}
+		switch {
+		case verb == VerbAdd && existing != nil && !upsert:
+			return m.recordConflict(r.Context(), *existing)
+		case verb == VerbUpdate && existing == nil && !upsert:
+			return recordNotFound(record.Name, record.Type)
+		}
+
+		if existing != nil {
+			if err := m.checkSingleValue(r.Context(), record.Name, record.Type); err != nil {
+				return err
+			}
+		}
+
+		// call to BL provider
+		op := types.BatchUpdate
+		if existing == nil {
+			op, err = types.BatchAdd, m.manager().AddDNSRecordContext(r.Context(), record)
+		} else {
+			err = m.manager().UpdateDNSRecordContext(r.Context(), record)
+		}
+		if err != nil {
+			return err
+		}
+		m.notify(types.RecordEvent{Op: op, Name: record.Name, Type: record.Type, Record: &record, Before: existing})
+		return nil
+	})
+}
+
+// upsertRequested tells if the request asks to add the record when missing or to update it when existing, see
+// UpsertParam
+func upsertRequested(r *http.Request) (bool, error) {
+	value := r.URL.Query().Get(UpsertParam)
+	if value == "" {
+		return false, nil
+	}
+	upsert, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, types.BadRequestError(fmt.Sprintf("Invalid value of the query parameter '%s'", UpsertParam), err,
+			fmt.Sprintf("the value '%s' is not a boolean", value))
+	}
+	return upsert, nil
+}
+
+// otherVerb returns VerbUpdate for VerbAdd and the other way around
+func otherVerb(verb string) string {
+	if verb == VerbAdd {
+		return VerbUpdate
+	}
+	return VerbAdd
+}
+
+// existingRecord returns the record identified by name and type, or nil when the manager does not find it, either
+// returning a nil record or types.ErrNotFound
+func (m *DNSWebhook) existingRecord(ctx context.Context, name, recordType string) (*types.DNSRecord, error) {
+	record, err := m.manager().GetDNSRecordContext(ctx, name, recordType)
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+	return record, err
+}
+
+// recordConflict returns the error of adding a record that already exists. The existing record is part of the error
+// only when the policy, if any, allows the caller to get it
+func (m *DNSWebhook) recordConflict(ctx context.Context, existing types.DNSRecord) error {
+	return types.ConflictError("Record already exists", m.visibleRecord(ctx, existing), types.ErrConflict,
+		fmt.Sprintf("there is already a record '%s' of type '%s'", existing.Name, existing.Type))
+}
+
+// visibleRecord returns a copy of the record to be shown to the caller, or nil when the policy, if any, does not allow
+// the caller to get it
+func (m *DNSWebhook) visibleRecord(ctx context.Context, record types.DNSRecord) *types.DNSRecord {
+	if m.Policy != nil && m.Policy.Authorize(CallerFromContext(ctx), VerbGet, record.Name, record.Type) != nil {
+		return nil
+	}
+	record.SyncValue()
+	record.SyncUnicodeName()
+	return &record
+}
+
+// recordNotFound returns the error of a missing record
+func recordNotFound(name, recordType string) error {
+	return types.NotFoundError("Record not found", types.ErrNotFound, fmt.Sprintf("there is no record '%s' of type '%s'", name, recordType)).
+		WithErrorCode(types.CodeRecordNotFound)
+}
+
+// manager returns the context-aware view of the DNSManager, see types.ContextAdapter
+func (m *DNSWebhook) manager() types.ContextDNSManager {
+	return types.ContextAdapter(m.DNSManager)
+}
+
+// pathVars returns the canonical name and type of the record identified by the url params
+func (m *DNSWebhook) pathVars(r *http.Request) (string, string) {
+	vars := mux.Vars(r)
+	return m.Names.Name(vars["name"]), m.Names.Type(vars["type"])
+}
+
+// authorize checks the policy, if any, allows the caller of the request to perform the verb over the record
+func (m *DNSWebhook) authorize(r *http.Request, verb, name, recordType string) error {
+	if m.Policy == nil {
+		return nil
+	}
+	return m.Policy.Authorize(CallerFromContext(r.Context()), verb, name, recordType)
+}
+
+// syncValues returns a copy of the records with their values and structured fields consistent, and the Unicode form of
+// internationalized names
+func syncValues(records []types.DNSRecord) []types.DNSRecord {
+	if records == nil {
+		return nil
+	}
+	synced := make([]types.DNSRecord, len(records))
+	for i, record := range records {
+		record.SyncValue()
+		record.SyncUnicodeName()
+		synced[i] = record
+	}
+	return synced
 }
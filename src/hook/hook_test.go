@@ -2,35 +2,37 @@ package hook
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/gorilla/mux"
-	"github.com/labbsr0x/bindman-dns-webhook/src/types"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
 )
 
-var records = []types.DNSRecord{{"test.com.br", "127.0.0.1", "A"}}
+var records = []types.DNSRecord{{Name: "test.com.br", Value: "127.0.0.1", Type: "A"}}
 
 func TestInitialize(t *testing.T) {
 	t.Run("initialize the hook with a nil DNSManager", func(t *testing.T) {
-		defer func() {
-			err := recover()
-			if err == nil {
-				t.Error("A panic must occur when a nil DNSManager is passed to Initialize function")
-			}
-		}()
-		Initialize(nil, "1")
+		if err := Initialize(nil, "1"); err == nil {
+			t.Error("An error must be returned when a nil DNSManager is passed to Initialize function")
+		}
 	})
 }
 
 func TestDNSRecordsHandlers(t *testing.T) {
 	var (
 		errorBadRequest       = &types.Error{Message: "test message", Code: http.StatusBadRequest}
-		hookSuccess           = &DNSWebhook{&SuccessDNSManagerMock{records}}
-		hookError             = &DNSWebhook{&ErrorDNSManagerMock{errorBadRequest}}
+		hookSuccess           = &DNSWebhook{DNSManager: &SuccessDNSManagerMock{records}}
+		hookError             = &DNSWebhook{DNSManager: &ErrorDNSManagerMock{errorBadRequest}}
 		invalidRequestBodyMsg = "Invalid request body. You must pass a JSON formatted record on request body"
+		newRecord             = types.DNSRecord{Name: "new.test.com.br", Value: "127.0.0.2", Type: "A"}
 	)
 	type expected struct {
 		code int
@@ -108,7 +110,7 @@ func TestDNSRecordsHandlers(t *testing.T) {
 			req{body: types.DNSRecord{Name: "test.com.br"}},
 			"",
 			hookError.UpdateDNSRecord,
-			expected{http.StatusBadRequest, types.BadRequestError(invalidRequestBodyMsg, nil, (&types.DNSRecord{Name: "test.com.br"}).Check()...)},
+			expected{http.StatusBadRequest, types.ValidationError(invalidRequestBodyMsg, (&types.DNSRecord{Name: "test.com.br"}).FieldErrors())},
 		},
 		{"UpdateDNSRecord error invalid content on requestBody",
 			req{body: "invalid format"},
@@ -117,9 +119,39 @@ func TestDNSRecordsHandlers(t *testing.T) {
 			expected{http.StatusBadRequest, types.BadRequestError(invalidRequestBodyMsg, nil)},
 		},
 		{"AddDNSRecord add record",
+			req{body: newRecord},
+			"",
+			hookSuccess.AddDNSRecord,
+			expected{http.StatusNoContent, nil},
+		},
+		{"AddDNSRecord error existing record",
 			req{body: records[0]},
 			"",
 			hookSuccess.AddDNSRecord,
+			expected{http.StatusConflict, types.ConflictError("Record already exists", &records[0], nil, "there is already a record 'test.com.br' of type 'A'")},
+		},
+		{"AddDNSRecord upsert existing record",
+			req{path: "?upsert=true", body: records[0]},
+			"",
+			hookSuccess.AddDNSRecord,
+			expected{http.StatusNoContent, nil},
+		},
+		{"AddDNSRecord error invalid upsert parameter",
+			req{path: "?upsert=maybe", body: records[0]},
+			"",
+			hookSuccess.AddDNSRecord,
+			expected{http.StatusBadRequest, types.BadRequestError("Invalid value of the query parameter 'upsert'", nil, "the value 'maybe' is not a boolean")},
+		},
+		{"UpdateDNSRecord error missing record",
+			req{body: newRecord},
+			"",
+			hookSuccess.UpdateDNSRecord,
+			expected{http.StatusNotFound, types.NotFoundError("Record not found", nil, "there is no record 'new.test.com.br' of type 'A'").WithErrorCode(types.CodeRecordNotFound)},
+		},
+		{"UpdateDNSRecord upsert missing record",
+			req{path: "?upsert=1", body: newRecord},
+			"",
+			hookSuccess.UpdateDNSRecord,
 			expected{http.StatusNoContent, nil},
 		},
 		{"AddDNSRecord error adding record",
@@ -138,7 +170,7 @@ func TestDNSRecordsHandlers(t *testing.T) {
 			req{body: types.DNSRecord{Name: "test.com.br"}},
 			"",
 			hookError.AddDNSRecord,
-			expected{http.StatusBadRequest, types.BadRequestError(invalidRequestBodyMsg, nil, (&types.DNSRecord{Name: "test.com.br"}).Check()...)},
+			expected{http.StatusBadRequest, types.ValidationError(invalidRequestBodyMsg, (&types.DNSRecord{Name: "test.com.br"}).FieldErrors())},
 		},
 		{"AddDNSRecord error invalid content on requestBody",
 			req{body: "invalid format"},
@@ -194,6 +226,175 @@ func TestDNSRecordsHandlers(t *testing.T) {
 
 }
 
+func TestAddDNSRecord_StructuredValue(t *testing.T) {
+	manager := newMemoryDNSManagerMock()
+	hook := &DNSWebhook{DNSManager: manager}
+
+	body := `{"name":"test.com","type":"MX","mx":{"preference":10,"host":"mail.test.com"}}`
+	res := httptest.NewRecorder()
+	hook.AddDNSRecord(res, httptest.NewRequest("POST", "/records", strings.NewReader(body)))
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("want status %d, got %d: %s", http.StatusNoContent, res.Code, res.Body.String())
+	}
+	got := manager.records["test.com/MX"]
+	if got.Value != "10 mail.test.com" || got.MX == nil {
+		t.Errorf("expected the manager to receive both representations, got %+v", got)
+	}
+}
+
+func TestDNSWebhook_Normalization(t *testing.T) {
+	manager := newMemoryDNSManagerMock()
+	server, err := New(manager, "1", WithDefaultZone("Example.com."))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+		code   int
+	}{
+		{"POST", "/records", `{"name":"App","type":"a","value":"10.0.0.1"}`, http.StatusNoContent},
+		{"PUT", "/records", `{"name":"APP.example.com.","type":"A","value":"10.0.0.2"}`, http.StatusNoContent},
+		{"GET", "/records/app.EXAMPLE.com./a", "", http.StatusOK},
+		{"DELETE", "/records/App/A", "", http.StatusNoContent},
+	}
+	for _, req := range requests {
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest(req.method, req.path, strings.NewReader(req.body)))
+		if res.Code != req.code {
+			t.Fatalf("%s %s: want status %d, got %d: %s", req.method, req.path, req.code, res.Code, res.Body.String())
+		}
+		if req.method == "PUT" {
+			if got := manager.records["app.example.com/A"]; got.Value != "10.0.0.2" || len(manager.records) != 1 {
+				t.Errorf("expected a single canonical record, got %+v", manager.records)
+			}
+		}
+	}
+	if len(manager.records) != 0 {
+		t.Errorf("expected the record to be removed, got %+v", manager.records)
+	}
+}
+
+func TestDNSWebhook_InternationalizedNames(t *testing.T) {
+	manager := newMemoryDNSManagerMock()
+	server, err := New(manager, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("POST", "/records", strings.NewReader(`{"name":"Café.test.com","type":"A","value":"10.0.0.1"}`)))
+	if res.Code != http.StatusNoContent {
+		t.Fatalf("want status %d, got %d: %s", http.StatusNoContent, res.Code, res.Body.String())
+	}
+	if _, ok := manager.records["xn--caf-dma.test.com/A"]; !ok {
+		t.Fatalf("expected the manager to receive the ASCII name, got %+v", manager.records)
+	}
+
+	res = httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/records/caf%C3%A9.test.com/A", nil))
+	var record types.DNSRecord
+	if err := json.Unmarshal(res.Body.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Name != "xn--caf-dma.test.com" || record.UnicodeName != "café.test.com" {
+		t.Errorf("expected both forms of the name, got %+v", record)
+	}
+
+	res = httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("POST", "/records", strings.NewReader(`{"name":"pаypal.com","type":"A","value":"10.0.0.1"}`)))
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("want status %d for a mixed-script name, got %d", http.StatusBadRequest, res.Code)
+	}
+}
+
+func TestDNSWebhook_NotFound(t *testing.T) {
+	managers := []struct {
+		name    string
+		manager types.DNSManager
+	}{
+		{"manager returning nil records", newMemoryDNSManagerMock()},
+		{"manager returning ErrNotFound", &notFoundDNSManagerMock{newMemoryDNSManagerMock()}},
+	}
+	requests := []struct {
+		method string
+		path   string
+	}{
+		{"GET", "/records/missing.test.com/A"},
+		{"DELETE", "/records/missing.test.com/A"},
+		{"GET", "/rrsets/missing.test.com/A"},
+		{"DELETE", "/rrsets/missing.test.com/A"},
+	}
+	for _, m := range managers {
+		server, err := New(m.manager, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range requests {
+			t.Run(m.name+" "+r.method+" "+r.path, func(t *testing.T) {
+				res := httptest.NewRecorder()
+				server.Handler().ServeHTTP(res, httptest.NewRequest(r.method, r.path, nil))
+				if res.Code != http.StatusNotFound {
+					t.Fatalf("want status %d, got %d: %s", http.StatusNotFound, res.Code, res.Body.String())
+				}
+				var e types.Error
+				if err := json.Unmarshal(res.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+				if !errors.Is(&e, types.ErrNotFound) {
+					t.Errorf("expected a not found error, got %+v", e)
+				}
+			})
+		}
+	}
+}
+
+func TestDNSWebhook_RequestContext(t *testing.T) {
+	manager := &contextDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(records...)}
+	hook := &DNSWebhook{DNSManager: manager}
+	type key struct{}
+
+	req := httptest.NewRequest("GET", "/records", nil)
+	req = req.WithContext(context.WithValue(req.Context(), key{}, "trace"))
+	hook.GetDNSRecords(httptest.NewRecorder(), req)
+	if manager.ctx == nil || manager.ctx.Value(key{}) != "trace" {
+		t.Errorf("expected the manager to receive the request context, got %v", manager.ctx)
+	}
+}
+
+// contextDNSManagerMock records the context given to the context-aware operations
+type contextDNSManagerMock struct {
+	*memoryDNSManagerMock
+	ctx context.Context
+}
+
+func (m *contextDNSManagerMock) GetDNSRecordsContext(ctx context.Context) ([]types.DNSRecord, error) {
+	m.ctx = ctx
+	return m.GetDNSRecords()
+}
+
+func (m *contextDNSManagerMock) GetDNSRecordContext(ctx context.Context, name, recordType string) (*types.DNSRecord, error) {
+	m.ctx = ctx
+	return m.GetDNSRecord(name, recordType)
+}
+
+func (m *contextDNSManagerMock) RemoveDNSRecordContext(ctx context.Context, name, recordType string) error {
+	m.ctx = ctx
+	return m.RemoveDNSRecord(name, recordType)
+}
+
+func (m *contextDNSManagerMock) AddDNSRecordContext(ctx context.Context, record types.DNSRecord) error {
+	m.ctx = ctx
+	return m.AddDNSRecord(record)
+}
+
+func (m *contextDNSManagerMock) UpdateDNSRecordContext(ctx context.Context, record types.DNSRecord) error {
+	m.ctx = ctx
+	return m.UpdateDNSRecord(record)
+}
+
 type SuccessDNSManagerMock struct {
 	records []types.DNSRecord
 }
@@ -207,7 +408,7 @@ func (m *SuccessDNSManagerMock) GetDNSRecord(name, recordType string) (*types.DN
 	if name == record.Name && recordType == record.Type {
 		return &record, nil
 	}
-	return nil, types.InternalServerError(fmt.Sprintf("expected name = %s and type %s on path parameter, got name = %s and type %s", record.Name, record.Type, name, recordType), nil)
+	return nil, nil
 }
 
 func (m *SuccessDNSManagerMock) RemoveDNSRecord(name, recordType string) error {
@@ -245,3 +446,67 @@ func (m *ErrorDNSManagerMock) AddDNSRecord(record types.DNSRecord) error {
 func (m *ErrorDNSManagerMock) UpdateDNSRecord(record types.DNSRecord) error {
 	return m.error
 }
+
+// notFoundDNSManagerMock returns types.ErrNotFound for missing records
+type notFoundDNSManagerMock struct {
+	*memoryDNSManagerMock
+}
+
+func (m *notFoundDNSManagerMock) GetDNSRecord(name, recordType string) (*types.DNSRecord, error) {
+	record, err := m.memoryDNSManagerMock.GetDNSRecord(name, recordType)
+	if err == nil && record == nil {
+		return nil, fmt.Errorf("getting '%s': %w", name, types.ErrNotFound)
+	}
+	return record, err
+}
+
+// memoryDNSManagerMock keeps the records in memory, identified by name and type
+type memoryDNSManagerMock struct {
+	mu      sync.Mutex
+	records map[string]types.DNSRecord
+}
+
+func newMemoryDNSManagerMock(records ...types.DNSRecord) *memoryDNSManagerMock {
+	m := &memoryDNSManagerMock{records: map[string]types.DNSRecord{}}
+	for _, record := range records {
+		m.records[record.Name+"/"+record.Type] = record
+	}
+	return m
+}
+
+func (m *memoryDNSManagerMock) GetDNSRecords() ([]types.DNSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []types.DNSRecord{}
+	for _, record := range m.records {
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+func (m *memoryDNSManagerMock) GetDNSRecord(name, recordType string) (*types.DNSRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if record, ok := m.records[name+"/"+recordType]; ok {
+		return &record, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryDNSManagerMock) RemoveDNSRecord(name, recordType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, name+"/"+recordType)
+	return nil
+}
+
+func (m *memoryDNSManagerMock) AddDNSRecord(record types.DNSRecord) error {
+	return m.UpdateDNSRecord(record)
+}
+
+func (m *memoryDNSManagerMock) UpdateDNSRecord(record types.DNSRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[record.Name+"/"+record.Type] = record
+	return nil
+}
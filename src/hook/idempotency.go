@@ -0,0 +1,223 @@
+package hook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const (
+	// DefaultIdempotencyWindow is how long the responses of idempotent requests are replayed when no other window is
+	// configured
+	DefaultIdempotencyWindow = 24 * time.Hour
+
+	// MaxIdempotencyKeys is how many responses of idempotent requests are kept. The oldest ones are forgotten first
+	MaxIdempotencyKeys = 10000
+
+	// maxIdempotencyKeyLength is the longest idempotency key accepted
+	maxIdempotencyKeyLength = 255
+
+	// maxIdempotentBodySize bounds the size of the bodies of idempotent requests, which are read to fingerprint them
+	maxIdempotentBodySize = 1 << 20
+
+	// inProgressRetryAfter is how many seconds the retries of a request still being served are asked to wait
+	inProgressRetryAfter = "1"
+)
+
+// idempotencyStore keeps the responses of the requests carrying an Idempotency-Key header, replaying them to the
+// retries of the requests. Keys are scoped by caller
+type idempotencyStore struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+	order   []*idempotentResponse
+}
+
+// idempotentResponse is the response of an idempotent request, or its reservation while the request is served
+type idempotentResponse struct {
+	key         string
+	fingerprint string
+	expires     time.Time
+
+	done   bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// newIdempotencyStore builds a store replaying the responses for the window
+func newIdempotencyStore(window time.Duration) *idempotencyStore {
+	return &idempotencyStore{window: window, entries: map[string]*idempotentResponse{}}
+}
+
+// wrap makes the handler honor the Idempotency-Key header. The first response of a key is stored and replayed to the
+// later requests with the same key, method, url and body, without calling the handler again. Requests reusing a key
+// with a different content are answered with 422 Unprocessable Entity, and those arriving while the first one is being
+// served with 409 Conflict and a Retry-After header. Server errors and 429 responses are not stored, so retries are
+// served again. Bodies are limited to maxIdempotentBodySize
+func (s *idempotencyStore) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(types.HeaderIdempotencyKey)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, r, types.BadRequestError(fmt.Sprintf("Invalid %s header", types.HeaderIdempotencyKey), nil,
+				fmt.Sprintf("the key must have at most %d characters", maxIdempotencyKeyLength)))
+			return
+		}
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil && len(body) >= maxIdempotentBodySize {
+			writeError(w, r, &types.Error{Message: "Request body too large", Code: http.StatusRequestEntityTooLarge,
+				ErrorCode: types.CodeBadRequest, Details: []string{fmt.Sprintf("the body must have at most %d bytes", maxIdempotentBodySize)}})
+			return
+		}
+		if err != nil {
+			writeError(w, r, types.BadRequestError("Invalid request body", err))
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		entry, err := s.reserve(CallerFromContext(r.Context())+"\n"+key, fingerprint(r, body))
+		if err != nil {
+			if types.AsError(err).ErrorCode == types.CodeRequestInProgress {
+				w.Header().Set("Retry-After", inProgressRetryAfter)
+			}
+			writeError(w, r, err)
+			return
+		}
+		if entry.done {
+			entry.replay(w)
+			return
+		}
+
+		recorder := &recordingResponseWriter{ResponseWriter: w}
+		stored := false
+		defer func() {
+			// the reservation is released when the handler panics, so retries are served again
+			if !stored {
+				s.release(entry)
+			}
+		}()
+		next(recorder, r)
+		stored = s.store(entry, recorder)
+	}
+}
+
+// reserve returns the stored response of the key, or reserves the key for a new request
+func (s *idempotencyStore) reserve(key, fingerprint string) (*idempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.purge(now)
+
+	if entry, ok := s.entries[key]; ok {
+		if entry.fingerprint != fingerprint {
+			return nil, types.UnprocessableEntityError("The idempotency key was already used by a different request", nil,
+				"the method, url and body of the requests sharing an idempotency key must be the same").
+				WithErrorCode(types.CodeIdempotencyKeyReused)
+		}
+		if !entry.done {
+			return nil, types.ConflictError("A request with the same idempotency key is in progress", nil, nil,
+				"retry once the first request is answered").WithErrorCode(types.CodeRequestInProgress)
+		}
+		return entry, nil
+	}
+	entry := &idempotentResponse{key: key, fingerprint: fingerprint, expires: now.Add(s.window)}
+	s.entries[key] = entry
+	s.order = append(s.order, entry)
+	return entry, nil
+}
+
+// store keeps the recorded response of the reserved entry, returning false when it must not be replayed
+func (s *idempotencyStore) store(entry *idempotentResponse, recorder *recordingResponseWriter) bool {
+	status := recorder.statusCode()
+	if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry.status, entry.header, entry.body = status, recorder.header, recorder.body.Bytes()
+	entry.done = true
+	return true
+}
+
+// release forgets the reserved entry
+func (s *idempotencyStore) release(entry *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[entry.key] == entry {
+		delete(s.entries, entry.key)
+	}
+}
+
+// purge forgets the expired entries and the oldest ones beyond MaxIdempotencyKeys. Must be called holding the lock
+func (s *idempotencyStore) purge(now time.Time) {
+	for len(s.order) > 0 {
+		oldest := s.order[0]
+		if s.entries[oldest.key] == oldest && now.Before(oldest.expires) && len(s.entries) < MaxIdempotencyKeys {
+			return
+		}
+		if s.entries[oldest.key] == oldest {
+			delete(s.entries, oldest.key)
+		}
+		s.order[0] = nil
+		s.order = s.order[1:]
+	}
+}
+
+// replay writes the stored response
+func (e *idempotentResponse) replay(w http.ResponseWriter) {
+	for name, values := range e.header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(types.HeaderIdempotentReplayed, "true")
+	w.WriteHeader(e.status)
+	_, _ = w.Write(e.body)
+}
+
+// fingerprint identifies the content of a request: its method, url and body
+func fingerprint(r *http.Request, body []byte) string {
+	sum := sha256.Sum256([]byte(r.Method + "\n" + r.URL.RequestURI() + "\n" + string(body)))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordingResponseWriter keeps a copy of the response it writes
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status, w.header = statusCode, w.Header().Clone()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// statusCode returns the status of the response, 200 when nothing was written
+func (w *recordingResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,125 @@
+package hook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestServer_IdempotentRequests(t *testing.T) {
+	record := `{"name":"a.test.com","type":"A","value":"10.0.0.1"}`
+	type request struct {
+		key          string
+		body         string
+		wantCode     int
+		wantReplayed bool
+	}
+	tests := []struct {
+		name     string
+		options  []Option
+		failOn   string
+		requests []request
+	}{
+		{"replayed", nil, "", []request{
+			{"k1", record, http.StatusNoContent, false},
+			{"k1", record, http.StatusNoContent, true},
+			{"k2", record, http.StatusConflict, false},
+			{"k2", record, http.StatusConflict, true},
+		}},
+		{"key reused", nil, "", []request{
+			{"k1", record, http.StatusNoContent, false},
+			{"k1", strings.Replace(record, "10.0.0.1", "10.0.0.2", 1), http.StatusUnprocessableEntity, false},
+		}},
+		{"server errors not stored", nil, "a.test.com", []request{
+			{"k1", record, http.StatusServiceUnavailable, false},
+			{"k1", record, http.StatusServiceUnavailable, false},
+		}},
+		{"without key", nil, "", []request{
+			{"", record, http.StatusNoContent, false},
+			{"", record, http.StatusConflict, false},
+		}},
+		{"disabled", []Option{WithIdempotencyWindow(0)}, "", []request{
+			{"k1", record, http.StatusNoContent, false},
+			{"k1", record, http.StatusConflict, false},
+		}},
+		{"key too long", nil, "", []request{
+			{strings.Repeat("k", 256), record, http.StatusBadRequest, false},
+		}},
+		{"body too large", nil, "", []request{
+			{"k1", strings.Repeat(" ", maxIdempotentBodySize) + record, http.StatusRequestEntityTooLarge, false},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &failingDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(), failOn: tt.failOn}
+			server, err := New(manager, "1", tt.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, request := range tt.requests {
+				req := httptest.NewRequest("POST", "/records", strings.NewReader(request.body))
+				if request.key != "" {
+					req.Header.Set(types.HeaderIdempotencyKey, request.key)
+				}
+				res := httptest.NewRecorder()
+				server.Handler().ServeHTTP(res, req)
+				if res.Code != request.wantCode {
+					t.Errorf("request %d: want status %d, got %d: %s", i, request.wantCode, res.Code, res.Body.String())
+				}
+				if replayed := res.Header().Get(types.HeaderIdempotentReplayed) == "true"; replayed != request.wantReplayed {
+					t.Errorf("request %d: want replayed %v, got %v", i, request.wantReplayed, replayed)
+				}
+			}
+		})
+	}
+}
+
+func Test_idempotencyStore(t *testing.T) {
+	s := newIdempotencyStore(time.Hour)
+	entry, err := s.reserve("k", "f")
+	if err != nil || entry.done {
+		t.Fatalf("expected the key to be reserved, got %+v (%v)", entry, err)
+	}
+	if _, err := s.reserve("k", "f"); types.AsError(err).ErrorCode != types.CodeRequestInProgress {
+		t.Errorf("expected the request to be in progress, got %v", err)
+	}
+	s.release(entry)
+	if entry, err = s.reserve("k", "f"); err != nil || entry.done {
+		t.Errorf("expected the released key to be reserved again, got %+v (%v)", entry, err)
+	}
+
+	body := `{"name":"a.test.com","type":"A","value":"10.0.0.1"}`
+	req := httptest.NewRequest("POST", "/records", strings.NewReader(body))
+	req.Header.Set(types.HeaderIdempotencyKey, "in-progress")
+	if _, err := s.reserve("\nin-progress", fingerprint(req, []byte(body))); err != nil {
+		t.Fatal(err)
+	}
+	res := httptest.NewRecorder()
+	s.wrap(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected the request in progress not to be served again")
+	})(res, req)
+	if res.Code != http.StatusConflict || res.Header().Get("Retry-After") != inProgressRetryAfter {
+		t.Errorf("expected a 409 with Retry-After, got %d %v", res.Code, res.Header())
+	}
+
+	expiring := newIdempotencyStore(time.Millisecond)
+	entry, _ = expiring.reserve("k", "f")
+	expiring.store(entry, &recordingResponseWriter{status: http.StatusNoContent})
+	time.Sleep(5 * time.Millisecond)
+	if entry, err = expiring.reserve("k", "g"); err != nil || entry.done {
+		t.Errorf("expected the expired key to be reserved again, got %+v (%v)", entry, err)
+	}
+
+	full := newIdempotencyStore(time.Hour)
+	for i := 0; i <= MaxIdempotencyKeys; i++ {
+		_, _ = full.reserve(fmt.Sprint(i), "f")
+	}
+	if len(full.entries) != MaxIdempotencyKeys {
+		t.Errorf("want %d keys kept, got %d", MaxIdempotencyKeys, len(full.entries))
+	}
+}
@@ -0,0 +1,287 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// pointerReplacer unescapes the reference tokens of JSON pointers, as defined by RFC 6901
+var pointerReplacer = strings.NewReplacer("~1", "/", "~0", "~")
+
+// jsonPatch is a parsed JSON Patch, as defined by RFC 6902
+type jsonPatch []jsonPatchOperation
+
+// jsonPatchOperation is an operation with its pointers split into reference tokens and its value decoded
+type jsonPatchOperation struct {
+	types.PatchOperation
+	path  []string
+	from  []string
+	value interface{}
+}
+
+// parseJSONPatch checks the operations are well formed
+func parseJSONPatch(operations []types.PatchOperation) (jsonPatch, error) {
+	patch := make(jsonPatch, len(operations))
+	for i, operation := range operations {
+		var err error
+		patch[i].PatchOperation = operation
+		switch operation.Op {
+		case "add", "replace", "test":
+			if len(operation.Value) == 0 {
+				return nil, fmt.Errorf("operation %d: the %s operation requires a value", i, operation.Op)
+			}
+			if err = json.Unmarshal(operation.Value, &patch[i].value); err != nil {
+				return nil, fmt.Errorf("operation %d: invalid value: %v", i, err)
+			}
+		case "remove":
+		case "move", "copy":
+			if patch[i].from, err = parsePointer(operation.From); err != nil {
+				return nil, fmt.Errorf("operation %d: %v", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unknown operation '%s'", i, operation.Op)
+		}
+		if patch[i].path, err = parsePointer(operation.Path); err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		if operation.Op == "move" && isPrefix(patch[i].from, patch[i].path) && len(patch[i].from) < len(patch[i].path) {
+			return nil, fmt.Errorf("operation %d: cannot move '%s' into one of its children", i, operation.From)
+		}
+	}
+	return patch, nil
+}
+
+// apply applies the operations in order to the document, the decoded form of a JSON value. Nothing is applied unless
+// every operation succeeds
+func (patch jsonPatch) apply(doc interface{}) (interface{}, error) {
+	doc, err := deepCopy(doc)
+	if err != nil {
+		return nil, err
+	}
+	for i, operation := range patch {
+		if doc, err = operation.apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s '%s'): %v", i, operation.Op, operation.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+func (operation jsonPatchOperation) apply(doc interface{}) (interface{}, error) {
+	switch operation.Op {
+	case "add":
+		return addValue(doc, operation.path, operation.value)
+	case "remove":
+		doc, _, err := removeValue(doc, operation.path)
+		return doc, err
+	case "replace":
+		doc, _, err := removeValue(doc, operation.path)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, operation.path, operation.value)
+	case "move":
+		doc, value, err := removeValue(doc, operation.from)
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, operation.path, value)
+	case "copy":
+		value, err := getValue(doc, operation.from)
+		if err != nil {
+			return nil, err
+		}
+		if value, err = deepCopy(value); err != nil {
+			return nil, err
+		}
+		return addValue(doc, operation.path, value)
+	default: // test
+		value, err := getValue(doc, operation.path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(value, operation.value) {
+			return nil, fmt.Errorf("the value is %v, not %v", value, operation.value)
+		}
+		return doc, nil
+	}
+}
+
+// parsePointer splits a JSON pointer into its unescaped reference tokens. The empty pointer refers to the whole document
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = pointerReplacer.Replace(token)
+	}
+	return tokens, nil
+}
+
+// getValue returns the value the tokens refer to
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("the member '%s' does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("cannot refer to '%s' on a value that is neither an object nor an array", token)
+		}
+	}
+	return doc, nil
+}
+
+// addValue adds the value at the location the tokens refer to, replacing the member of an object or inserting the
+// element of an array, and returns the resulting document
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	token, last := tokens[0], len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if last {
+			node[token] = value
+			return node, nil
+		}
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("the member '%s' does not exist", token)
+		}
+		child, err := addValue(child, tokens[1:], value)
+		node[token] = child
+		return node, err
+	case []interface{}:
+		if last {
+			i := len(node)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(node)); err != nil {
+					return nil, err
+				}
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[i], err = addValue(node[i], tokens[1:], value)
+		return node, err
+	default:
+		return nil, fmt.Errorf("cannot add '%s' to a value that is neither an object nor an array", token)
+	}
+}
+
+// removeValue removes the value the tokens refer to, returning the resulting document and the removed value
+func removeValue(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the whole document")
+	}
+	token, last := tokens[0], len(tokens) == 1
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("the member '%s' does not exist", token)
+		}
+		if last {
+			delete(node, token)
+			return node, child, nil
+		}
+		child, removed, err := removeValue(child, tokens[1:])
+		node[token] = child
+		return node, removed, err
+	case []interface{}:
+		i, err := arrayIndex(token, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+		child, removed, err := removeValue(node[i], tokens[1:])
+		node[i] = child
+		return node, removed, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove '%s' from a value that is neither an object nor an array", token)
+	}
+}
+
+// arrayIndex parses the token as an array index between 0 and max
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("the array index %d is out of bounds", i)
+	}
+	return i, nil
+}
+
+// isPrefix tells if the tokens of prefix start the tokens of path
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// mergePatch applies a JSON Merge Patch, as defined by RFC 7396, to the document
+func mergePatch(doc, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	docObject, ok := doc.(map[string]interface{})
+	if !ok {
+		docObject = map[string]interface{}{}
+	}
+	for key, value := range patchObject {
+		if value == nil {
+			delete(docObject, key)
+		} else {
+			docObject[key] = mergePatch(docObject[key], value)
+		}
+	}
+	return docObject
+}
+
+// deepCopy copies a decoded JSON value
+func deepCopy(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var copied interface{}
+	err = json.Unmarshal(data, &copied)
+	return copied, err
+}
@@ -0,0 +1,116 @@
+package hook
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func Test_jsonPatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		want     string
+		parseErr bool
+		applyErr bool
+	}{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`, false, false},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`, false, false},
+		{"append array element", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`, false, false},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`, false, false},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`, false, false},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`, false, false},
+		{"move", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			`{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`, false, false},
+		{"copy", `{"foo":{"bar":1}}`, `[{"op":"copy","from":"/foo","path":"/baz"}]`, `{"foo":{"bar":1},"baz":{"bar":1}}`, false, false},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			`{"baz":"qux","foo":["a",2,"c"]}`, false, false},
+		{"escaped pointer", `{"a/b":1,"m~n":2}`, `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`, `{"a/b":3}`, false, false},
+		{"failed test", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, "", false, true},
+		{"remove missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, "", false, true},
+		{"replace missing member", `{"foo":"bar"}`, `[{"op":"replace","path":"/baz","value":1}]`, "", false, true},
+		{"add to missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, "", false, true},
+		{"index out of bounds", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, "", false, true},
+		{"leading zero index", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, "", false, true},
+		{"unknown operation", `{}`, `[{"op":"merge","path":"/foo"}]`, "", true, false},
+		{"invalid pointer", `{}`, `[{"op":"add","path":"foo","value":1}]`, "", true, false},
+		{"move into a child", `{"foo":{}}`, `[{"op":"move","from":"/foo","path":"/foo/bar"}]`, "", true, false},
+		{"add without a value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz"}]`, "", true, false},
+		{"replace without a value", `{"foo":"bar"}`, `[{"op":"replace","path":"/foo"}]`, "", true, false},
+		{"test without a value", `{"foo":null}`, `[{"op":"test","path":"/foo"}]`, "", true, false},
+		{"add a null value", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":null}]`, `{"baz":null,"foo":"bar"}`, false, false},
+		{"test a null value", `{"foo":null}`, `[{"op":"test","path":"/foo","value":null}]`, `{"foo":null}`, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var doc interface{}
+			var operations []types.PatchOperation
+			if err := json.Unmarshal([]byte(tt.doc), &doc); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.patch), &operations); err != nil {
+				t.Fatal(err)
+			}
+			patch, err := parseJSONPatch(operations)
+			if (err != nil) != tt.parseErr {
+				t.Fatalf("parseJSONPatch() error = %v, want error %v", err, tt.parseErr)
+			}
+			if err != nil {
+				return
+			}
+			original, _ := deepCopy(doc)
+			got, err := patch.apply(doc)
+			if (err != nil) != tt.applyErr {
+				t.Fatalf("apply() error = %v, want error %v", err, tt.applyErr)
+			}
+			if !reflect.DeepEqual(doc, original) {
+				t.Errorf("apply() must not change the document, got %v", doc)
+			}
+			if err != nil {
+				return
+			}
+			var want interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("apply() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_mergePatch(t *testing.T) {
+	tests := []struct {
+		doc   string
+		patch string
+		want  string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`{"a":"foo"}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.patch, func(t *testing.T) {
+			var doc, patch, want interface{}
+			for _, v := range []struct {
+				data  string
+				value *interface{}
+			}{{tt.doc, &doc}, {tt.patch, &patch}, {tt.want, &want}} {
+				if err := json.Unmarshal([]byte(v.data), v.value); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := mergePatch(doc, patch); !reflect.DeepEqual(got, want) {
+				t.Errorf("mergePatch() = %v, want %v", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package hook
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const (
+	// DefaultPageSize is the number of records of a page when a cursor is given without a limit
+	DefaultPageSize = 100
+
+	// MaxPageSize is the greatest number of records of a page
+	MaxPageSize = 1000
+)
+
+// sortFields are the fields sorting the records
+var sortFields = map[string]bool{"name": true, "type": true, "value": true}
+
+// listQuery is the filter, order and page asked by a listing of records
+type listQuery struct {
+	filter types.RecordFilter
+	sort   string
+	desc   bool
+	limit  int
+	after  *recordCursor
+}
+
+// recordCursor identifies the last record of a page, the next page starting after it
+type recordCursor struct {
+	Sort  string `json:"s"`
+	Name  string `json:"n"`
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// parseListQuery reads the listing asked by the query parameters of the request
+func parseListQuery(r *http.Request) (listQuery, error) {
+	params := r.URL.Query()
+	filter, err := types.ParseRecordFilter(params)
+	if err != nil {
+		return listQuery{}, types.BadRequestError("Invalid filter", err, err.Error()).WithErrorCode(types.CodeInvalidValue)
+	}
+	query := listQuery{filter: filter, sort: params.Get(types.QuerySort)}
+
+	field := strings.TrimPrefix(query.sort, "-")
+	if query.sort != "" && !sortFields[field] {
+		return listQuery{}, invalidParam(types.QuerySort, query.sort, "the records can be sorted by name, type or value")
+	}
+	query.desc = strings.HasPrefix(query.sort, "-")
+
+	if value := params.Get(types.QueryLimit); value != "" {
+		if query.limit, err = strconv.Atoi(value); err != nil || query.limit < 1 || query.limit > MaxPageSize {
+			return listQuery{}, invalidParam(types.QueryLimit, value, fmt.Sprintf("the limit must be between 1 and %d", MaxPageSize))
+		}
+	}
+	if value := params.Get(types.QueryCursor); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil || cursor.Sort != query.sort {
+			return listQuery{}, invalidParam(types.QueryCursor, value, "the cursor must be the one given by the previous page, with the same sort")
+		}
+		query.after = &cursor
+		if query.limit == 0 {
+			query.limit = DefaultPageSize
+		}
+	}
+	return query, nil
+}
+
+// invalidParam returns the error of a query parameter with an invalid value
+func invalidParam(param, value, detail string) error {
+	return types.BadRequestError(fmt.Sprintf("Invalid value of the query parameter '%s'", param), nil, detail).
+		WithErrorCode(types.CodeInvalidValue)
+}
+
+// ordered tells if the records must be sorted, which paginating requires. Otherwise they are kept in the order of
+// the manager
+func (q *listQuery) ordered() bool {
+	return q.sort != "" || q.limit > 0
+}
+
+// page sorts the records, when asked, and returns the page asked by the query along with the cursor of the next page,
+// empty when it is the last one
+func (q *listQuery) page(records []types.DNSRecord) ([]types.DNSRecord, string) {
+	if !q.ordered() {
+		return records, ""
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return q.compare(cursorOf(&records[i]), cursorOf(&records[j])) < 0
+	})
+	if q.after != nil {
+		start := sort.Search(len(records), func(i int) bool {
+			return q.compare(cursorOf(&records[i]), *q.after) > 0
+		})
+		records = records[start:]
+	}
+	if q.limit == 0 || len(records) <= q.limit {
+		return records, ""
+	}
+	records = records[:q.limit]
+	last := cursorOf(&records[len(records)-1])
+	last.Sort = q.sort
+	return records, encodeCursor(last)
+}
+
+// compare compares two records on the order of the query: the sort field, then the name, the type and the value
+func (q *listQuery) compare(a, b recordCursor) int {
+	c := 0
+	switch strings.TrimPrefix(q.sort, "-") {
+	case "type":
+		c = strings.Compare(a.Type, b.Type)
+	case "value":
+		c = strings.Compare(a.Value, b.Value)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Type, b.Type)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Value, b.Value)
+	}
+	if q.desc {
+		return -c
+	}
+	return c
+}
+
+// cursorOf returns the cursor identifying the record: its canonical name and type and its value
+func cursorOf(record *types.DNSRecord) recordCursor {
+	return recordCursor{Name: strings.ToLower(strings.TrimSuffix(record.Name, ".")), Type: strings.ToUpper(record.Type), Value: record.Value}
+}
+
+// encodeCursor returns the opaque form of the cursor given to clients
+func encodeCursor(cursor recordCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor reads a cursor given by encodeCursor
+func decodeCursor(value string) (cursor recordCursor, err error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &cursor)
+	return
+}
+
+// setNextPage sets the headers pointing to the next page of a listing: X-Next-Cursor and Link
+func setNextPage(w http.ResponseWriter, r *http.Request, cursor string) {
+	next := *r.URL
+	params := next.Query()
+	params.Set(types.QueryCursor, cursor)
+	next.RawQuery = params.Encode()
+	w.Header().Set(types.HeaderNextCursor, cursor)
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+}
+
+// findRecords lists the records matching the filter with their values in the presentation format, letting the
+// manager filter them when it implements types.FilteredDNSManager
+func (m *DNSWebhook) findRecords(ctx context.Context, filter types.RecordFilter) ([]types.DNSRecord, error) {
+	var records []types.DNSRecord
+	var err error
+	if manager, ok := m.DNSManager.(types.FilteredDNSManager); ok && !filter.IsZero() {
+		records, err = manager.FindDNSRecords(ctx, filter)
+	} else {
+		records, err = m.manager().GetDNSRecordsContext(ctx)
+	}
+	if err != nil || records == nil {
+		return records, err
+	}
+	found := make([]types.DNSRecord, 0, len(records))
+	for _, record := range records {
+		record.SyncValue()
+		if filter.Matches(record) {
+			found = append(found, record)
+		}
+	}
+	return found, nil
+}
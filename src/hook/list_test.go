@@ -0,0 +1,144 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+var listedRecords = []types.DNSRecord{
+	{Name: "www.example.com", Type: "A", Value: "10.0.0.1"},
+	{Name: "api.example.com", Type: "A", Value: "10.0.1.1"},
+	{Name: "api.example.com", Type: "AAAA", Value: "::1"},
+	{Name: "example.com", Type: "TXT", Value: "v=spf1 -all"},
+	{Name: "www.example.org", Type: "CNAME", Value: "example.org"},
+	{Name: "mail.example.org", Type: "A", Value: "10.0.2.1"},
+}
+
+func TestDNSWebhook_GetDNSRecords_Query(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		want     []string
+	}{
+		{"type", "?type=a,aaaa&sort=name", http.StatusOK, []string{"api.example.com/A", "api.example.com/AAAA", "mail.example.org/A", "www.example.com/A"}},
+		{"name glob", "?name=www.*&sort=name", http.StatusOK, []string{"www.example.com/A", "www.example.org/CNAME"}},
+		{"name prefix and suffix", "?namePrefix=api.&nameSuffix=.example.com.&sort=name", http.StatusOK, []string{"api.example.com/A", "api.example.com/AAAA"}},
+		{"value", "?value=10.0.*&sort=value", http.StatusOK, []string{"www.example.com/A", "api.example.com/A", "mail.example.org/A"}},
+		{"zone", "?zone=Example.com&sort=name", http.StatusOK, []string{"api.example.com/A", "api.example.com/AAAA", "example.com/TXT", "www.example.com/A"}},
+		{"descending type", "?zone=example.org&sort=-type", http.StatusOK, []string{"www.example.org/CNAME", "mail.example.org/A"}},
+		{"invalid sort", "?sort=ttl", http.StatusBadRequest, nil},
+		{"invalid limit", "?limit=0", http.StatusBadRequest, nil},
+		{"invalid cursor", "?cursor=nope", http.StatusBadRequest, nil},
+		{"invalid pattern", "?name=[", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(newMemoryDNSManagerMock(listedRecords...), "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/records"+tt.query, nil))
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			if got := listedKeys(t, res); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDNSWebhook_GetDNSRecords_Pages(t *testing.T) {
+	server, err := New(newMemoryDNSManagerMock(listedRecords...), "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sort := range []string{"", "-name", "value"} {
+		var got []string
+		path := "/records?limit=4&sort=" + sort
+		pages := 0
+		for path != "" {
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest("GET", path, nil))
+			if res.Code != http.StatusOK {
+				t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+			}
+			got = append(got, listedKeys(t, res)...)
+			path = ""
+			if link := res.Header().Get("Link"); link != "" {
+				path = strings.TrimPrefix(strings.Split(link, ">")[0], "<")
+				if !strings.Contains(path, "cursor="+res.Header().Get(types.HeaderNextCursor)) {
+					t.Errorf("expected the link %s to hold the next cursor", link)
+				}
+			}
+			pages++
+		}
+
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/records?sort="+sort+"&limit=1000", nil))
+		if want := listedKeys(t, res); pages != 2 || !reflect.DeepEqual(got, want) {
+			t.Errorf("sort %q: want %v on 2 pages, got %v on %d", sort, want, got, pages)
+		}
+	}
+}
+
+func TestDNSWebhook_GetDNSRecords_FilteredDNSManager(t *testing.T) {
+	manager := &filteredDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(listedRecords...)}
+	server, err := New(manager, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, httptest.NewRequest("GET", "/records?type=A&zone=example.com", nil))
+	want := types.RecordFilter{Types: []string{"A"}, Zone: "example.com"}
+	if !reflect.DeepEqual(manager.filter, want) {
+		t.Errorf("want the filter %+v given to the manager, got %+v", want, manager.filter)
+	}
+	// the manager only applies the type, the webhook applies the zone
+	if got := listedKeys(t, res); len(got) != 2 {
+		t.Errorf("want the records of the zone, got %v", got)
+	}
+}
+
+// listedKeys returns the "name/type" of the listed records
+func listedKeys(t *testing.T, res *httptest.ResponseRecorder) []string {
+	var records []types.DNSRecord
+	if err := json.Unmarshal(res.Body.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, record := range records {
+		keys = append(keys, record.Name+"/"+record.Type)
+	}
+	return keys
+}
+
+// filteredDNSManagerMock filters the records by type only
+type filteredDNSManagerMock struct {
+	*memoryDNSManagerMock
+	filter types.RecordFilter
+}
+
+func (m *filteredDNSManagerMock) FindDNSRecords(ctx context.Context, filter types.RecordFilter) ([]types.DNSRecord, error) {
+	m.filter = filter
+	records, _ := m.GetDNSRecords()
+	var found []types.DNSRecord
+	for _, record := range records {
+		if (&types.RecordFilter{Types: filter.Types}).Matches(record) {
+			found = append(found, record)
+		}
+	}
+	return found, nil
+}
@@ -0,0 +1,82 @@
+package hook
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// keyLocks serializes the changes made to the same name and type, so that read-modify-write operations are atomic.
+// The zero value is ready to use
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+// keyLock is the lock of a single key, released from keyLocks once nobody holds or waits for it
+type keyLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock acquires the lock of the name and type and returns the function releasing it
+func (k *keyLocks) lock(name, recordType string) func() {
+	return k.acquire(lockKey(name, recordType))
+}
+
+// lockAll acquires the locks of the names and types of all the records, always in the same order so concurrent calls
+// do not deadlock, and returns the function releasing them
+func (k *keyLocks) lockAll(records []types.DNSRecord) func() {
+	keys := map[string]bool{}
+	for _, record := range records {
+		keys[lockKey(record.Name, record.Type)] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	unlocks := make([]func(), len(sorted))
+	for i, key := range sorted {
+		unlocks[i] = k.acquire(key)
+	}
+	return func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+}
+
+// lockKey returns the key identifying the name and type
+func lockKey(name, recordType string) string {
+	return strings.ToLower(strings.TrimSuffix(name, ".")) + "/" + strings.ToUpper(recordType)
+}
+
+// acquire acquires the lock of the key and returns the function releasing it
+func (k *keyLocks) acquire(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = map[string]*keyLock{}
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
@@ -26,10 +26,22 @@ func (s *statusCodeResponseWriter) WriteHeader(code int) {
 	s.ResponseWriter.WriteHeader(code)
 }
 
+// Flush sends the buffered data to the client, so streamed responses pass through the middleware
+func (s *statusCodeResponseWriter) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 type Prometheus struct {
 	reqCount    *prometheus.CounterVec
 	reqLatency  *prometheus.HistogramVec
 	reqInFlight *prometheus.GaugeVec
+	panics      *prometheus.CounterVec
+
+	deliveryLatency  *prometheus.HistogramVec
+	deliveryFailures *prometheus.CounterVec
+	deadLetters      *prometheus.CounterVec
 }
 
 func New(serviceVersion string) *Prometheus {
@@ -45,37 +57,93 @@ func New(serviceVersion string) *Prometheus {
 		})
 	buildInfoGauge.Set(1)
 
-	prometheus.MustRegister(buildInfoGauge)
+	mustRegisterOrReuse(buildInfoGauge)
 
 	p := &Prometheus{}
-	p.reqCount = prometheus.NewCounterVec(
+	p.reqCount = mustRegisterOrReuse(prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "http_requests_total",
 			Help: "How many HTTP requests processed, partitioned by status code, method and HTTP path.",
 		},
 		[]string{"code", "method", "path"},
-	)
-	prometheus.MustRegister(p.reqCount)
+	)).(*prometheus.CounterVec)
 
-	p.reqLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	p.reqLatency = mustRegisterOrReuse(prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "http_request_duration_seconds",
 		Help: "How long it took to process the request, partitioned by status code, method and HTTP path.",
 	},
 		[]string{"code", "method", "path"},
-	)
-	prometheus.MustRegister(p.reqLatency)
+	)).(*prometheus.HistogramVec)
 
-	p.reqInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	p.reqInFlight = mustRegisterOrReuse(prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "http_requests_in_flight",
 		Help: "How many requests are being processed, partitioned method and HTTP path.",
 	},
 		[]string{"method", "path"},
-	)
-	prometheus.MustRegister(p.reqInFlight)
+	)).(*prometheus.GaugeVec)
+
+	p.panics = mustRegisterOrReuse(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_handler_panics_total",
+		Help: "How many panics were recovered from the handlers, partitioned by method and HTTP path.",
+	},
+		[]string{"method", "path"},
+	)).(*prometheus.CounterVec)
+
+	p.deliveryLatency = mustRegisterOrReuse(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "subscription_delivery_duration_seconds",
+		Help: "How long each attempt to deliver an event to a subscription took, partitioned by subscription and status code.",
+	},
+		[]string{"subscription", "code"},
+	)).(*prometheus.HistogramVec)
+
+	p.deliveryFailures = mustRegisterOrReuse(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "subscription_delivery_failures_total",
+		Help: "How many attempts to deliver an event to a subscription failed, partitioned by subscription and status code.",
+	},
+		[]string{"subscription", "code"},
+	)).(*prometheus.CounterVec)
+
+	p.deadLetters = mustRegisterOrReuse(prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "subscription_dead_letters_total",
+		Help: "How many events could not be delivered to a subscription on any attempt, partitioned by subscription.",
+	},
+		[]string{"subscription"},
+	)).(*prometheus.CounterVec)
 
 	return p
 }
 
+// mustRegisterOrReuse registers the collector on the default registry. When an identical collector is already registered,
+// e.g. because more than one webhook runs in the same process, the registered one is returned so metrics are shared
+func mustRegisterOrReuse(c prometheus.Collector) prometheus.Collector {
+	if err := prometheus.Register(c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return c
+}
+
+// IncPanics counts a panic recovered from the handler of the method and path
+func (p *Prometheus) IncPanics(method, path string) {
+	p.panics.WithLabelValues(method, path).Inc()
+}
+
+// ObserveDelivery records an attempt to deliver an event to the subscription. code is the status code of the response,
+// or "error" when none was received
+func (p *Prometheus) ObserveDelivery(subscription, code string, duration time.Duration, failed bool) {
+	p.deliveryLatency.WithLabelValues(subscription, code).Observe(duration.Seconds())
+	if failed {
+		p.deliveryFailures.WithLabelValues(subscription, code).Inc()
+	}
+}
+
+// IncDeadLetters counts an event that could not be delivered to the subscription
+func (p *Prometheus) IncDeadLetters(subscription string) {
+	p.deadLetters.WithLabelValues(subscription).Inc()
+}
+
 func (p *Prometheus) HandleFunc(path string, next http.HandlerFunc) (string, http.HandlerFunc) {
 	return path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		responseWriter := newLoggingResponseWriter(w)
@@ -5,8 +5,10 @@ import (
 	"net/http/httptest"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func Test_newLoggingResponseWriter(t *testing.T) {
@@ -78,6 +80,9 @@ func TestNew(t *testing.T) {
 	if newMetrics.reqInFlight == nil {
 		t.Fatalf("expected reqInFlight to be already instantiated")
 	}
+	if newMetrics.panics == nil {
+		t.Fatalf("expected panics to be already instantiated")
+	}
 
 	registerValidation := func(c prometheus.Collector, t *testing.T) {
 		err := prometheus.Register(c)
@@ -92,6 +97,41 @@ func TestNew(t *testing.T) {
 	registerValidation(newMetrics.reqCount, t)
 	registerValidation(newMetrics.reqLatency, t)
 	registerValidation(newMetrics.reqInFlight, t)
+	registerValidation(newMetrics.panics, t)
+}
+
+func TestPrometheus_IncPanics(t *testing.T) {
+	resetRegistry()
+	p := New("1")
+	p.IncPanics("GET", "/records")
+	p.IncPanics("GET", "/records")
+	if got := testutil.ToFloat64(p.panics.WithLabelValues("GET", "/records")); got != 2 {
+		t.Errorf("expected 2 panics, got %v", got)
+	}
+}
+
+func TestPrometheus_ObserveDelivery(t *testing.T) {
+	resetRegistry()
+	p := New("1")
+	p.ObserveDelivery("s1", "200", time.Millisecond, false)
+	p.ObserveDelivery("s1", "500", time.Millisecond, true)
+	p.ObserveDelivery("s1", "error", time.Millisecond, true)
+	p.IncDeadLetters("s1")
+	if got := testutil.ToFloat64(p.deliveryFailures.WithLabelValues("s1", "500")); got != 1 {
+		t.Errorf("expected 1 failure, got %v", got)
+	}
+	if got := testutil.ToFloat64(p.deadLetters.WithLabelValues("s1")); got != 1 {
+		t.Errorf("expected 1 dead letter, got %v", got)
+	}
+}
+
+func TestNew_Twice(t *testing.T) {
+	resetRegistry()
+	first := New("1")
+	second := New("1")
+	if first.reqCount != second.reqCount || first.reqLatency != second.reqLatency || first.reqInFlight != second.reqInFlight {
+		t.Fatal("expected the collectors already registered to be reused")
+	}
 }
 
 func TestNew_ValidateArgs(t *testing.T) {
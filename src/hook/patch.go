@@ -0,0 +1,157 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+// PatchDNSRecord changes some fields of a DNS record, like its TTL or value. Its name and type come from url params
+// Expects a JSON Merge Patch (application/merge-patch+json) or a JSON Patch (application/json-patch+json) as a body
+// payload and responds with the resulting record. The If-Match and If-None-Match headers make the change conditional
+func (m *DNSWebhook) PatchDNSRecord(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.patchDNSRecord).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) patchDNSRecord(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("PatchDNSRecord call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
+
+	apply, err := decodePatch(r)
+	if err != nil {
+		return err
+	}
+	if err := m.authorize(r, VerbUpdate, name, recordType); err != nil {
+		return err
+	}
+
+	unlock := m.locks.lock(name, recordType)
+	defer unlock()
+	existing, err := m.existingRecord(r.Context(), name, recordType)
+	if err != nil {
+		return err
+	}
+	if err := checkPreconditions(w, r, existing); err != nil {
+		return err
+	}
+	if existing == nil {
+		return recordNotFound(name, recordType)
+	}
+	if err := m.checkSingleValue(r.Context(), name, recordType); err != nil {
+		return err
+	}
+	current := *existing
+	current.SyncValue()
+
+	record, err := patchRecord(current, apply, m.visibleRecord(r.Context(), current))
+	if err != nil {
+		return err
+	}
+	if record.Name != current.Name || record.Type != current.Type {
+		// only the names written by the patch are normalized, as the stored one may be out of the default zone
+		if patched := m.Names.Record(record); patched.Name != name || patched.Type != recordType {
+			return types.BadRequestError("Invalid patch. The name and type of a record cannot be changed", nil)
+		}
+	}
+	record.Name, record.Type = current.Name, current.Type
+	record.SyncValue()
+	if errs := record.FieldErrors(); errs != nil {
+		return types.ValidationError("Invalid patch. The patched record is not valid", errs)
+	}
+	if err := m.TTL.apply(&record); err != nil {
+		return err
+	}
+	if err := m.manager().UpdateDNSRecordContext(r.Context(), record); err != nil {
+		return err
+	}
+	m.notify(types.RecordEvent{Op: types.BatchUpdate, Name: record.Name, Type: record.Type, Record: &record, Before: existing})
+	w.Header().Set("ETag", record.ETag())
+	visible := m.visibleRecord(r.Context(), record)
+	if visible == nil {
+		// the patch may have kept fields the caller is not allowed to read
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return writeJSONResponse(visible, http.StatusOK, w)
+}
+
+// decodePatch decodes the patch of the request body, as given by its content type, returning the function applying it
+// to a record document
+func decodePatch(r *http.Request) (func(doc interface{}) (interface{}, error), error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case types.MergePatchContentType:
+		var patch interface{}
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			return nil, types.BadRequestError("Invalid request body. You must pass a JSON Merge Patch on request body", err)
+		}
+		if _, ok := patch.(map[string]interface{}); !ok {
+			return nil, types.BadRequestError("Invalid request body. The JSON Merge Patch of a record must be an object", nil)
+		}
+		return func(doc interface{}) (interface{}, error) {
+			return mergePatch(doc, patch), nil
+		}, nil
+	case types.JSONPatchContentType:
+		var operations []types.PatchOperation
+		if err := json.NewDecoder(r.Body).Decode(&operations); err != nil {
+			return nil, types.BadRequestError("Invalid request body. You must pass a JSON Patch on request body", err)
+		}
+		patch, err := parseJSONPatch(operations)
+		if err != nil {
+			return nil, types.BadRequestError("Invalid request body. You must pass a JSON Patch on request body", err, err.Error())
+		}
+		return patch.apply, nil
+	default:
+		return nil, &types.Error{
+			Message:   "Unsupported patch format",
+			Code:      http.StatusUnsupportedMediaType,
+			ErrorCode: types.CodeUnsupportedMediaType,
+			Details: []string{fmt.Sprintf("the content type must be either '%s' or '%s', got '%s'",
+				types.MergePatchContentType, types.JSONPatchContentType, r.Header.Get("Content-Type"))},
+		}
+	}
+}
+
+// patchRecord applies the patch to the JSON document of the record. When the patch changes either the value or the
+// structured field, the other one is rendered from it. A patch that cannot be applied is a conflict holding the shown
+// record, if any
+func patchRecord(current types.DNSRecord, apply func(doc interface{}) (interface{}, error), shown *types.DNSRecord) (types.DNSRecord, error) {
+	current.UnicodeName = ""
+	data, err := json.Marshal(current)
+	if err != nil {
+		return types.DNSRecord{}, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return types.DNSRecord{}, err
+	}
+	// the TTL is omitted when zero, but may still be replaced
+	doc["ttl"] = float64(current.TTL)
+
+	patched, err := apply(doc)
+	if err != nil {
+		return types.DNSRecord{}, types.ConflictError("The patch cannot be applied to the record", shown, nil, err.Error())
+	}
+	if data, err = json.Marshal(patched); err != nil {
+		return types.DNSRecord{}, err
+	}
+	var record types.DNSRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return types.DNSRecord{}, types.BadRequestError("Invalid patch. The patched record is not a valid record", err, err.Error())
+	}
+
+	structuredChanged := !reflect.DeepEqual(record.MX, current.MX) || !reflect.DeepEqual(record.SRV, current.SRV) ||
+		!reflect.DeepEqual(record.CAA, current.CAA)
+	switch {
+	case record.Value != current.Value && !structuredChanged:
+		record.MX, record.SRV, record.CAA = nil, nil, nil
+	case record.Value == current.Value && structuredChanged:
+		record.Value = ""
+	}
+	return record, nil
+}
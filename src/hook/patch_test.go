@@ -0,0 +1,137 @@
+package hook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_PatchDNSRecord(t *testing.T) {
+	a := types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.1", TTL: 300}
+	mx := types.DNSRecord{Name: "test.com", Type: "MX", Value: "10 mail.test.com"}
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		want        types.DNSRecord
+	}{
+		{"merge patch ttl", "/records/app.test.com/A", types.MergePatchContentType, `{"ttl":600}`,
+			http.StatusOK, types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.1", TTL: 600}},
+		{"merge patch removing the ttl", "/records/app.test.com/A", types.MergePatchContentType, `{"ttl":null}`,
+			http.StatusOK, types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.1"}},
+		{"json patch value", "/records/app.test.com/A", types.JSONPatchContentType,
+			`[{"op":"test","path":"/value","value":"10.0.0.1"},{"op":"replace","path":"/value","value":"10.0.0.2"}]`,
+			http.StatusOK, types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.2", TTL: 300}},
+		{"json patch structured field", "/records/test.com/MX", types.JSONPatchContentType, `[{"op":"replace","path":"/mx/preference","value":20}]`,
+			http.StatusOK, types.DNSRecord{Name: "test.com", Type: "MX", Value: "20 mail.test.com", MX: &types.MXData{Preference: 20, Host: "mail.test.com"}}},
+		{"merge patch value of structured record", "/records/test.com/MX", types.MergePatchContentType, `{"value":"5 mx.test.com"}`,
+			http.StatusOK, types.DNSRecord{Name: "test.com", Type: "MX", Value: "5 mx.test.com", MX: &types.MXData{Preference: 5, Host: "mx.test.com"}}},
+		{"failed test", "/records/app.test.com/A", types.JSONPatchContentType, `[{"op":"test","path":"/value","value":"10.0.0.9"}]`,
+			http.StatusConflict, a},
+		{"invalid patched record", "/records/app.test.com/A", types.MergePatchContentType, `{"value":"::1"}`,
+			http.StatusBadRequest, a},
+		{"changing the name", "/records/app.test.com/A", types.MergePatchContentType, `{"name":"other.test.com"}`,
+			http.StatusBadRequest, a},
+		{"malformed json patch", "/records/app.test.com/A", types.JSONPatchContentType, `[{"op":"merge","path":"/value"}]`,
+			http.StatusBadRequest, a},
+		{"unsupported content type", "/records/app.test.com/A", "application/json", `{"ttl":600}`,
+			http.StatusUnsupportedMediaType, a},
+		{"missing record", "/records/missing.test.com/A", types.MergePatchContentType, `{"ttl":600}`,
+			http.StatusNotFound, a},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newMemoryDNSManagerMock(a, mx)
+			server, err := New(manager, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("PATCH", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			key := tt.want.Name + "/" + tt.want.Type
+			if got := manager.records[key]; got.Value != tt.want.Value || got.TTL != tt.want.TTL {
+				t.Errorf("want the manager to hold %+v, got %+v", tt.want, got)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var got types.DNSRecord
+			if err := json.Unmarshal(res.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Value != tt.want.Value || got.TTL != tt.want.TTL || (tt.want.MX != nil && *got.MX != *tt.want.MX) {
+				t.Errorf("want the response %+v, got %+v", tt.want, got)
+			}
+			if etag := res.Header().Get("ETag"); etag != tt.want.ETag() {
+				t.Errorf("want ETag %s, got %s", tt.want.ETag(), etag)
+			}
+		})
+	}
+}
+
+func TestDNSWebhook_PatchDNSRecord_DefaultZone(t *testing.T) {
+	manager := newMemoryDNSManagerMock(types.DNSRecord{Name: "app.other.org", Type: "A", Value: "10.0.0.1"})
+	server, err := New(manager, "1", WithDefaultZone("example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("PATCH", "/records/app.other.org./A", strings.NewReader(`{"ttl":600}`))
+	req.Header.Set("Content-Type", types.MergePatchContentType)
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, req)
+	if res.Code != http.StatusOK {
+		t.Fatalf("want status %d, got %d: %s", http.StatusOK, res.Code, res.Body.String())
+	}
+	if got := manager.records["app.other.org/A"]; got.TTL != 600 {
+		t.Errorf("want the record out of the default zone patched, got %+v", got)
+	}
+}
+
+func TestDNSWebhook_PatchDNSRecord_Policy(t *testing.T) {
+	tests := []struct {
+		name        string
+		verbs       []string
+		body        string
+		wantCode    int
+		wantCurrent bool
+	}{
+		{"failed test, caller allowed to get", []string{VerbUpdate, VerbGet}, `[{"op":"test","path":"/value","value":"10.0.0.9"}]`, http.StatusConflict, true},
+		{"failed test, caller not allowed to get", []string{VerbUpdate}, `[{"op":"test","path":"/value","value":"10.0.0.9"}]`, http.StatusConflict, false},
+		{"patched, caller allowed to get", []string{VerbUpdate, VerbGet}, `[{"op":"replace","path":"/ttl","value":600}]`, http.StatusOK, true},
+		{"patched, caller not allowed to get", []string{VerbUpdate}, `[{"op":"replace","path":"/ttl","value":600}]`, http.StatusNoContent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(Rule{Callers: []string{"*"}, Verbs: tt.verbs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := New(newMemoryDNSManagerMock(types.DNSRecord{Name: "app.test.com", Type: "A", Value: "10.0.0.1"}), "1", WithPolicy(policy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest("PATCH", "/records/app.test.com/A", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", types.JSONPatchContentType)
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			if got := strings.Contains(res.Body.String(), `"10.0.0.1"`); got != tt.wantCurrent {
+				t.Errorf("want the record on the response: %v, got %s", tt.wantCurrent, res.Body.String())
+			}
+		})
+	}
+}
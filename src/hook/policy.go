@@ -0,0 +1,207 @@
+package hook
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"gopkg.in/yaml.v2"
+)
+
+// Verbs identifying the operations a policy rule may allow
+const (
+	VerbList   = "list"
+	VerbGet    = "get"
+	VerbAdd    = "add"
+	VerbUpdate = "update"
+	VerbRemove = "remove"
+
+	// VerbSubscribe allows managing the subscriptions notified of the changes of the records
+	VerbSubscribe = "subscribe"
+
+	// VerbAdmin allows managing the subscriptions of every caller. Unlike the other verbs, rules must name it
+	VerbAdmin = "admin"
+)
+
+// anyValue matches any caller, verb or type on a rule
+const anyValue = "*"
+
+// Rule allows callers to perform operations over records. An empty list of names, types or verbs allows all of them
+type Rule struct {
+	// Callers the identities the rule applies to, as given by the authenticators. "*" matches any caller
+	Callers []string `json:"callers" yaml:"callers"`
+
+	// NameSuffixes the zones the record names must belong to. "example.com" matches the zone apex and every name under it,
+	// "*.example.com" matches only the names under it
+	NameSuffixes []string `json:"nameSuffixes" yaml:"nameSuffixes"`
+
+	// NamePatterns regular expressions the whole record names must match, as if enclosed in ^(?:...)$
+	NamePatterns []string `json:"namePatterns" yaml:"namePatterns"`
+
+	// Types the record types allowed, e.g. A and CNAME
+	Types []string `json:"types" yaml:"types"`
+
+	// Verbs the operations allowed: list, get, add, update, remove, subscribe and admin
+	Verbs []string `json:"verbs" yaml:"verbs"`
+
+	patterns []*regexp.Regexp
+}
+
+// Policy defines what each caller may do. A request is authorized when at least one rule allows it
+type Policy struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// NewPolicy validates the rules and builds a Policy from them
+func NewPolicy(rules ...Rule) (*Policy, error) {
+	p := &Policy{Rules: rules}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// LoadPolicy reads a Policy from a YAML or JSON file
+func LoadPolicy(file string) (*Policy, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Policy{}
+	// YAML is a superset of JSON, so both formats are parsed the same way
+	if err := yaml.UnmarshalStrict(data, p); err != nil {
+		return nil, fmt.Errorf("error parsing the policy file '%s': %v", file, err)
+	}
+	if err := p.compile(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compile parses the name patterns of the rules
+func (p *Policy) compile() error {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if len(rule.Callers) == 0 {
+			return fmt.Errorf("rule %d must define at least one caller", i)
+		}
+		rule.patterns = nil
+		for _, pattern := range rule.NamePatterns {
+			// patterns are anchored, so "app\.example\.com" does not match "app.example.com.attacker.net"
+			re, err := regexp.Compile("^(?:" + pattern + ")$")
+			if err != nil {
+				return fmt.Errorf("invalid name pattern on rule %d: %v", i, err)
+			}
+			rule.patterns = append(rule.patterns, re)
+		}
+	}
+	return nil
+}
+
+// Authorize returns a forbidden types.Error unless some rule allows the caller to perform the verb over the record
+// identified by name and type. Listing is authorized when some rule allows the caller to list any record
+func (p *Policy) Authorize(caller, verb, name, recordType string) error {
+	for i := range p.Rules {
+		if p.Rules[i].allows(caller, verb, name, recordType) {
+			return nil
+		}
+	}
+	detail := fmt.Sprintf("no rule allows the caller '%s' to %s records", caller, verb)
+	if verb != VerbList {
+		detail = fmt.Sprintf("no rule allows the caller '%s' to %s the record '%s' of type '%s'", caller, verb, name, recordType)
+	}
+	return types.ForbiddenError("The operation is not allowed by the policy", nil, detail).WithErrorCode(types.CodePolicyDenied)
+}
+
+// IsAdmin tells if some rule names both the caller and the admin verb, which may then manage the subscriptions of
+// every caller. Wildcards and rules without verbs do not grant it
+func (p *Policy) IsAdmin(caller string) bool {
+	if caller == "" {
+		return false
+	}
+	for i := range p.Rules {
+		if containsExactly(p.Rules[i].Callers, caller) && containsExactly(p.Rules[i].Verbs, VerbAdmin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the records the caller is allowed to see with the given verb
+func (p *Policy) Filter(caller, verb string, records []types.DNSRecord) []types.DNSRecord {
+	allowed := make([]types.DNSRecord, 0, len(records))
+	for _, record := range records {
+		if p.Authorize(caller, verb, record.Name, record.Type) == nil {
+			allowed = append(allowed, record)
+		}
+	}
+	return allowed
+}
+
+// allows tells if the rule allows the caller to perform the verb over the record. An empty name and type, as on
+// listings, only checks the caller and the verb
+func (r *Rule) allows(caller, verb, name, recordType string) bool {
+	if !contains(r.Callers, caller, true) || !contains(r.Verbs, verb, false) {
+		return false
+	}
+	if name == "" && recordType == "" {
+		return true
+	}
+	if !contains(r.Types, recordType, false) {
+		return false
+	}
+
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if len(r.NameSuffixes) > 0 {
+		matched := false
+		for _, suffix := range r.NameSuffixes {
+			if matchesSuffix(name, suffix) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, pattern := range r.patterns {
+		if !pattern.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesSuffix tells if name is the zone defined by suffix or belongs to it
+func matchesSuffix(name, suffix string) bool {
+	suffix = strings.TrimSuffix(strings.ToLower(suffix), ".")
+	if strings.HasPrefix(suffix, "*.") {
+		return strings.HasSuffix(name, suffix[1:])
+	}
+	return name == suffix || strings.HasSuffix(name, "."+suffix)
+}
+
+// containsExactly tells if the value is on the list, ignoring the "*" wildcard
+func containsExactly(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// contains tells if the value is on the list. An empty list contains any value, unless a value is required
+func contains(list []string, value string, required bool) bool {
+	if len(list) == 0 {
+		return !required
+	}
+	for _, item := range list {
+		if item == anyValue || strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package hook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+const yamlPolicy = `
+rules:
+  - callers: [team-a]
+    nameSuffixes: ["*.team-a.example.com"]
+    types: [A, CNAME]
+    verbs: [list, get, add, update, remove]
+  - callers: ["*"]
+    nameSuffixes: [public.example.com]
+    namePatterns: ["^[a-z]+\\."]
+    verbs: [get]
+`
+
+const jsonPolicy = `{"rules": [{"callers": ["team-a"], "nameSuffixes": ["*.team-a.example.com"], "types": ["A", "CNAME"], "verbs": ["list", "get", "add", "update", "remove"]},
+{"callers": ["*"], "nameSuffixes": ["public.example.com"], "namePatterns": ["^[a-z]+\\."], "verbs": ["get"]}]}`
+
+func TestLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"yaml policy", write("policy.yaml", yamlPolicy), false},
+		{"json policy", write("policy.json", jsonPolicy), false},
+		{"unknown field", write("unknown.yaml", "rules:\n  - callers: [a]\n    zones: [a]\n"), true},
+		{"rule without callers", write("callers.yaml", "rules:\n  - verbs: [get]\n"), true},
+		{"invalid pattern", write("pattern.yaml", "rules:\n  - callers: [a]\n    namePatterns: ['(']\n"), true},
+		{"missing file", filepath.Join(dir, "missing.yaml"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := LoadPolicy(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (len(p.Rules) != 2 || len(p.Rules[1].patterns) != 1) {
+				t.Errorf("unexpected policy %+v", p)
+			}
+		})
+	}
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	p, err := NewPolicy(
+		Rule{Callers: []string{"team-a"}, NameSuffixes: []string{"*.team-a.example.com"}, Types: []string{"A", "CNAME"}, Verbs: []string{VerbList, VerbGet, VerbAdd, VerbUpdate, VerbRemove}},
+		Rule{Callers: []string{"*"}, NameSuffixes: []string{"public.example.com"}, NamePatterns: []string{`[a-z]+\.public\.example\.com`}, Verbs: []string{VerbGet}},
+		Rule{Callers: []string{"team-c"}, NamePatterns: []string{`app\.example\.com`}, Verbs: []string{VerbGet}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		caller     string
+		verb       string
+		recordName string
+		recordType string
+		want       bool
+	}{
+		{"subdomain of the allowed zone", "team-a", VerbAdd, "app.team-a.example.com", "A", true},
+		{"case and trailing dot", "team-a", VerbRemove, "App.Team-A.example.com.", "cname", true},
+		{"zone apex not allowed by wildcard suffix", "team-a", VerbAdd, "team-a.example.com", "A", false},
+		{"other zone", "team-a", VerbAdd, "app.team-b.example.com", "A", false},
+		{"suffix must match whole labels", "team-a", VerbAdd, "app.xteam-a.example.com", "A", false},
+		{"type not allowed", "team-a", VerbAdd, "app.team-a.example.com", "TXT", false},
+		{"other caller", "team-b", VerbAdd, "app.team-a.example.com", "A", false},
+		{"listing", "team-a", VerbList, "", "", true},
+		{"listing not allowed", "team-b", VerbList, "", "", false},
+		{"apex not matching the pattern", "team-b", VerbGet, "public.example.com", "TXT", false},
+		{"any caller matching pattern", "team-b", VerbGet, "www.public.example.com", "TXT", true},
+		{"verb not allowed", "team-b", VerbRemove, "www.public.example.com", "TXT", false},
+		{"pattern matching the whole name", "team-c", VerbGet, "app.example.com", "A", true},
+		{"pattern matching part of the name", "team-c", VerbGet, "evilapp.example.com.attacker.net", "A", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Authorize(tt.caller, tt.verb, tt.recordName, tt.recordType)
+			if (err == nil) != tt.want {
+				t.Fatalf("Authorize() = %v, want allowed %v", err, tt.want)
+			}
+			if err != nil && err.(*types.Error).Code != http.StatusForbidden {
+				t.Errorf("expected a forbidden error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_IsAdmin(t *testing.T) {
+	p, err := NewPolicy(
+		Rule{Callers: []string{"ops"}, Verbs: []string{VerbSubscribe, VerbAdmin}},
+		Rule{Callers: []string{"team-a"}},
+		Rule{Callers: []string{"*"}, Verbs: []string{VerbSubscribe, VerbAdmin}},
+		Rule{Callers: []string{"team-b"}, Verbs: []string{"*"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsAdmin("ops") {
+		t.Error("expected the caller of the admin verb to be an admin")
+	}
+	if p.IsAdmin("team-a") {
+		t.Error("expected rules without verbs and wildcard callers not to grant the admin verb")
+	}
+	if p.IsAdmin("team-b") {
+		t.Error("expected wildcard verbs not to grant the admin verb")
+	}
+}
+
+func TestServer_Policy(t *testing.T) {
+	managed := []types.DNSRecord{
+		{Name: "app.team-a.example.com", Value: "127.0.0.1", Type: "A"},
+		{Name: "app.team-b.example.com", Value: "127.0.0.1", Type: "A"},
+	}
+	p, err := NewPolicy(Rule{Callers: []string{"team-a"}, NameSuffixes: []string{"*.team-a.example.com"}, Types: []string{"A"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(&SuccessDNSManagerMock{managed}, "1", WithAuthentication(BearerTokens{"token-a": "team-a"}), WithPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, path string, body interface{}) *httptest.ResponseRecorder {
+		var buf bytes.Buffer
+		if body != nil {
+			json.NewEncoder(&buf).Encode(body)
+		}
+		r := httptest.NewRequest(method, path, &buf)
+		r.Header.Set("Authorization", "Bearer token-a")
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, r)
+		return res
+	}
+
+	t.Run("listing only returns allowed records", func(t *testing.T) {
+		res := do("GET", "/records", nil)
+		var got []types.DNSRecord
+		json.NewDecoder(res.Body).Decode(&got)
+		if res.Code != http.StatusOK || !reflect.DeepEqual(got, managed[:1]) {
+			t.Errorf("want %v, got %d %v", managed[:1], res.Code, got)
+		}
+	})
+	t.Run("allowed record", func(t *testing.T) {
+		if res := do("PUT", "/records", managed[0]); res.Code != http.StatusNoContent {
+			t.Errorf("want status %d, got %d", http.StatusNoContent, res.Code)
+		}
+	})
+	t.Run("denied record", func(t *testing.T) {
+		res := do("PUT", "/records", managed[1])
+		var got types.Error
+		json.NewDecoder(res.Body).Decode(&got)
+		if res.Code != http.StatusForbidden || got.Code != http.StatusForbidden || len(got.Details) != 1 {
+			t.Errorf("want a forbidden error with details, got %d %v", res.Code, got)
+		}
+	})
+	t.Run("denied removal", func(t *testing.T) {
+		if res := do("DELETE", "/records/app.team-b.example.com/A", nil); res.Code != http.StatusForbidden {
+			t.Errorf("want status %d, got %d", http.StatusForbidden, res.Code)
+		}
+	})
+}
+
+func TestServer_PolicyUpsert(t *testing.T) {
+	p, err := NewPolicy(Rule{Callers: []string{"*"}, Verbs: []string{VerbGet, VerbUpdate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(&SuccessDNSManagerMock{records}, "1", WithPolicy(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		path string
+		code int
+	}{
+		{"update allowed", "/records", http.StatusNoContent},
+		{"upsert requires adding as well", "/records?upsert=true", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			json.NewEncoder(&buf).Encode(records[0])
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest("PUT", tt.path, &buf))
+			if res.Code != tt.code {
+				t.Errorf("want status %d, got %d: %s", tt.code, res.Code, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestServer_PolicyConflict(t *testing.T) {
+	existing := types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}
+	tests := []struct {
+		name       string
+		verbs      []string
+		wantRecord bool
+	}{
+		{"caller allowed to get", []string{VerbAdd, VerbGet}, true},
+		{"caller not allowed to get", []string{VerbAdd}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(Rule{Callers: []string{"*"}, Verbs: tt.verbs})
+			if err != nil {
+				t.Fatal(err)
+			}
+			server, err := New(newMemoryDNSManagerMock(existing), "1", WithPolicy(p))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range []string{"/records", "/records:batch"} {
+				body := `{"name":"a.test.com","type":"A","value":"10.0.0.2"}`
+				if path == "/records:batch" {
+					body = `{"operations":[{"op":"add","record":` + body + `}]}`
+				}
+				res := httptest.NewRecorder()
+				server.Handler().ServeHTTP(res, httptest.NewRequest("POST", path, strings.NewReader(body)))
+				if res.Code != http.StatusConflict {
+					t.Fatalf("want status %d on %s, got %d: %s", http.StatusConflict, path, res.Code, res.Body.String())
+				}
+				if got := strings.Contains(res.Body.String(), "10.0.0.1"); got != tt.wantRecord {
+					t.Errorf("want the existing record on %s: %v, got %s", path, tt.wantRecord, res.Body.String())
+				}
+			}
+		})
+	}
+}
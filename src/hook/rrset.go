@@ -0,0 +1,308 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+// GetRRSets lists the registered RRSets
+func (m *DNSWebhook) GetRRSets(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getRRSets).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getRRSets(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetRRSets call. Http Request: %v", r)
+
+	if err := m.authorize(r, VerbList, "", ""); err != nil {
+		return err
+	}
+	sets, err := m.rrSets().GetRRSetsContext(r.Context())
+	if err != nil {
+		return err
+	}
+	if m.Policy != nil {
+		caller := CallerFromContext(r.Context())
+		allowed := make([]types.RRSet, 0, len(sets))
+		for _, set := range sets {
+			if m.Policy.Authorize(caller, VerbList, set.Name, set.Type) == nil {
+				allowed = append(allowed, set)
+			}
+		}
+		sets = allowed
+	}
+	return writeJSONResponse(syncUnicodeNames(sets), http.StatusOK, w)
+}
+
+// GetRRSet gets a specific RRSet. Its name and type come from url params
+func (m *DNSWebhook) GetRRSet(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getRRSet).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getRRSet(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetRRSet call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
+
+	if err := m.authorize(r, VerbGet, name, recordType); err != nil {
+		return err
+	}
+	set, err := m.existingRRSet(r.Context(), name, recordType)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		return rrSetNotFound(name, recordType)
+	}
+	synced := *set
+	synced.SyncUnicodeName()
+	return writeJSONResponse(synced, http.StatusOK, w)
+}
+
+// ReplaceRRSet creates a RRSet or replaces all its values. Its name and type come from url params
+// Expects a RRSet object as a body payload
+func (m *DNSWebhook) ReplaceRRSet(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.replaceRRSet).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) replaceRRSet(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("ReplaceRRSet call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
+
+	var set types.RRSet
+	if err := json.NewDecoder(r.Body).Decode(&set); err != nil {
+		return types.BadRequestError("Invalid request body. You must pass a JSON formatted RRSet on request body", err)
+	}
+	set = m.Names.RRSet(set)
+	if (set.Name != "" && set.Name != name) || (set.Type != "" && set.Type != recordType) {
+		return types.BadRequestError("Invalid request body. The RRSet name and type must match the ones of the url", nil)
+	}
+	set.Name, set.Type = name, recordType
+
+	unlock := m.locks.lock(set.Name, set.Type)
+	defer unlock()
+	existing, err := m.existingRRSet(r.Context(), set.Name, set.Type)
+	if err != nil {
+		return err
+	}
+	if err := m.saveRRSet(r, existing, set); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// PatchRRSet adds and removes values of a RRSet atomically, creating it when needed and removing it when no value is
+// left. Its name and type come from url params
+// Expects a RRSetChange object as a body payload and responds with the resulting RRSet
+func (m *DNSWebhook) PatchRRSet(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.patchRRSet).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) patchRRSet(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("PatchRRSet call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
+
+	var change types.RRSetChange
+	if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+		return types.BadRequestError("Invalid request body. You must pass a JSON formatted RRSet change on request body", err)
+	}
+
+	unlock := m.locks.lock(name, recordType)
+	defer unlock()
+	existing, err := m.existingRRSet(r.Context(), name, recordType)
+	if err != nil {
+		return err
+	}
+	current := types.RRSet{Name: name, Type: recordType}
+	if existing != nil {
+		current = *existing
+	}
+
+	set := current.Apply(change)
+	if len(set.Values) == 0 {
+		if existing != nil {
+			if err := m.authorize(r, VerbRemove, set.Name, set.Type); err != nil {
+				return err
+			}
+			if err := m.rrSets().RemoveRRSetContext(r.Context(), set.Name, set.Type); err != nil {
+				return err
+			}
+			m.notify(types.RecordEvent{Op: types.BatchDelete, Name: set.Name, Type: set.Type, RRSetBefore: existing})
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	if err := m.saveRRSet(r, existing, set); err != nil {
+		return err
+	}
+	set.SyncUnicodeName()
+	return writeJSONResponse(set, http.StatusOK, w)
+}
+
+// RemoveRRSet removes a RRSet with all its values. Its name and type come from url params
+func (m *DNSWebhook) RemoveRRSet(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.removeRRSet).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) removeRRSet(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("RemoveRRSet call. Http Request: %v", r)
+	name, recordType := m.pathVars(r)
+
+	if err := m.authorize(r, VerbRemove, name, recordType); err != nil {
+		return err
+	}
+	unlock := m.locks.lock(name, recordType)
+	defer unlock()
+	existing, err := m.existingRRSet(r.Context(), name, recordType)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return rrSetNotFound(name, recordType)
+	}
+	if err := m.rrSets().RemoveRRSetContext(r.Context(), name, recordType); err != nil {
+		return err
+	}
+	m.notify(types.RecordEvent{Op: types.BatchDelete, Name: name, Type: recordType, RRSetBefore: existing})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// saveRRSet validates the set, authorizes adding it or updating the existing one and saves it. Must be called holding
+// the lock of the set
+func (m *DNSWebhook) saveRRSet(r *http.Request, existing *types.RRSet, set types.RRSet) error {
+	if errs := set.FieldErrors(); errs != nil {
+		return types.ValidationError("Invalid request body. You must pass a JSON formatted RRSet on request body", errs)
+	}
+	verb, op := VerbAdd, types.BatchAdd
+	if existing != nil {
+		verb, op = VerbUpdate, types.BatchUpdate
+	}
+	if err := m.authorize(r, verb, set.Name, set.Type); err != nil {
+		return err
+	}
+	if err := m.TTL.applyTo(&set.TTL); err != nil {
+		return err
+	}
+	if err := m.rrSets().ReplaceRRSetContext(r.Context(), set); err != nil {
+		return err
+	}
+	m.notify(types.RecordEvent{Op: op, Name: set.Name, Type: set.Type, RRSet: &set, RRSetBefore: existing})
+	return nil
+}
+
+// rrSets returns the context-aware manager of the RRSets: the DNSManager itself when it implements
+// types.ContextRRSetManager or types.RRSetManager, or a view of its records limited to a single value per name and type
+// otherwise
+func (m *DNSWebhook) rrSets() types.ContextRRSetManager {
+	if manager, ok := m.DNSManager.(types.ContextRRSetManager); ok {
+		return manager
+	}
+	if manager, ok := m.DNSManager.(types.RRSetManager); ok {
+		return types.RRSetContextAdapter(manager)
+	}
+	return singleValueRRSets{m.manager()}
+}
+
+// existingRRSet returns the RRSet identified by name and type, or nil when the manager does not find it, either
+// returning a nil set or types.ErrNotFound
+func (m *DNSWebhook) existingRRSet(ctx context.Context, name, recordType string) (*types.RRSet, error) {
+	set, err := m.rrSets().GetRRSetContext(ctx, name, recordType)
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+	return set, err
+}
+
+// checkSingleValue returns a conflict when the record identified by name and type is a RRSet of several values, which
+// updating it through the single record operations would replace by a single value
+func (m *DNSWebhook) checkSingleValue(ctx context.Context, name, recordType string) error {
+	if _, ok := m.rrSets().(singleValueRRSets); ok {
+		return nil
+	}
+	set, err := m.existingRRSet(ctx, name, recordType)
+	if err != nil || set == nil || len(set.Values) < 2 {
+		return err
+	}
+	return types.ConflictError("The record has several values", nil, types.ErrConflict,
+		fmt.Sprintf("the RRSet '%s' of type '%s' has %d values, which must be changed on /rrsets", name, recordType, len(set.Values)))
+}
+
+// syncUnicodeNames returns a copy of the sets with the Unicode form of internationalized names
+func syncUnicodeNames(sets []types.RRSet) []types.RRSet {
+	if sets == nil {
+		return nil
+	}
+	synced := make([]types.RRSet, len(sets))
+	for i, set := range sets {
+		set.SyncUnicodeName()
+		synced[i] = set
+	}
+	return synced
+}
+
+// rrSetNotFound returns the error of a missing RRSet
+func rrSetNotFound(name, recordType string) error {
+	return types.NotFoundError("RRSet not found", types.ErrNotFound, fmt.Sprintf("there is no RRSet '%s' of type '%s'", name, recordType)).
+		WithErrorCode(types.CodeRRSetNotFound)
+}
+
+// singleValueRRSets exposes the records of a DNSManager that does not support RRSets as sets of a single value
+type singleValueRRSets struct {
+	manager types.ContextDNSManager
+}
+
+// GetRRSetsContext groups the records by name and type
+func (s singleValueRRSets) GetRRSetsContext(ctx context.Context) ([]types.RRSet, error) {
+	records, err := s.manager.GetDNSRecordsContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sets := types.GroupRRSets(syncValues(records))
+	if sets == nil {
+		sets = []types.RRSet{}
+	}
+	return sets, nil
+}
+
+// GetRRSetContext returns the set made of the record identified by name and type
+func (s singleValueRRSets) GetRRSetContext(ctx context.Context, name, recordType string) (*types.RRSet, error) {
+	record, err := s.manager.GetDNSRecordContext(ctx, name, recordType)
+	if errors.Is(err, types.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil || record == nil {
+		return nil, err
+	}
+	synced := *record
+	synced.SyncValue()
+	return &types.RRSet{Name: synced.Name, Type: synced.Type, TTL: synced.TTL, Values: []string{synced.Value}}, nil
+}
+
+// ReplaceRRSetContext adds or updates the record of the set, which must have a single value
+func (s singleValueRRSets) ReplaceRRSetContext(ctx context.Context, set types.RRSet) error {
+	if len(set.Values) != 1 {
+		return types.BadRequestError("The DNS manager supports a single value per name and type", nil,
+			fmt.Sprintf("the RRSet '%s' of type '%s' has %d values", set.Name, set.Type, len(set.Values))).
+			WithErrorCode(types.CodeUnsupportedOperation)
+	}
+	record := set.Records()[0]
+	existing, err := s.manager.GetDNSRecordContext(ctx, set.Name, set.Type)
+	if err != nil && !errors.Is(err, types.ErrNotFound) {
+		return err
+	}
+	if err == nil && existing != nil {
+		return s.manager.UpdateDNSRecordContext(ctx, record)
+	}
+	return s.manager.AddDNSRecordContext(ctx, record)
+}
+
+// RemoveRRSetContext removes the record of the set
+func (s singleValueRRSets) RemoveRRSetContext(ctx context.Context, name, recordType string) error {
+	return s.manager.RemoveDNSRecordContext(ctx, name, recordType)
+}
@@ -0,0 +1,335 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestRRSetHandlers(t *testing.T) {
+	unchanged := &types.RRSet{Name: "a.test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.2"}}
+	tests := []struct {
+		name     string
+		manager  types.DNSManager
+		method   string
+		path     string
+		body     string
+		wantCode int
+		wantSet  *types.RRSet
+	}{
+		{"get a set", newMemoryRRSetManagerMock(), "GET", "/rrsets/a.test.com/A", "", http.StatusOK, unchanged},
+		{"get a missing set", newMemoryRRSetManagerMock(), "GET", "/rrsets/b.test.com/A", "", http.StatusNotFound, nil},
+		{"replace a set", newMemoryRRSetManagerMock(), "PUT", "/rrsets/a.test.com/A", `{"values":["10.0.0.3","10.0.0.4"]}`,
+			http.StatusNoContent, &types.RRSet{Name: "a.test.com", Type: "A", Values: []string{"10.0.0.3", "10.0.0.4"}}},
+		{"replace a set with invalid values", newMemoryRRSetManagerMock(), "PUT", "/rrsets/a.test.com/A", `{"values":["x"]}`,
+			http.StatusBadRequest, unchanged},
+		{"replace a set with a mismatching name", newMemoryRRSetManagerMock(), "PUT", "/rrsets/a.test.com/A",
+			`{"name":"b.test.com","values":["10.0.0.3"]}`, http.StatusBadRequest, unchanged},
+		{"add values", newMemoryRRSetManagerMock(), "PATCH", "/rrsets/a.test.com/A", `{"add":["10.0.0.3"],"remove":["10.0.0.1"]}`,
+			http.StatusOK, &types.RRSet{Name: "a.test.com", Type: "A", Values: []string{"10.0.0.2", "10.0.0.3"}}},
+		{"add values to a missing set", newMemoryRRSetManagerMock(), "PATCH", "/rrsets/b.test.com/A", `{"add":["10.0.0.3"]}`,
+			http.StatusOK, &types.RRSet{Name: "b.test.com", Type: "A", Values: []string{"10.0.0.3"}}},
+		{"remove every value", newMemoryRRSetManagerMock(), "PATCH", "/rrsets/a.test.com/A", `{"remove":["10.0.0.1","10.0.0.2"]}`,
+			http.StatusNoContent, nil},
+		{"remove a set", newMemoryRRSetManagerMock(), "DELETE", "/rrsets/a.test.com/A", "", http.StatusNoContent, nil},
+		{"get a single value set", newMemoryDNSManagerMock(records...), "GET", "/rrsets/test.com.br/A", "",
+			http.StatusOK, &types.RRSet{Name: "test.com.br", Type: "A", Values: []string{"127.0.0.1"}}},
+		{"replace a single value set", newMemoryDNSManagerMock(records...), "PUT", "/rrsets/test.com.br/A", `{"values":["10.0.0.1"]}`,
+			http.StatusNoContent, &types.RRSet{Name: "test.com.br", Type: "A", Values: []string{"10.0.0.1"}}},
+		{"add a value to a single value set", newMemoryDNSManagerMock(records...), "PATCH", "/rrsets/test.com.br/A", `{"add":["10.0.0.1"]}`,
+			http.StatusBadRequest, &types.RRSet{Name: "test.com.br", Type: "A", Values: []string{"127.0.0.1"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := New(tt.manager, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+
+			parts := strings.Split(tt.path, "/")
+			got, err := server.Hook.rrSets().GetRRSetContext(context.Background(), parts[2], parts[3])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.wantSet) {
+				t.Errorf("want the set %+v, got %+v", tt.wantSet, got)
+			}
+			if tt.wantCode == http.StatusOK {
+				var body types.RRSet
+				if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil || !reflect.DeepEqual(&body, tt.wantSet) {
+					t.Errorf("want the response %+v, got %s", tt.wantSet, res.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestPatchRRSet_Concurrent(t *testing.T) {
+	manager := newMemoryRRSetManagerMock()
+	server, err := New(manager, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"add":["10.0.1.%d"]}`, i)
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, httptest.NewRequest("PATCH", "/rrsets/c.test.com/A", strings.NewReader(body)))
+			if res.Code != http.StatusOK {
+				t.Errorf("want status %d, got %d: %s", http.StatusOK, res.Code, res.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	set, _ := manager.GetRRSet("c.test.com", "A")
+	if set == nil || len(set.Values) != 20 {
+		t.Errorf("expected every value to be added, got %+v", set)
+	}
+}
+
+func TestGetRRSets_Policy(t *testing.T) {
+	policy, err := NewPolicy(Rule{Callers: []string{"*"}, NameSuffixes: []string{"a.test.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := newMemoryRRSetManagerMock()
+	manager.sets["b.test.com/A"] = types.RRSet{Name: "b.test.com", Type: "A", Values: []string{"10.0.0.9"}}
+	hook := &DNSWebhook{DNSManager: manager, Policy: policy}
+
+	res := httptest.NewRecorder()
+	hook.GetRRSets(res, httptest.NewRequest("GET", "/rrsets", nil))
+	var sets []types.RRSet
+	if err := json.Unmarshal(res.Body.Bytes(), &sets); err != nil {
+		t.Fatal(err)
+	}
+	if len(sets) != 1 || sets[0].Name != "a.test.com" {
+		t.Errorf("expected only the allowed sets, got %+v", sets)
+	}
+}
+
+func TestRecordHandlers_SeveralValues(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+		setName     string
+		wantValues  []string
+	}{
+		{"update", "PUT", "/records", "", `{"name":"a.test.com","type":"A","value":"10.0.0.3"}`,
+			http.StatusConflict, "a.test.com", []string{"10.0.0.1", "10.0.0.2"}},
+		{"upsert", "POST", "/records?upsert=true", "", `{"name":"a.test.com","type":"A","value":"10.0.0.3"}`,
+			http.StatusConflict, "a.test.com", []string{"10.0.0.1", "10.0.0.2"}},
+		{"patch", "PATCH", "/records/a.test.com/A", types.MergePatchContentType, `{"ttl":600}`,
+			http.StatusConflict, "a.test.com", []string{"10.0.0.1", "10.0.0.2"}},
+		{"batch update", "POST", "/records:batch", "", `{"operations":[{"op":"update","record":{"name":"a.test.com","type":"A","value":"10.0.0.3"}}]}`,
+			http.StatusConflict, "a.test.com", []string{"10.0.0.1", "10.0.0.2"}},
+		{"update a single value set", "PUT", "/records", "", `{"name":"b.test.com","type":"A","value":"10.0.0.3"}`,
+			http.StatusNoContent, "b.test.com", []string{"10.0.0.3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := newMemoryRRSetManagerMock()
+			manager.sets["b.test.com/A"] = types.RRSet{Name: "b.test.com", Type: "A", Values: []string{"10.0.0.1"}}
+			server, err := New(manager, "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			res := httptest.NewRecorder()
+			server.Handler().ServeHTTP(res, req)
+			if res.Code != tt.wantCode {
+				t.Fatalf("want status %d, got %d: %s", tt.wantCode, res.Code, res.Body.String())
+			}
+			if set, _ := manager.GetRRSet(tt.setName, "A"); set == nil || !reflect.DeepEqual(set.Values, tt.wantValues) {
+				t.Errorf("want values %v, got %+v", tt.wantValues, set)
+			}
+		})
+	}
+}
+
+func TestRRSetHandlers_RequestContext(t *testing.T) {
+	single := &contextDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"})}
+	sets := &contextRRSetManagerMock{memoryRRSetManagerMock: newMemoryRRSetManagerMock()}
+	managers := []struct {
+		name    string
+		manager types.DNSManager
+		ctx     *context.Context
+	}{
+		{"single value", single, &single.ctx},
+		{"rrsets", sets, &sets.ctx},
+	}
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"GET", "/rrsets", ""},
+		{"GET", "/rrsets/a.test.com/A", ""},
+		{"PUT", "/rrsets/a.test.com/A", `{"values":["10.0.0.3"]}`},
+		{"DELETE", "/rrsets/a.test.com/A", ""},
+	}
+	type key struct{}
+	for _, m := range managers {
+		server, err := New(m.manager, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range requests {
+			t.Run(m.name+" "+r.method+" "+r.path, func(t *testing.T) {
+				req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+				req = req.WithContext(context.WithValue(req.Context(), key{}, "trace"))
+				res := httptest.NewRecorder()
+				server.Handler().ServeHTTP(res, req)
+				if res.Code >= 300 {
+					t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+				}
+				if ctx := *m.ctx; ctx == nil || ctx.Value(key{}) != "trace" {
+					t.Errorf("expected the manager to receive the request context, got %v", ctx)
+				}
+			})
+		}
+	}
+}
+
+func TestSingleValueRRSets_ReplaceRRSet(t *testing.T) {
+	tests := []struct {
+		name    string
+		lookup  error
+		wantErr error
+		wantAdd bool
+	}{
+		{"missing record", fmt.Errorf("getting: %w", types.ErrNotFound), nil, true},
+		{"failing backend", types.ServiceUnavailableError("backend down", nil).WithErrorCode(types.CodeBackendUnavailable),
+			types.ErrBackendUnavailable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &lookupDNSManagerMock{memoryDNSManagerMock: newMemoryDNSManagerMock(), lookup: tt.lookup}
+			sets := singleValueRRSets{types.ContextAdapter(manager)}
+			err := sets.ReplaceRRSetContext(context.Background(), types.RRSet{Name: "a.test.com", Type: "A", Values: []string{"10.0.0.1"}})
+			if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("want the error %v, got %v", tt.wantErr, err)
+			}
+			if manager.added != tt.wantAdd {
+				t.Errorf("want the record added %v, got %v", tt.wantAdd, manager.added)
+			}
+		})
+	}
+}
+
+// lookupDNSManagerMock fails every lookup with the given error and records whether a record was added
+type lookupDNSManagerMock struct {
+	*memoryDNSManagerMock
+	lookup error
+	added  bool
+}
+
+func (m *lookupDNSManagerMock) GetDNSRecord(name, recordType string) (*types.DNSRecord, error) {
+	return nil, m.lookup
+}
+
+func (m *lookupDNSManagerMock) AddDNSRecord(record types.DNSRecord) error {
+	m.added = true
+	return m.memoryDNSManagerMock.AddDNSRecord(record)
+}
+
+// contextRRSetManagerMock records the context given to the context-aware RRSet operations
+type contextRRSetManagerMock struct {
+	*memoryRRSetManagerMock
+	ctx context.Context
+}
+
+func (m *contextRRSetManagerMock) GetRRSetsContext(ctx context.Context) ([]types.RRSet, error) {
+	m.ctx = ctx
+	return m.GetRRSets()
+}
+
+func (m *contextRRSetManagerMock) GetRRSetContext(ctx context.Context, name, recordType string) (*types.RRSet, error) {
+	m.ctx = ctx
+	return m.GetRRSet(name, recordType)
+}
+
+func (m *contextRRSetManagerMock) ReplaceRRSetContext(ctx context.Context, set types.RRSet) error {
+	m.ctx = ctx
+	return m.ReplaceRRSet(set)
+}
+
+func (m *contextRRSetManagerMock) RemoveRRSetContext(ctx context.Context, name, recordType string) error {
+	m.ctx = ctx
+	return m.RemoveRRSet(name, recordType)
+}
+
+// memoryRRSetManagerMock keeps RRSets in memory and exposes them as single-value records as well
+type memoryRRSetManagerMock struct {
+	types.DNSManager
+	mu   sync.Mutex
+	sets map[string]types.RRSet
+}
+
+func newMemoryRRSetManagerMock() *memoryRRSetManagerMock {
+	m := &memoryRRSetManagerMock{sets: map[string]types.RRSet{
+		"a.test.com/A": {Name: "a.test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.2"}},
+	}}
+	m.DNSManager = types.NewRRSetDNSManager(m)
+	return m
+}
+
+func (m *memoryRRSetManagerMock) GetRRSets() ([]types.RRSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var sets []types.RRSet
+	for _, set := range m.sets {
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets, nil
+}
+
+func (m *memoryRRSetManagerMock) GetRRSet(name, recordType string) (*types.RRSet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if set, ok := m.sets[name+"/"+recordType]; ok {
+		return &set, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryRRSetManagerMock) ReplaceRRSet(set types.RRSet) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sets[set.Name+"/"+set.Type] = set
+	return nil
+}
+
+func (m *memoryRRSetManagerMock) RemoveRRSet(name, recordType string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sets, name+"/"+recordType)
+	return nil
+}
@@ -0,0 +1,446 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/labbsr0x/bindman-dns-webhook/src/hook/metrics"
+	"github.com/labbsr0x/bindman-dns-webhook/src/tlsconfig"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultAddress is the address a Server listens on when no other is configured
+	DefaultAddress = "0.0.0.0:7070"
+
+	// DefaultDrainTimeout is how long a Server waits for in-flight requests when shutting down
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// Server serves the routes of a DNSWebhook
+type Server struct {
+	// Hook the webhook whose routes are served
+	Hook *DNSWebhook
+
+	address      string
+	basePath     string
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+	drainTimeout time.Duration
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	authenticators []Authenticator
+	publicMetrics  bool
+
+	asyncChanges    bool
+	changeWorkers   int
+	changeQueueSize int
+
+	idempotencyWindow time.Duration
+	idempotency       *idempotencyStore
+
+	watchBuffer int
+
+	subscriptions    []types.Subscription
+	deliveryAttempts int
+	deliveryBackoff  time.Duration
+
+	handler    http.Handler
+	httpServer *http.Server
+}
+
+// Option defines a function that customizes a Server on its creation
+type Option func(*Server)
+
+// WithAddress defines the address the server will listen on, e.g. ":8080"
+func WithAddress(address string) Option {
+	return func(s *Server) {
+		s.address = address
+	}
+}
+
+// WithTimeouts defines the read, write and idle timeouts of the http server. A zero value means no timeout
+func WithTimeouts(read, write, idle time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = read
+		s.writeTimeout = write
+		s.idleTimeout = idle
+	}
+}
+
+// WithDrainTimeout defines how long the server waits for in-flight requests and for the manager shutdown once Run is canceled
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(s *Server) {
+		s.drainTimeout = timeout
+	}
+}
+
+// WithTLS makes the server serve HTTPS with the given certificate and key files. The files are reloaded when they change
+func WithTLS(certFile, keyFile string) Option {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// WithClientCA requires clients to present a certificate signed by one of the CAs of the given bundle (mutual TLS).
+// Only takes effect along with WithTLS
+func WithClientCA(caFile string) Option {
+	return func(s *Server) {
+		s.clientCAFile = caFile
+	}
+}
+
+// WithAuthentication requires requests to be accepted by one of the authenticators. The caller identity is then
+// available to handlers through CallerFromContext
+func WithAuthentication(authenticators ...Authenticator) Option {
+	return func(s *Server) {
+		s.authenticators = append(s.authenticators, authenticators...)
+	}
+}
+
+// WithPublicMetrics exempts the metrics endpoint from authentication
+func WithPublicMetrics() Option {
+	return func(s *Server) {
+		s.publicMetrics = true
+	}
+}
+
+// WithPolicy restricts what each authenticated caller may do, see LoadPolicy
+func WithPolicy(policy *Policy) Option {
+	return func(s *Server) {
+		s.Hook.Policy = policy
+	}
+}
+
+// WithTTL defines the TTL given to records sent without one and the range of TTLs accepted. Zero disables a setting
+func WithTTL(defaultTTL, minTTL, maxTTL int) Option {
+	return func(s *Server) {
+		s.Hook.TTL = TTLBounds{Default: defaultTTL, Min: minTTL, Max: maxTTL}
+	}
+}
+
+// WithDefaultZone makes names not ending with a dot relative to the zone, e.g. "app" becomes "app.example.com" on the
+// "example.com" zone. See types.NameNormalizer
+func WithDefaultZone(zone string) Option {
+	return func(s *Server) {
+		s.Hook.Names.DefaultZone = zone
+	}
+}
+
+// WithBasePath prefixes every route of the server with the given path, e.g. "/dns" exposes "/dns/records"
+func WithBasePath(path string) Option {
+	return func(s *Server) {
+		s.basePath = "/" + strings.Trim(path, "/")
+		if s.basePath == "/" {
+			s.basePath = ""
+		}
+	}
+}
+
+// WithAsyncChanges lets requests adding, updating and removing records on /records be answered before the change is
+// applied, when they carry the "Prefer: respond-async" header. Such requests are validated and authorized right away
+// and answered with 202 Accepted and a types.Change, whose status is then reported on /changes/{id}. workers bounds
+// how many changes are applied at once and queueSize how many may wait, the others being refused with 503 Service
+// Unavailable. Zero means DefaultChangeWorkers and DefaultChangeQueueSize
+func WithAsyncChanges(workers, queueSize int) Option {
+	return func(s *Server) {
+		s.asyncChanges = true
+		s.changeWorkers = workers
+		s.changeQueueSize = queueSize
+	}
+}
+
+// WithIdempotencyWindow defines how long the responses of requests carrying an Idempotency-Key header are replayed to
+// their retries, DefaultIdempotencyWindow by default. Zero disables idempotent requests
+func WithIdempotencyWindow(window time.Duration) Option {
+	return func(s *Server) {
+		s.idempotencyWindow = window
+	}
+}
+
+// WithWatchBuffer defines how many events are kept to be replayed to the watchers of /records/watch resuming their
+// stream, DefaultWatchBuffer by default
+func WithWatchBuffer(size int) Option {
+	return func(s *Server) {
+		s.watchBuffer = size
+	}
+}
+
+// WithSubscriptions registers subscriptions notified of the changes of the records, along with the ones registered on
+// /subscriptions, see LoadSubscriptions. Subscriptions registered this way are not kept once removed on /subscriptions
+func WithSubscriptions(subscriptions ...types.Subscription) Option {
+	return func(s *Server) {
+		s.subscriptions = append(s.subscriptions, subscriptions...)
+	}
+}
+
+// WithDeliveryRetries defines how many times an event is sent to a subscription before it becomes a dead letter, and
+// the wait before the second attempt, doubled after each failure. DefaultDeliveryAttempts and DefaultDeliveryBackoff
+// by default
+func WithDeliveryRetries(attempts int, backoff time.Duration) Option {
+	return func(s *Server) {
+		s.deliveryAttempts = attempts
+		s.deliveryBackoff = backoff
+	}
+}
+
+// New builds a Server exposing the manager operations. The server does not listen until ListenAndServe or Serve is called;
+// its routes can also be mounted on another server through Handler
+func New(manager types.DNSManager, serviceVersion string, options ...Option) (*Server, error) {
+	if manager == nil {
+		return nil, errors.New("A non-nil DNSManager is required to initialize the hook")
+	}
+	if strings.TrimSpace(serviceVersion) == "" {
+		return nil, errors.New("A non-empty service version is required to initialize the hook")
+	}
+
+	s := &Server{Hook: &DNSWebhook{DNSManager: manager}, address: DefaultAddress, drainTimeout: DefaultDrainTimeout,
+		idempotencyWindow: DefaultIdempotencyWindow, watchBuffer: DefaultWatchBuffer,
+		deliveryAttempts: DefaultDeliveryAttempts, deliveryBackoff: DefaultDeliveryBackoff}
+	for _, option := range options {
+		option(s)
+	}
+	if err := s.Hook.TTL.validate(); err != nil {
+		return nil, err
+	}
+	if errs := s.Hook.Names.Check(); errs != nil {
+		return nil, fmt.Errorf("invalid default zone: %s", strings.Join(errs, ", "))
+	}
+	if s.Hook.Policy != nil {
+		if err := s.Hook.Policy.compile(); err != nil {
+			return nil, err
+		}
+	}
+	if s.changeWorkers < 0 || s.changeQueueSize < 0 {
+		return nil, fmt.Errorf("invalid asynchronous changes: %d workers and a queue of %d changes", s.changeWorkers, s.changeQueueSize)
+	}
+	if s.idempotencyWindow < 0 {
+		return nil, fmt.Errorf("invalid idempotency window: %v", s.idempotencyWindow)
+	}
+	if s.idempotencyWindow > 0 {
+		s.idempotency = newIdempotencyStore(s.idempotencyWindow)
+	}
+	if s.watchBuffer < 1 {
+		return nil, fmt.Errorf("invalid watch buffer: %d events", s.watchBuffer)
+	}
+	s.Hook.events = newEventBroker(s.watchBuffer)
+	if s.deliveryAttempts < 1 || s.deliveryBackoff < 0 {
+		return nil, fmt.Errorf("invalid delivery retries: %d attempts with a backoff of %v", s.deliveryAttempts, s.deliveryBackoff)
+	}
+	if err := s.checkSubscriptions(); err != nil {
+		return nil, err
+	}
+
+	prometheus := metrics.New(serviceVersion)
+	s.handler = s.routes(prometheus)
+	s.httpServer = &http.Server{
+		Addr:         s.address,
+		Handler:      s.handler,
+		ReadTimeout:  s.readTimeout,
+		WriteTimeout: s.writeTimeout,
+		IdleTimeout:  s.idleTimeout,
+	}
+	if s.certFile != "" || s.keyFile != "" {
+		tlsConfig, err := tlsconfig.Server(s.certFile, s.keyFile, s.clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		s.httpServer.TLSConfig = tlsConfig
+	}
+	if s.asyncChanges {
+		workers, queueSize := s.changeWorkers, s.changeQueueSize
+		if workers == 0 {
+			workers = DefaultChangeWorkers
+		}
+		if queueSize == 0 {
+			queueSize = DefaultChangeQueueSize
+		}
+		// started last, so no error leaves the workers running
+		s.Hook.changes = newChangeQueue(workers, queueSize)
+	}
+	s.Hook.subscriptions = newNotifier(s.subscriptions, s.deliveryAttempts, s.deliveryBackoff, s.Hook.Policy, prometheus)
+	return s, nil
+}
+
+// checkSubscriptions validates the subscriptions given by WithSubscriptions, generating the missing IDs
+func (s *Server) checkSubscriptions() error {
+	ids := map[string]bool{}
+	for i := range s.subscriptions {
+		subscription := &s.subscriptions[i]
+		if errs := subscription.Check(); errs != nil {
+			return fmt.Errorf("invalid subscription %d: %s", i, strings.Join(errs, ", "))
+		}
+		if subscription.ID == "" {
+			id, err := newID()
+			if err != nil {
+				return err
+			}
+			subscription.ID = id
+		}
+		if ids[subscription.ID] {
+			return fmt.Errorf("duplicated subscription '%s'", subscription.ID)
+		}
+		ids[subscription.ID] = true
+		subscription.Owner = ""
+	}
+	return nil
+}
+
+// routes builds the router with every endpoint of the webhook
+func (s *Server) routes(prometheus *metrics.Prometheus) http.Handler {
+	router := mux.NewRouter()
+	hook := s.Hook
+
+	handle := func(method, path string, handler http.HandlerFunc) {
+		path = s.basePath + path
+		if s.idempotency != nil && method != "GET" {
+			handler = s.idempotency.wrap(handler)
+		}
+		router.HandleFunc(prometheus.HandleFunc(path, recoverPanics(handler, func(r *http.Request) {
+			prometheus.IncPanics(r.Method, path)
+		}))).Methods(method)
+	}
+
+	handle("GET", "/records", hook.GetDNSRecords)
+	handle("GET", "/records/watch", hook.WatchDNSRecords)
+	handle("GET", "/records/{name}/{type}", hook.GetDNSRecord)
+	handle("DELETE", "/records/{name}/{type}", hook.RemoveDNSRecord)
+	handle("POST", "/records", hook.AddDNSRecord)
+	handle("PUT", "/records", hook.UpdateDNSRecord)
+	handle("PATCH", "/records/{name}/{type}", hook.PatchDNSRecord)
+	handle("POST", "/records:batch", hook.ApplyBatch)
+	handle("GET", "/rrsets", hook.GetRRSets)
+	handle("GET", "/rrsets/{name}/{type}", hook.GetRRSet)
+	handle("PUT", "/rrsets/{name}/{type}", hook.ReplaceRRSet)
+	handle("PATCH", "/rrsets/{name}/{type}", hook.PatchRRSet)
+	handle("DELETE", "/rrsets/{name}/{type}", hook.RemoveRRSet)
+	handle("GET", "/changes/{id}", hook.GetChange)
+	handle("GET", "/subscriptions", hook.GetSubscriptions)
+	handle("POST", "/subscriptions", hook.AddSubscription)
+	handle("GET", "/subscriptions/{id}", hook.GetSubscription)
+	handle("DELETE", "/subscriptions/{id}", hook.RemoveSubscription)
+	handle("GET", "/subscriptions/{id}/dead-letters", hook.GetDeadLetters)
+
+	// exposes /metrics endpoint with standard golang metrics used by prometheus
+	router.Handle(s.basePath+"/metrics", promhttp.Handler())
+
+	if len(s.authenticators) == 0 {
+		return router
+	}
+	return authenticate(s.authenticators, func(r *http.Request) bool {
+		return s.publicMetrics && r.URL.Path == s.basePath+"/metrics"
+	}, router)
+}
+
+// Handler returns the http.Handler serving the webhook routes, so they can be embedded in another server
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+// Addr returns the address the server listens on
+func (s *Server) Addr() string {
+	return s.address
+}
+
+// ListenAndServe listens on the configured address and serves the webhook routes. It always returns a non-nil error
+func (s *Server) ListenAndServe() error {
+	logrus.Infof("Initialized DNS Manager Webhook on %s", s.address)
+	if s.httpServer.TLSConfig != nil {
+		return s.httpServer.ListenAndServeTLS("", "")
+	}
+	return s.httpServer.ListenAndServe()
+}
+
+// Serve serves the webhook routes on the given listener. It always returns a non-nil error
+func (s *Server) Serve(listener net.Listener) error {
+	logrus.Infof("Initialized DNS Manager Webhook on %s", listener.Addr())
+	if s.httpServer.TLSConfig != nil {
+		return s.httpServer.ServeTLS(listener, "", "")
+	}
+	return s.httpServer.Serve(listener)
+}
+
+// Run listens on the configured address and serves the webhook routes until ctx is done. On cancellation, in-flight
+// requests are drained and the manager is shut down, both within the drain timeout. Returns nil after a graceful shutdown
+func (s *Server) Run(ctx context.Context) error {
+	return s.run(ctx, s.ListenAndServe)
+}
+
+// run serves with the given function until ctx is done and then shuts down the server
+func (s *Server) run(ctx context.Context, serve func() error) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- serve()
+	}()
+
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	logrus.Info("Shutting down the DNS Manager Webhook")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancel()
+	err := s.Shutdown(shutdownCtx)
+	if serveErr := <-served; serveErr != http.ErrServerClosed && err == nil {
+		err = serveErr
+	}
+	return err
+}
+
+// Shutdown stops accepting requests, ends the streams of the watchers, waits for the in-flight requests, for the
+// pending asynchronous changes and for the pending deliveries to the subscriptions, and then shuts the manager down if
+// it implements types.Shutdowner or io.Closer. ctx bounds how long it waits
+func (s *Server) Shutdown(ctx context.Context) error {
+	// the streams of the watchers never end on their own
+	s.Hook.events.close()
+	err := s.httpServer.Shutdown(ctx)
+	if err != nil {
+		logrus.Errorf("Error draining in-flight requests: %v", err)
+	}
+	if s.Hook.changes != nil {
+		if changesErr := s.Hook.changes.close(ctx); changesErr != nil {
+			logrus.Errorf("Error applying the pending changes: %v", changesErr)
+			if err == nil {
+				err = changesErr
+			}
+		}
+	}
+
+	// the changes applied so far may still have events to deliver
+	if deliveriesErr := s.Hook.subscriptions.close(ctx); deliveriesErr != nil {
+		logrus.Errorf("Error delivering the pending events: %v", deliveriesErr)
+		if err == nil {
+			err = deliveriesErr
+		}
+	}
+
+	var managerErr error
+	switch manager := s.Hook.DNSManager.(type) {
+	case types.Shutdowner:
+		managerErr = manager.Shutdown(ctx)
+	case io.Closer:
+		managerErr = manager.Close()
+	}
+	if managerErr != nil {
+		logrus.Errorf("Error shutting down the DNS Manager: %v", managerErr)
+		if err == nil {
+			err = managerErr
+		}
+	}
+	return err
+}
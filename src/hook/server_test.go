@@ -0,0 +1,171 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name           string
+		manager        types.DNSManager
+		serviceVersion string
+		options        []Option
+		wantErr        bool
+	}{
+		{"nil manager", nil, "1", nil, true},
+		{"empty service version", &SuccessDNSManagerMock{records}, " ", nil, true},
+		{"valid arguments", &SuccessDNSManagerMock{records}, "1", nil, false},
+		{"missing TLS files", &SuccessDNSManagerMock{records}, "1", []Option{WithTLS("missing.crt", "missing.key")}, true},
+		{"invalid default zone", &SuccessDNSManagerMock{records}, "1", []Option{WithDefaultZone("exa mple.com")}, true},
+		{"negative change workers", &SuccessDNSManagerMock{records}, "1", []Option{WithAsyncChanges(-1, 0)}, true},
+		{"negative idempotency window", &SuccessDNSManagerMock{records}, "1", []Option{WithIdempotencyWindow(-time.Second)}, true},
+		{"invalid watch buffer", &SuccessDNSManagerMock{records}, "1", []Option{WithWatchBuffer(0)}, true},
+		{"invalid delivery retries", &SuccessDNSManagerMock{records}, "1", []Option{WithDeliveryRetries(0, time.Second)}, true},
+		{"invalid subscription", &SuccessDNSManagerMock{records}, "1", []Option{WithSubscriptions(types.Subscription{URL: "/dns"})}, true},
+		{"duplicated subscription", &SuccessDNSManagerMock{records}, "1", []Option{WithSubscriptions(types.Subscription{ID: "s", URL: "http://a.com", Secret: "s"}, types.Subscription{ID: "s", URL: "http://b.com", Secret: "s"})}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(tt.manager, tt.serviceVersion, tt.options...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && got != nil {
+				t.Errorf("New() must return a nil *Server when an error occurred, got %v", got)
+			}
+		})
+	}
+}
+
+func TestNew_Options(t *testing.T) {
+	server, err := New(&SuccessDNSManagerMock{records}, "1",
+		WithAddress("127.0.0.1:8080"),
+		WithTimeouts(time.Second, 2*time.Second, 3*time.Second),
+		WithBasePath("/dns/"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Addr() != "127.0.0.1:8080" || server.httpServer.Addr != "127.0.0.1:8080" {
+		t.Errorf("unexpected address %s", server.Addr())
+	}
+	if server.httpServer.ReadTimeout != time.Second || server.httpServer.WriteTimeout != 2*time.Second || server.httpServer.IdleTimeout != 3*time.Second {
+		t.Errorf("unexpected timeouts %v", server.httpServer)
+	}
+
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/dns/records", http.StatusOK},
+		{"/dns/metrics", http.StatusOK},
+		{"/records", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest("GET", tt.path, nil))
+		if res.Code != tt.code {
+			t.Errorf("GET %s: want status %d, got %d", tt.path, tt.code, res.Code)
+		}
+	}
+}
+
+func TestServer_Serve(t *testing.T) {
+	// two hooks must be able to run in the same process
+	for i := 0; i < 2; i++ {
+		server, err := New(&SuccessDNSManagerMock{records}, "1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go server.Serve(listener)
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/records", listener.Addr()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []types.DNSRecord
+		err = json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, records) {
+			t.Errorf("want %v, got %v", records, got)
+		}
+		server.httpServer.Close()
+	}
+}
+
+func TestServer_Run(t *testing.T) {
+	manager := &slowDNSManagerMock{SuccessDNSManagerMock: SuccessDNSManagerMock{records}, delay: 100 * time.Millisecond}
+	server, err := New(manager, "1", WithDrainTimeout(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- server.run(ctx, func() error { return server.Serve(listener) })
+	}()
+
+	responded := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(fmt.Sprintf("http://%s/records", listener.Addr()))
+		if err != nil {
+			t.Error(err)
+			responded <- 0
+			return
+		}
+		resp.Body.Close()
+		responded <- resp.StatusCode
+	}()
+
+	// cancel while the request is in flight
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if code := <-responded; code != http.StatusOK {
+		t.Errorf("in-flight request must be drained, got status %d", code)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("graceful shutdown must return nil, got %v", err)
+	}
+	if !manager.shutdown {
+		t.Error("the manager must be shut down")
+	}
+}
+
+type slowDNSManagerMock struct {
+	SuccessDNSManagerMock
+	delay    time.Duration
+	shutdown bool
+}
+
+func (m *slowDNSManagerMock) GetDNSRecords() ([]types.DNSRecord, error) {
+	time.Sleep(m.delay)
+	return m.SuccessDNSManagerMock.GetDNSRecords()
+}
+
+func (m *slowDNSManagerMock) Shutdown(ctx context.Context) error {
+	m.shutdown = true
+	return nil
+}
@@ -0,0 +1,182 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// subscriptionsFile is the content of a file of subscriptions, see LoadSubscriptions
+type subscriptionsFile struct {
+	Subscriptions []types.Subscription `json:"subscriptions" yaml:"subscriptions"`
+}
+
+// LoadSubscriptions reads the subscriptions from a YAML or JSON file holding them on a "subscriptions" list
+func LoadSubscriptions(file string) ([]types.Subscription, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	content := subscriptionsFile{}
+	// YAML is a superset of JSON, so both formats are parsed the same way
+	if err := yaml.UnmarshalStrict(data, &content); err != nil {
+		return nil, fmt.Errorf("error parsing the subscriptions file '%s': %v", file, err)
+	}
+	return content.Subscriptions, nil
+}
+
+// GetSubscriptions lists the subscriptions notified of the changes of the records the caller manages
+func (m *DNSWebhook) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getSubscriptions).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getSubscriptions(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetSubscriptions call. Http Request: %v", r)
+
+	if err := m.authorize(r, VerbSubscribe, "", ""); err != nil {
+		return err
+	}
+	subscriptions := []types.Subscription{}
+	if m.subscriptions == nil {
+		return writeJSONResponse(subscriptions, http.StatusOK, w)
+	}
+	for _, subscription := range m.subscriptions.list() {
+		if m.managesSubscription(r, subscription) {
+			subscription.Secret = ""
+			subscriptions = append(subscriptions, subscription)
+		}
+	}
+	return writeJSONResponse(subscriptions, http.StatusOK, w)
+}
+
+// GetSubscription gets a specific subscription. Its id comes from url params
+func (m *DNSWebhook) GetSubscription(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getSubscription).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getSubscription(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetSubscription call. Http Request: %v", r)
+	id := mux.Vars(r)["id"]
+
+	if err := m.authorize(r, VerbSubscribe, "", ""); err != nil {
+		return err
+	}
+	subscription, found := m.subscription(id)
+	if !found || !m.managesSubscription(r, subscription) {
+		return subscriptionNotFound(id)
+	}
+	subscription.Secret = ""
+	return writeJSONResponse(subscription, http.StatusOK, w)
+}
+
+// AddSubscription registers a subscription, answering 201 Created with it. Its ID is generated when missing
+// Expects a types.Subscription object as a body payload
+func (m *DNSWebhook) AddSubscription(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.addSubscription).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) addSubscription(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("AddSubscription call. Http Request: %v", r)
+
+	if err := m.authorize(r, VerbSubscribe, "", ""); err != nil {
+		return err
+	}
+	if m.subscriptions == nil {
+		return &types.Error{Message: "Subscriptions are not supported by the server", Code: http.StatusNotImplemented,
+			ErrorCode: types.CodeUnsupportedOperation}
+	}
+	var subscription types.Subscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		return types.BadRequestError("Invalid request body. You must pass a JSON formatted subscription on request body", err)
+	}
+	if errs := subscription.FieldErrors(); errs != nil {
+		return types.ValidationError("Invalid request body. You must pass a JSON formatted subscription on request body", errs)
+	}
+	if subscription.ID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		subscription.ID = id
+	}
+	subscription.Owner = CallerFromContext(r.Context())
+	if err := m.subscriptions.add(subscription); err != nil {
+		return err
+	}
+	subscription.Secret = ""
+	w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+subscription.ID)
+	return writeJSONResponse(subscription, http.StatusCreated, w)
+}
+
+// RemoveSubscription unregisters a subscription with its dead letters. Its id comes from url params
+func (m *DNSWebhook) RemoveSubscription(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.removeSubscription).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) removeSubscription(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("RemoveSubscription call. Http Request: %v", r)
+	id := mux.Vars(r)["id"]
+
+	if err := m.authorize(r, VerbSubscribe, "", ""); err != nil {
+		return err
+	}
+	if subscription, found := m.subscription(id); !found || !m.managesSubscription(r, subscription) {
+		return subscriptionNotFound(id)
+	}
+	if !m.subscriptions.remove(id) {
+		return subscriptionNotFound(id)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// GetDeadLetters lists the events that could not be delivered to a subscription, oldest first. Its id comes from url
+// params
+func (m *DNSWebhook) GetDeadLetters(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.getDeadLetters).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) getDeadLetters(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("GetDeadLetters call. Http Request: %v", r)
+	id := mux.Vars(r)["id"]
+
+	if err := m.authorize(r, VerbSubscribe, "", ""); err != nil {
+		return err
+	}
+	if subscription, found := m.subscription(id); !found || !m.managesSubscription(r, subscription) {
+		return subscriptionNotFound(id)
+	}
+	return writeJSONResponse(m.subscriptions.deadLettersOf(id), http.StatusOK, w)
+}
+
+// subscription returns the subscription identified by id, if the DNSWebhook holds subscriptions at all
+func (m *DNSWebhook) subscription(id string) (types.Subscription, bool) {
+	if m.subscriptions == nil {
+		return types.Subscription{}, false
+	}
+	return m.subscriptions.get(id)
+}
+
+// managesSubscription tells if the caller of the request may see and manage the subscription. Callers manage the
+// subscriptions they registered; admins of the policy manage every one, including the ones registered by
+// configuration, which are notified of the changes of every record. Without a policy, every caller manages every
+// subscription
+func (m *DNSWebhook) managesSubscription(r *http.Request, subscription types.Subscription) bool {
+	if m.Policy == nil {
+		return true
+	}
+	caller := CallerFromContext(r.Context())
+	return (subscription.Owner != "" && subscription.Owner == caller) || m.Policy.IsAdmin(caller)
+}
+
+// subscriptionNotFound returns the error of a missing subscription
+func subscriptionNotFound(id string) error {
+	return types.NotFoundError("Subscription not found", nil, fmt.Sprintf("there is no subscription '%s'", id))
+}
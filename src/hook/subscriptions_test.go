@@ -0,0 +1,250 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_Subscriptions(t *testing.T) {
+	subscriber := &subscriberMock{t: t}
+	subscriberServer := httptest.NewServer(subscriber)
+	defer subscriberServer.Close()
+
+	server, err := New(newMemoryDNSManagerMock(types.DNSRecord{Name: "a.test.com", Type: "A", Value: "10.0.0.1"}), "1",
+		WithSubscriptions(types.Subscription{ID: "config", URL: subscriberServer.URL, Secret: "other",
+			Filter: types.SubscriptionFilter{Zones: []string{"other.com"}}}),
+		WithDeliveryRetries(1, time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return res
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid subscription", "POST", "/subscriptions", `{"url":"/dns"}`, http.StatusBadRequest},
+		{"added", "POST", "/subscriptions", `{"id":"s1","url":"` + subscriberServer.URL + `","secret":"secret","filter":{"zones":["test.com"],"verbs":["update"]}}`, http.StatusCreated},
+		{"existing subscription", "POST", "/subscriptions", `{"id":"s1","url":"http://example.com","secret":"secret"}`, http.StatusConflict},
+		{"subscription", "GET", "/subscriptions/s1", "", http.StatusOK},
+		{"missing subscription", "GET", "/subscriptions/missing", "", http.StatusNotFound},
+		{"dead letters", "GET", "/subscriptions/s1/dead-letters", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(tt.method, tt.path, tt.body)
+			if res.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+			if strings.Contains(res.Body.String(), `"secret":`) {
+				t.Errorf("expected the secret to be hidden, got %s", res.Body.String())
+			}
+		})
+	}
+	if location := serve("POST", "/subscriptions", `{"url":"http://example.com","secret":"s"}`).Header().Get("Location"); !strings.HasPrefix(location, "/subscriptions/") {
+		t.Errorf("unexpected location %s", location)
+	}
+
+	var subscriptions []types.Subscription
+	if err := json.Unmarshal(serve("GET", "/subscriptions", "").Body.Bytes(), &subscriptions); err != nil || len(subscriptions) != 3 ||
+		subscriptions[0].ID != "config" || subscriptions[1].ID != "s1" {
+		t.Errorf("unexpected subscriptions %+v (%v)", subscriptions, err)
+	}
+
+	serve("POST", "/records", `{"name":"b.test.com","type":"A","value":"10.0.0.3"}`)
+	if res := serve("PUT", "/records", `{"name":"a.test.com","type":"A","value":"10.0.0.2"}`); res.Code != http.StatusNoContent {
+		t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	waitFor(t, func() bool {
+		events, _ := subscriber.received()
+		return len(events) == 1
+	})
+	events, _ := subscriber.received()
+	if events[0].Op != types.BatchUpdate || events[0].Before == nil || events[0].Before.Value != "10.0.0.1" || events[0].Record.Value != "10.0.0.2" {
+		t.Errorf("unexpected event %+v", events[0])
+	}
+
+	if res := serve("DELETE", "/subscriptions/s1", ""); res.Code != http.StatusNoContent {
+		t.Errorf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	if res := serve("DELETE", "/subscriptions/s1", ""); res.Code != http.StatusNotFound {
+		t.Errorf("unexpected response %d %s", res.Code, res.Body.String())
+	}
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDNSWebhook_Subscriptions_Policy(t *testing.T) {
+	policy, err := NewPolicy(Rule{Callers: []string{"a"}, Verbs: []string{VerbList}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newMemoryDNSManagerMock(), "1", WithAuthentication(BearerTokens{"t": "a"}), WithPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("GET", "/subscriptions", nil)
+	req.Header.Set("Authorization", "Bearer t")
+	res := httptest.NewRecorder()
+	server.Handler().ServeHTTP(res, req)
+	if res.Code != http.StatusForbidden {
+		t.Errorf("expected the subscribe verb to be required, got %d", res.Code)
+	}
+}
+
+func TestDNSWebhook_Subscriptions_Owners(t *testing.T) {
+	policy, err := NewPolicy(
+		Rule{Callers: []string{"a", "b"}, Verbs: []string{VerbSubscribe}},
+		Rule{Callers: []string{"ops"}, Verbs: []string{VerbSubscribe, VerbAdmin}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := New(newMemoryDNSManagerMock(), "1",
+		WithAuthentication(BearerTokens{"ta": "a", "tb": "b", "tops": "ops"}), WithPolicy(policy),
+		WithSubscriptions(types.Subscription{ID: "config", URL: "http://example.com", Secret: "secret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Shutdown(context.Background())
+	serve := func(token, method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, req)
+		return res
+	}
+	for token, id := range map[string]string{"ta": "sa", "tb": "sb"} {
+		if res := serve(token, "POST", "/subscriptions", `{"id":"`+id+`","url":"http://example.com","secret":"secret"}`); res.Code != http.StatusCreated {
+			t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name       string
+		token      string
+		method     string
+		path       string
+		wantStatus int
+		wantIDs    string
+	}{
+		{"owner lists", "ta", "GET", "/subscriptions", http.StatusOK, "sa"},
+		{"other caller lists", "tb", "GET", "/subscriptions", http.StatusOK, "sb"},
+		{"admin lists", "tops", "GET", "/subscriptions", http.StatusOK, "config,sa,sb"},
+		{"other caller gets", "tb", "GET", "/subscriptions/sa", http.StatusNotFound, ""},
+		{"other caller gets dead letters", "tb", "GET", "/subscriptions/sa/dead-letters", http.StatusNotFound, ""},
+		{"other caller removes", "tb", "DELETE", "/subscriptions/sa", http.StatusNotFound, ""},
+		{"caller gets configured", "tb", "GET", "/subscriptions/config", http.StatusNotFound, ""},
+		{"caller gets configured dead letters", "tb", "GET", "/subscriptions/config/dead-letters", http.StatusNotFound, ""},
+		{"caller removes configured", "tb", "DELETE", "/subscriptions/config", http.StatusNotFound, ""},
+		{"admin gets configured", "tops", "GET", "/subscriptions/config/dead-letters", http.StatusOK, ""},
+		{"admin gets", "tops", "GET", "/subscriptions/sa", http.StatusOK, ""},
+		{"owner removes", "ta", "DELETE", "/subscriptions/sa", http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := serve(tt.token, tt.method, tt.path, "")
+			if res.Code != tt.wantStatus {
+				t.Fatalf("want status %d, got %d %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+			if tt.wantIDs == "" {
+				return
+			}
+			var subscriptions []types.Subscription
+			if err := json.Unmarshal(res.Body.Bytes(), &subscriptions); err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, subscription := range subscriptions {
+				ids = append(ids, subscription.ID)
+			}
+			if got := strings.Join(ids, ","); got != tt.wantIDs {
+				t.Errorf("want subscriptions %s, got %s", tt.wantIDs, got)
+			}
+		})
+	}
+}
+
+func TestDNSWebhook_Subscriptions_WithoutNotifier(t *testing.T) {
+	hook := &DNSWebhook{DNSManager: newMemoryDNSManagerMock()}
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		method     string
+		body       string
+		wantStatus int
+	}{
+		{"list", hook.GetSubscriptions, "GET", "", http.StatusOK},
+		{"get", hook.GetSubscription, "GET", "", http.StatusNotFound},
+		{"add", hook.AddSubscription, "POST", `{"url":"http://example.com","secret":"secret"}`, http.StatusNotImplemented},
+		{"remove", hook.RemoveSubscription, "DELETE", "", http.StatusNotFound},
+		{"dead letters", hook.GetDeadLetters, "GET", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := mux.SetURLVars(httptest.NewRequest(tt.method, "/subscriptions/s1", strings.NewReader(tt.body)), map[string]string{"id": "s1"})
+			res := httptest.NewRecorder()
+			tt.handler(res, req)
+			if res.Code != tt.wantStatus {
+				t.Errorf("want status %d, got %d %s", tt.wantStatus, res.Code, res.Body.String())
+			}
+		})
+	}
+}
+
+func TestLoadSubscriptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subscriptions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		wantErr bool
+	}{
+		{"yaml subscriptions", write("subscriptions.yaml", "subscriptions:\n  - id: cmdb\n    url: https://cmdb.example.com/dns\n    secret: s\n    filter:\n      zones: [example.com]\n      verbs: [add, remove]\n"), false},
+		{"json subscriptions", write("subscriptions.json", `{"subscriptions": [{"id": "cmdb", "url": "https://cmdb.example.com/dns", "secret": "s", "filter": {"zones": ["example.com"], "verbs": ["add", "remove"]}}]}`), false},
+		{"unknown field", write("unknown.yaml", "subscriptions:\n  - url: https://cmdb.example.com/dns\n    owner: a\n"), true},
+		{"missing file", filepath.Join(dir, "missing.yaml"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subscriptions, err := LoadSubscriptions(tt.file)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadSubscriptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (len(subscriptions) != 1 || subscriptions[0].ID != "cmdb" || len(subscriptions[0].Filter.Verbs) != 2) {
+				t.Errorf("unexpected subscriptions %+v", subscriptions)
+			}
+		})
+	}
+}
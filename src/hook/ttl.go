@@ -0,0 +1,61 @@
+package hook
+
+import (
+	"fmt"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+// TTLBounds defines the TTL given to records that do not define one and the range of TTLs accepted by the hook.
+// A zero value disables the respective setting
+type TTLBounds struct {
+	// Default the TTL of the records sent without one
+	Default int
+
+	// Min the lowest TTL accepted
+	Min int
+
+	// Max the greatest TTL accepted
+	Max int
+}
+
+// validate checks the bounds are consistent
+func (b TTLBounds) validate() error {
+	if b.Default < 0 || b.Min < 0 || b.Max < 0 || b.Default > types.MaxTTL || b.Min > types.MaxTTL || b.Max > types.MaxTTL {
+		return fmt.Errorf("TTL bounds must be between 0 and %d", types.MaxTTL)
+	}
+	if b.Max > 0 && b.Min > b.Max {
+		return fmt.Errorf("the min TTL %d is greater than the max TTL %d", b.Min, b.Max)
+	}
+	if b.Default > 0 && !b.accepts(b.Default) {
+		return fmt.Errorf("the default TTL %d is out of the accepted range", b.Default)
+	}
+	return nil
+}
+
+// accepts tells if the ttl is within the bounds
+func (b TTLBounds) accepts(ttl int) bool {
+	return ttl >= b.Min && (b.Max == 0 || ttl <= b.Max)
+}
+
+// apply sets the default TTL on the record when it does not define one and checks its TTL is within the bounds
+func (b TTLBounds) apply(record *types.DNSRecord) error {
+	return b.applyTo(&record.TTL)
+}
+
+// applyTo sets the default TTL when ttl is zero and checks it is within the bounds
+func (b TTLBounds) applyTo(ttl *int) error {
+	if *ttl == 0 {
+		*ttl = b.Default
+	}
+	if *ttl != 0 && !b.accepts(*ttl) {
+		max := b.Max
+		if max == 0 {
+			max = types.MaxTTL
+		}
+		return types.ValidationError("Invalid record TTL", []types.FieldError{
+			{Field: "ttl", Code: types.CodeOutOfRange, Message: fmt.Sprintf("the value of field 'ttl' must be between %d and %d", b.Min, max)},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package hook
+
+import (
+	"testing"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestTTLBounds_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		bounds  TTLBounds
+		wantErr bool
+	}{
+		{"no bounds", TTLBounds{}, false},
+		{"default within bounds", TTLBounds{Default: 300, Min: 60, Max: 3600}, false},
+		{"only default", TTLBounds{Default: 300}, false},
+		{"default lower than min", TTLBounds{Default: 30, Min: 60}, true},
+		{"default greater than max", TTLBounds{Default: 7200, Max: 3600}, true},
+		{"min greater than max", TTLBounds{Min: 3600, Max: 60}, true},
+		{"negative value", TTLBounds{Min: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.bounds.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTTLBounds_apply(t *testing.T) {
+	bounds := TTLBounds{Default: 300, Min: 60, Max: 3600}
+	tests := []struct {
+		name    string
+		bounds  TTLBounds
+		ttl     int
+		want    int
+		wantErr bool
+	}{
+		{"default ttl", bounds, 0, 300, false},
+		{"ttl within bounds", bounds, 600, 600, false},
+		{"ttl lower than min", bounds, 30, 30, true},
+		{"ttl greater than max", bounds, 7200, 7200, true},
+		{"no default", TTLBounds{Min: 60}, 0, 0, false},
+		{"no max", TTLBounds{Min: 60}, types.MaxTTL, types.MaxTTL, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := types.DNSRecord{Name: "test.com", Value: "127.0.0.1", Type: "A", TTL: tt.ttl}
+			err := tt.bounds.apply(&record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("apply() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if record.TTL != tt.want {
+				t.Errorf("want TTL %d, got %d", tt.want, record.TTL)
+			}
+		})
+	}
+}
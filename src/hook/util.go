@@ -2,32 +2,134 @@ package hook
 
 import (
 	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
 	"github.com/labbsr0x/bindman-dns-webhook/src/types"
 	"github.com/sirupsen/logrus"
-	"net/http"
 )
 
-// write200Response writes the response to be sent
-func writeJSONResponse(payload interface{}, statusCode int, w http.ResponseWriter) {
-	// Headers must be set before call WriteHeader or Write. see https://golang.org/pkg/net/http/#ResponseWriter
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+// errorHandler is an http handler that returns its errors instead of writing them, so they are all translated into
+// responses by a single place
+type errorHandler func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls the handler and writes the error it returns, unless the handler already started the response
+func (h errorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &responseWriter{ResponseWriter: w}
+	if err := h(rw, r); err != nil {
+		if rw.written {
+			logrus.Errorf("Error after the response to %s %s was sent: %v", r.Method, r.URL.Path, err)
+			return
+		}
+		writeError(w, r, err)
+	}
+}
+
+// writeError translates the error into a response, see types.AsError. Errors that are neither a types.Error nor a
+// sentinel error are sent as internal server errors, without details
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	e := types.AsError(err)
+	if e.Code >= http.StatusInternalServerError {
+		logrus.Errorf("%s %s failed: %v", r.Method, r.URL.Path, err)
+	} else {
+		logrus.Infof("%s %s rejected: %v", r.Method, r.URL.Path, err)
+	}
+	var payload interface{} = e
+	contentType := "application/json"
+	if acceptsProblem(r) {
+		payload, contentType = e.Problem(r.URL.Path), types.ProblemContentType
+	}
+	if err := writeResponse(payload, contentType, e.Code, w); err != nil {
+		logrus.Errorf("Error encoding the error response: %v", err)
+		w.WriteHeader(e.Code)
+	}
+}
+
+// acceptsProblem tells if the request asks for errors as RFC 7807 problem details on its Accept header
+func acceptsProblem(r *http.Request) bool {
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == types.ProblemContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONResponse writes the payload encoded as JSON with the status code. The payload is encoded before anything is
+// written, so an encoding error can still be sent to the caller
+func writeJSONResponse(payload interface{}, statusCode int, w http.ResponseWriter) error {
+	return writeResponse(payload, "application/json", statusCode, w)
+}
+
+// writeResponse writes the payload encoded as JSON with the content type and the status code
+func writeResponse(payload interface{}, contentType string, statusCode int, w http.ResponseWriter) error {
+	var body []byte
 	if payload != nil {
-		types.PanicIfError(json.NewEncoder(w).Encode(payload))
+		var err error
+		if body, err = json.Marshal(payload); err != nil {
+			return err
+		}
+		body = append(body, '\n')
 	}
 
+	// Headers must be set before call WriteHeader or Write. see https://golang.org/pkg/net/http/#ResponseWriter
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body); err != nil {
+		logrus.Errorf("Error writing the response: %v", err)
+	}
 	logrus.Infof("%d Response sent. Payload: %#v", statusCode, payload)
+	return nil
 }
 
-// handleError recovers from a panic
-func handleError(w http.ResponseWriter) {
-	r := recover()
-	if r != nil {
-		err := types.InternalServerError("An internal server error occurred, please contact the system administrator.", nil)
-		if e, ok := r.(*types.Error); ok {
-			err = e
-		}
-		logrus.Error(err)
-		writeJSONResponse(err, err.Code, w)
+// recoverPanics recovers the panics of the handler, logging them with their stack trace and counting them with
+// countPanic. An internal server error is sent when the response was not started yet
+func recoverPanics(next http.HandlerFunc, countPanic func(r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rw := &responseWriter{ResponseWriter: w}
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+			logrus.Errorf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, recovered, debug.Stack())
+			if countPanic != nil {
+				countPanic(r)
+			}
+			if !rw.written {
+				writeError(w, r, fmt.Errorf("panic: %v", recovered))
+			}
+		}()
+		next.ServeHTTP(rw, r)
+	}
+}
+
+// responseWriter tracks whether the response was started
+type responseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush sends the buffered data to the client, when the underlying writer supports it
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.written = true
+		flusher.Flush()
 	}
 }
@@ -55,51 +55,151 @@ func Test_writeJSONResponse(t *testing.T) {
 	}
 }
 
-func Test_handleError(t *testing.T) {
-	type args struct {
-		e error
-	}
+func Test_writeError(t *testing.T) {
 	type expected struct {
 		code int
 		body string
 	}
 	tests := []struct {
 		name     string
-		args     args
+		err      error
 		expected expected
 	}{
-		{"do not write any response when recover function return nil",
-			args{nil},
-			expected{httptest.NewRecorder().Code, httptest.NewRecorder().Body.String()},
+		{"must write status code and body from the error when it is instance of types.Error",
+			types.BadRequestError("error", nil),
+			expected{http.StatusBadRequest, "{\"message\":\"error\",\"code\":400,\"errorCode\":\"BAD_REQUEST\"}\n"},
+		},
+		{"must write status code and body from a wrapped types.Error",
+			fmt.Errorf("wrapped: %w", types.NotFoundError("not found", nil)),
+			expected{http.StatusNotFound, "{\"message\":\"not found\",\"code\":404,\"errorCode\":\"NOT_FOUND\"}\n"},
+		},
+		{"must write the status code of a sentinel error",
+			fmt.Errorf("getting record: %w", types.ErrNotFound),
+			expected{http.StatusNotFound, "{\"message\":\"Record not found\",\"code\":404,\"errorCode\":\"RECORD_NOT_FOUND\",\"details\":[\"getting record: record not found\"]}\n"},
 		},
-		{"must write status code and body from error recovered when it is instance of types.Error ",
-			args{types.BadRequestError("error", nil)},
-			expected{http.StatusBadRequest, "{\"message\":\"error\",\"code\":400}\n"},
+		{"must write the status code of a sentinel error without details",
+			types.ErrBackendUnavailable,
+			expected{http.StatusServiceUnavailable, "{\"message\":\"The DNS backend is unavailable\",\"code\":503,\"errorCode\":\"BACKEND_UNAVAILABLE\"}\n"},
 		},
-		{"must write a default internal server error when recovered error is not instance of types.Error ",
-			args{fmt.Errorf("unknow error")},
-			expected{http.StatusInternalServerError, "{\"message\":\"An internal server error occurred, please contact the system administrator.\",\"code\":500}\n"},
+		{"must write a default internal server error when the error is not instance of types.Error",
+			fmt.Errorf("unknow error"),
+			expected{http.StatusInternalServerError, "{\"message\":\"An internal server error occurred, please contact the system administrator.\",\"code\":500,\"errorCode\":\"INTERNAL_ERROR\"}\n"},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			res := httptest.NewRecorder()
-			defer func() {
-				if tt.expected.code != res.Code {
-					t.Errorf("expected code %d, got %d", tt.expected.code, res.Code)
-				}
-				resBody := res.Body.String()
-				if tt.expected.body != resBody {
-					t.Errorf("expected code %s, got %s", tt.expected.body, resBody)
-				}
-				if tt.expected.body != "" {
-					if res.Header().Get("Content-Type") != "application/json" {
-						t.Error("the content type header value must be 'application/json' when non-empty body")
-					}
+			writeError(res, httptest.NewRequest("GET", "/records", nil), tt.err)
+			if tt.expected.code != res.Code {
+				t.Errorf("expected code %d, got %d", tt.expected.code, res.Code)
+			}
+			if resBody := res.Body.String(); tt.expected.body != resBody {
+				t.Errorf("expected body %s, got %s", tt.expected.body, resBody)
+			}
+			if res.Header().Get("Content-Type") != "application/json" {
+				t.Error("the content type header value must be 'application/json' when non-empty body")
+			}
+		})
+	}
+}
+
+func Test_writeError_problem(t *testing.T) {
+	tests := []struct {
+		name    string
+		accept  string
+		problem bool
+	}{
+		{"problem details when asked", "application/problem+json", true},
+		{"problem details among other media types", "application/json;q=0.9, application/problem+json", true},
+		{"plain errors by default", "", false},
+		{"plain errors when asking for json", "application/json", false},
+	}
+	err := types.ValidationError("invalid", []types.FieldError{{Field: "ttl", Code: types.CodeOutOfRange, Message: "out of range"}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			req := httptest.NewRequest("PUT", "/records", nil)
+			req.Header.Set("Accept", tt.accept)
+			writeError(res, req, err)
+			if res.Code != http.StatusBadRequest {
+				t.Errorf("expected code %d, got %d", http.StatusBadRequest, res.Code)
+			}
+			if !tt.problem {
+				if contentType := res.Header().Get("Content-Type"); contentType != "application/json" {
+					t.Errorf("expected content type 'application/json', got '%s'", contentType)
 				}
-			}()
-			defer handleError(res)
-			panic(tt.args.e)
+				return
+			}
+			if contentType := res.Header().Get("Content-Type"); contentType != types.ProblemContentType {
+				t.Errorf("expected content type '%s', got '%s'", types.ProblemContentType, contentType)
+			}
+			expected := "{\"type\":\"urn:bindman:error:INVALID_VALUE\",\"title\":\"Bad Request\",\"status\":400,\"detail\":\"invalid\",\"instance\":\"/records\"," +
+				"\"errorCode\":\"INVALID_VALUE\",\"details\":[\"out of range\"],\"fields\":[{\"field\":\"ttl\",\"code\":\"OUT_OF_RANGE\",\"message\":\"out of range\"}]}\n"
+			if body := res.Body.String(); body != expected {
+				t.Errorf("expected body %s, got %s", expected, body)
+			}
 		})
 	}
 }
+
+func Test_errorHandler(t *testing.T) {
+	t.Run("write the returned error", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			return types.BadRequestError("error", nil)
+		}).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("expected code %d, got %d", http.StatusBadRequest, res.Code)
+		}
+	})
+	t.Run("keep the response already started", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusNoContent)
+			return types.BadRequestError("error", nil)
+		}).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+		if res.Code != http.StatusNoContent || res.Body.Len() != 0 {
+			t.Errorf("expected the response to be kept, got %d %s", res.Code, res.Body.String())
+		}
+	})
+	t.Run("report payloads that cannot be encoded", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+			return writeJSONResponse(func() {}, http.StatusOK, w)
+		}).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+		if res.Code != http.StatusInternalServerError {
+			t.Errorf("expected code %d, got %d", http.StatusInternalServerError, res.Code)
+		}
+	})
+}
+
+func Test_recoverPanics(t *testing.T) {
+	panics := 0
+	count := func(r *http.Request) { panics++ }
+
+	res := httptest.NewRecorder()
+	recoverPanics(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, count).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if res.Code != http.StatusInternalServerError || panics != 1 {
+		t.Errorf("expected an internal server error and a counted panic, got %d and %d panics", res.Code, panics)
+	}
+
+	res = httptest.NewRecorder()
+	recoverPanics(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	}, count).ServeHTTP(res, httptest.NewRequest("GET", "/", nil))
+	if res.Code != http.StatusAccepted || panics != 2 {
+		t.Errorf("expected the response to be kept and a counted panic, got %d and %d panics", res.Code, panics)
+	}
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Error("expected http.ErrAbortHandler to be propagated")
+		}
+	}()
+	recoverPanics(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}, count).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,248 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultWatchBuffer is how many events are kept to be replayed to the watchers resuming a stream, when no other
+	// size is configured
+	DefaultWatchBuffer = 1000
+
+	// watchHeartbeat is how often a comment is sent on idle streams, so proxies do not close them
+	watchHeartbeat = 15 * time.Second
+
+	// watcherBuffer is how many events may wait to be sent to a watcher. Slower watchers are disconnected, resuming
+	// their stream from the buffer of the broker once they reconnect
+	watcherBuffer = 64
+)
+
+// WatchDNSRecords streams the changes of the records made through the webhook as Server-Sent Events, each one holding
+// a types.RecordEvent. Streams resume after the event given by the Last-Event-ID header or the lastEventId query
+// parameter, replaying the missed events; a reset event is sent when some of them are no longer kept
+func (m *DNSWebhook) WatchDNSRecords(w http.ResponseWriter, r *http.Request) {
+	errorHandler(m.watchDNSRecords).ServeHTTP(w, r)
+}
+
+func (m *DNSWebhook) watchDNSRecords(w http.ResponseWriter, r *http.Request) error {
+	logrus.Infof("WatchDNSRecords call. Http Request: %v", r)
+
+	if err := m.authorize(r, VerbList, "", ""); err != nil {
+		return err
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok || m.events == nil {
+		return types.InternalServerError("Streaming is not supported by the server", nil)
+	}
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	replay, watcher, err := m.events.subscribe(lastID)
+	if err != nil {
+		return err
+	}
+	defer m.events.unsubscribe(watcher)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	caller := CallerFromContext(r.Context())
+	send := func(event types.RecordEvent) error {
+		if event.Op != types.EventReset && m.Policy != nil && m.Policy.Authorize(caller, VerbList, event.Name, event.Type) != nil {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Op, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	for _, event := range replay {
+		if err := send(event); err != nil {
+			return err
+		}
+	}
+
+	heartbeat := time.NewTicker(watchHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return nil
+		case event, open := <-watcher:
+			if !open {
+				return nil
+			}
+			if err := send(event); err != nil {
+				return err
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return err
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// notify publishes the change of a record, or of a RRSet, to the watchers and to the subscriptions. The records and
+// sets of the event are copied
+func (m *DNSWebhook) notify(event types.RecordEvent) {
+	event.Record, event.Before = syncedRecord(event.Record), syncedRecord(event.Before)
+	event.RRSet, event.RRSetBefore = syncedRRSet(event.RRSet), syncedRRSet(event.RRSetBefore)
+	if m.events != nil {
+		event = m.events.publish(event)
+	}
+	if m.subscriptions != nil {
+		m.subscriptions.publish(event)
+	}
+}
+
+// syncedRecord returns a copy of the record with both representations of its value and the Unicode form of its name
+func syncedRecord(record *types.DNSRecord) *types.DNSRecord {
+	if record == nil {
+		return nil
+	}
+	synced := *record
+	synced.SyncValue()
+	synced.SyncUnicodeName()
+	return &synced
+}
+
+// syncedRRSet returns a copy of the set with the Unicode form of its name
+func syncedRRSet(set *types.RRSet) *types.RRSet {
+	if set == nil {
+		return nil
+	}
+	synced := *set
+	synced.SyncUnicodeName()
+	return &synced
+}
+
+// eventBroker hands the events to the watchers, keeping the latest ones so interrupted streams can resume. Event IDs
+// are made of the epoch of the broker and a sequence number, so IDs given by a previous run of the webhook are told
+// apart
+type eventBroker struct {
+	epoch string
+	size  int
+
+	mu       sync.Mutex
+	closed   bool
+	seq      uint64
+	events   []types.RecordEvent
+	watchers map[chan types.RecordEvent]bool
+}
+
+// newEventBroker builds a broker keeping the last size events
+func newEventBroker(size int) *eventBroker {
+	return &eventBroker{
+		epoch:    strconv.FormatInt(time.Now().UnixNano(), 36),
+		size:     size,
+		watchers: map[chan types.RecordEvent]bool{},
+	}
+}
+
+// publish assigns the next ID to the event, keeps it and hands it to the watchers, returning it. Watchers too slow to
+// take it are disconnected. Once closed, the event gets its ID but is neither kept nor handed
+func (b *eventBroker) publish(event types.RecordEvent) types.RecordEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	event.ID = b.id(b.seq)
+	event.Time = time.Now().UTC()
+	if b.closed {
+		return event
+	}
+	b.events = append(b.events, event)
+	if len(b.events) > b.size {
+		b.events = b.events[len(b.events)-b.size:]
+	}
+	for watcher := range b.watchers {
+		select {
+		case watcher <- event:
+		default:
+			logrus.Warnf("Disconnecting a watcher that fell %d events behind", watcherBuffer)
+			delete(b.watchers, watcher)
+			close(watcher)
+		}
+	}
+	return event
+}
+
+// subscribe registers a watcher, returning the events following lastID that it missed. When some of them are no
+// longer kept, or lastID is unknown, a reset event is returned instead
+func (b *eventBroker) subscribe(lastID string) ([]types.RecordEvent, chan types.RecordEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, types.ServiceUnavailableError("The webhook is shutting down, try again later", nil).WithErrorCode(types.CodeShuttingDown)
+	}
+
+	var replay []types.RecordEvent
+	if lastID != "" {
+		oldest := b.seq - uint64(len(b.events)) + 1
+		last, known := b.parseID(lastID)
+		switch {
+		case !known || last > b.seq || last+1 < oldest:
+			replay = []types.RecordEvent{{ID: b.id(b.seq), Op: types.EventReset, Time: time.Now().UTC()}}
+		case last < b.seq:
+			replay = append(replay, b.events[last+1-oldest:]...)
+		}
+	}
+	watcher := make(chan types.RecordEvent, watcherBuffer)
+	b.watchers[watcher] = true
+	return replay, watcher, nil
+}
+
+// unsubscribe removes the watcher, unless it was already disconnected
+func (b *eventBroker) unsubscribe(watcher chan types.RecordEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.watchers[watcher] {
+		delete(b.watchers, watcher)
+		close(watcher)
+	}
+}
+
+// close disconnects every watcher and stops accepting new ones, so the streams end
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for watcher := range b.watchers {
+		delete(b.watchers, watcher)
+		close(watcher)
+	}
+}
+
+// id returns the ID of the event with the sequence number
+func (b *eventBroker) id(seq uint64) string {
+	return b.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// parseID returns the sequence number of an ID given by the broker, telling if it comes from it
+func (b *eventBroker) parseID(id string) (uint64, bool) {
+	i := strings.LastIndex(id, "-")
+	if i < 0 || id[:i] != b.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	return seq, err == nil
+}
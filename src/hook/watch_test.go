@@ -0,0 +1,130 @@
+package hook
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labbsr0x/bindman-dns-webhook/src/types"
+)
+
+func TestDNSWebhook_WatchDNSRecords(t *testing.T) {
+	server, err := New(newMemoryDNSManagerMock(), "1", WithWatchBuffer(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer := httptest.NewServer(server.Handler())
+	defer httpServer.Close()
+
+	change := func(method, path, body string) {
+		res := httptest.NewRecorder()
+		server.Handler().ServeHTTP(res, httptest.NewRequest(method, path, strings.NewReader(body)))
+		if res.Code >= 300 {
+			t.Fatalf("unexpected response %d %s", res.Code, res.Body.String())
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := watch(ctx, t, httpServer.URL+"/records/watch", "")
+	change("POST", "/records", `{"name":"a.test.com","type":"A","value":"10.0.0.1"}`)
+	change("PUT", "/records", `{"name":"a.test.com","type":"A","value":"10.0.0.2"}`)
+	change("DELETE", "/records/a.test.com/A", "")
+	change("PUT", "/rrsets/b.test.com/A", `{"values":["10.0.0.3"]}`)
+
+	var got []types.RecordEvent
+	for i := 0; i < 4; i++ {
+		select {
+		case event := <-events:
+			got = append(got, event)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected 4 events, got %+v", got)
+		}
+	}
+	if got[0].Op != types.BatchAdd || got[1].Op != types.BatchUpdate || got[1].Record.Value != "10.0.0.2" ||
+		got[2].Op != types.BatchDelete || got[2].Name != "a.test.com" || got[3].RRSet == nil || len(got[3].RRSet.Values) != 1 {
+		t.Errorf("unexpected events %+v", got)
+	}
+
+	tests := []struct {
+		name    string
+		lastID  string
+		wantOps []string
+	}{
+		{"resumed", got[1].ID, []string{types.BatchDelete, types.BatchAdd}},
+		{"missed events", got[0].ID, []string{types.EventReset}},
+		{"unknown event", "other-1", []string{types.EventReset}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events := watch(ctx, t, httpServer.URL+"/records/watch", tt.lastID)
+			for _, op := range tt.wantOps {
+				select {
+				case event := <-events:
+					if event.Op != op {
+						t.Errorf("want a %s event, got %+v", op, event)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("expected a %s event", op)
+				}
+			}
+		})
+	}
+
+	cancel()
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Errorf("expected the streams to end on shutdown, got %v", err)
+	}
+}
+
+func Test_eventBroker_slowWatcher(t *testing.T) {
+	b := newEventBroker(DefaultWatchBuffer)
+	_, watcher, _ := b.subscribe("")
+	for i := 0; i <= watcherBuffer; i++ {
+		b.publish(types.RecordEvent{Op: types.BatchAdd})
+	}
+	received := 0
+	for range watcher {
+		received++
+	}
+	if received != watcherBuffer {
+		t.Errorf("expected the slow watcher to be disconnected after %d events, got %d", watcherBuffer, received)
+	}
+	b.unsubscribe(watcher)
+}
+
+// watch streams the events of the url, resuming after lastID when given
+func watch(ctx context.Context, t *testing.T, url, lastID string) <-chan types.RecordEvent {
+	req, _ := http.NewRequest("GET", url, nil)
+	req = req.WithContext(ctx)
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+	events := make(chan types.RecordEvent, 10)
+	go func() {
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data := strings.TrimPrefix(scanner.Text(), "data: "); data != scanner.Text() {
+				var event types.RecordEvent
+				_ = json.Unmarshal([]byte(data), &event)
+				events <- event
+			}
+		}
+	}()
+	return events
+}
@@ -0,0 +1,218 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// checkInterval is the minimum interval between two checks for changes on the certificate files
+const checkInterval = time.Second
+
+// Reloader holds a certificate/key pair and a CA bundle loaded from disk. Files are checked for changes at most once per
+// second while handshakes happen, so rotated certificates are picked up without a restart
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+// NewReloader loads the given files. certFile and keyFile must be both empty or both set; caFile is optional
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	if (strings.TrimSpace(certFile) == "") != (strings.TrimSpace(keyFile) == "") {
+		return nil, errors.New("the certificate and key files must be provided together")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile, modTimes: map[string]time.Time{}}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads all the configured files
+func (r *Reloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return err
+		}
+		modTimes[file] = info.ModTime()
+	}
+
+	var cert *tls.Certificate
+	if r.certFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+		if err != nil {
+			return fmt.Errorf("error loading the certificate '%s': %v", r.certFile, err)
+		}
+		cert = &pair
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pem, err := ioutil.ReadFile(r.caFile)
+		if err != nil {
+			return err
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate could be parsed from the CA bundle '%s'", r.caFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert, r.pool, r.modTimes, r.lastCheck = cert, pool, modTimes, time.Now()
+	return nil
+}
+
+// reloadIfChanged reloads the files when any of them changed since the last load. On errors the current files are kept
+func (r *Reloader) reloadIfChanged() {
+	r.mu.RLock()
+	due := time.Since(r.lastCheck) >= checkInterval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+
+	changed := false
+	for file, modTime := range r.currentModTimes() {
+		info, err := os.Stat(file)
+		if err == nil && !info.ModTime().Equal(modTime) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		r.mu.Lock()
+		r.lastCheck = time.Now()
+		r.mu.Unlock()
+		return
+	}
+	if err := r.load(); err != nil {
+		logrus.Errorf("Error reloading the TLS files, keeping the current ones: %v", err)
+		r.mu.Lock()
+		r.lastCheck = time.Now()
+		r.mu.Unlock()
+		return
+	}
+	logrus.Info("TLS files reloaded")
+}
+
+func (r *Reloader) currentModTimes() map[string]time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.modTimes
+}
+
+// Certificate returns the current certificate/key pair
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.reloadIfChanged()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// CertPool returns the current CA bundle
+func (r *Reloader) CertPool() *x509.CertPool {
+	r.reloadIfChanged()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// Server builds a server tls.Config serving the certificate/key pair of the reloader. When a CA bundle is configured,
+// clients must present a certificate signed by it
+func Server(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if strings.TrimSpace(certFile) == "" {
+		return nil, errors.New("a certificate and a key file are required to serve TLS")
+	}
+	r, err := NewReloader(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		},
+	}
+	if clientCAFile != "" {
+		config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			clientConfig := config.Clone()
+			clientConfig.GetConfigForClient = nil
+			clientConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			clientConfig.ClientCAs = r.CertPool()
+			return clientConfig, nil
+		}
+	}
+	return config, nil
+}
+
+// Client builds a client tls.Config. The server certificate is verified against the CA bundle when one is provided, or
+// against the system roots otherwise; the certificate/key pair, if any, is presented to servers asking for it. The
+// server name is required along with a CA bundle, as the certificate would be accepted for any name otherwise
+func Client(caFile, certFile, keyFile, serverName string) (*tls.Config, error) {
+	if caFile != "" && serverName == "" {
+		return nil, errors.New("a server name is required to verify the server certificate against the CA bundle")
+	}
+	r, err := NewReloader(certFile, keyFile, caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if certFile != "" {
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		}
+	}
+	if caFile != "" {
+		// the standard verification would pin the CA bundle loaded now, so it is done here against the current one
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verify(rawCerts, r.CertPool(), serverName)
+		}
+	}
+	return config, nil
+}
+
+// verify checks the chain presented by a server against the given roots and server name
+func verify(rawCerts [][]byte, roots *x509.CertPool, serverName string) error {
+	if len(rawCerts) == 0 {
+		return errors.New("the server did not present a certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs[i] = cert
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{DNSName: serverName, Roots: roots, Intermediates: intermediates})
+	return err
+}
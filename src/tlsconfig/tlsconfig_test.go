@@ -0,0 +1,228 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewReloader(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ca := newCA(t, "ca")
+	certFile, keyFile := ca.issue(t, dir, "server", "localhost")
+	caFile := ca.write(t, dir, "ca")
+
+	tests := []struct {
+		name    string
+		cert    string
+		key     string
+		ca      string
+		wantErr bool
+	}{
+		{"certificate, key and CA", certFile, keyFile, caFile, false},
+		{"only CA", "", "", caFile, false},
+		{"certificate without key", certFile, "", "", true},
+		{"missing files", filepath.Join(dir, "missing.pem"), keyFile, "", true},
+		{"invalid CA bundle", "", "", keyFile, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReloader(tt.cert, tt.key, tt.ca)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewReloader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (r.Certificate() != nil) != (tt.cert != "") {
+				t.Errorf("unexpected certificate %v", r.Certificate())
+			}
+		})
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	ca := newCA(t, "ca")
+	caFile := ca.write(t, dir, "ca")
+	serverCert, serverKey := ca.issue(t, dir, "server", "localhost")
+	clientCert, clientKey := ca.issue(t, dir, "client", "")
+
+	serverConfig, err := Server(serverCert, serverKey, caFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	get := func(config *tls.Config) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := client.Get("https://localhost:" + port(server))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	t.Run("client certificate signed by the CA", func(t *testing.T) {
+		config, err := Client(caFile, clientCert, clientKey, "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, err := get(config); err != nil || body != "client" {
+			t.Errorf("want 'client', got '%s' and error %v", body, err)
+		}
+	})
+	t.Run("no client certificate", func(t *testing.T) {
+		config, err := Client(caFile, "", "", "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := get(config); err == nil {
+			t.Error("the server must reject clients without certificate")
+		}
+	})
+	t.Run("unexpected server name", func(t *testing.T) {
+		config, err := Client(caFile, clientCert, clientKey, "other.host")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := get(config); err == nil {
+			t.Error("the client must reject a certificate issued to another name")
+		}
+	})
+	t.Run("no server name", func(t *testing.T) {
+		if _, err := Client(caFile, clientCert, clientKey, ""); err == nil {
+			t.Error("the client must require a server name to verify the certificate against the CA bundle")
+		}
+	})
+	t.Run("server certificate is reloaded", func(t *testing.T) {
+		rotated := newCA(t, "rotated")
+		rotatedCAFile := rotated.write(t, dir, "rotated-ca")
+		rotated.issue(t, dir, "server", "localhost")
+		future := time.Now().Add(time.Hour)
+		for _, file := range []string{serverCert, serverKey} {
+			if err := os.Chtimes(file, future, future); err != nil {
+				t.Fatal(err)
+			}
+		}
+		time.Sleep(checkInterval)
+
+		config, err := Client(rotatedCAFile, clientCert, clientKey, "localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the client certificate is still signed by the CA the server trusts
+		if body, err := get(config); err != nil || body != "client" {
+			t.Errorf("want 'client', got '%s' and error %v", body, err)
+		}
+	})
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newCA(t *testing.T, name string) *testCA {
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert, key}
+}
+
+// write writes the CA certificate on dir and returns its path
+func (ca *testCA) write(t *testing.T, dir, name string) string {
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", ca.cert.Raw)
+	return file
+}
+
+// issue writes a certificate signed by the CA and its key on dir and returns their paths
+func (ca *testCA) issue(t *testing.T, dir, name, dnsName string) (string, string) {
+	key := newKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if dnsName != "" {
+		template.DNSNames = []string{dnsName}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDer)
+	return certFile, keyFile
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func writePEM(t *testing.T, file, blockType string, der []byte) {
+	if err := ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "tlsconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func port(server *httptest.Server) string {
+	_, p, _ := net.SplitHostPort(server.Listener.Addr().String())
+	return p
+}
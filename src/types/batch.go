@@ -0,0 +1,70 @@
+package types
+
+import "context"
+
+// Operations of a batch
+const (
+	// BatchAdd adds a new record
+	BatchAdd = "add"
+
+	// BatchUpdate updates an existing record
+	BatchUpdate = "update"
+
+	// BatchDelete removes an existing record. Only the name and type of the record are used
+	BatchDelete = "delete"
+)
+
+// BatchOperation is a change of a batch, see Batch
+type BatchOperation struct {
+	// Op the operation: add, update or delete
+	Op string `json:"op"`
+
+	// Record the record to add or update, or the name and type of the record to delete
+	Record DNSRecord `json:"record"`
+}
+
+// Batch groups changes of records applied all or nothing. A batch may change each name and type only once
+type Batch struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchResult reports the outcome of a batch and of each of its operations, in the order they were given
+type BatchResult struct {
+	// Applied tells if every operation was applied. Otherwise none was, unless rolling back failed
+	Applied bool `json:"applied"`
+
+	// Results the outcome of each operation
+	Results []BatchOperationResult `json:"results"`
+
+	// Error the reason the batch was not applied
+	Error *Error `json:"error,omitempty"`
+}
+
+// BatchOperationResult reports the outcome of an operation of a batch
+type BatchOperationResult struct {
+	// Op the operation
+	Op string `json:"op"`
+
+	// Name the name of the record
+	Name string `json:"name"`
+
+	// Type the type of the record
+	Type string `json:"type"`
+
+	// Status the HTTP status code of the operation on its own: 201 for added records, 200 for updated ones, 204 for
+	// deleted ones, 424 for operations not applied, or rolled back, because of another one, and the code of the error
+	// otherwise
+	Status int `json:"status"`
+
+	// Error the reason the operation failed
+	Error *Error `json:"error,omitempty"`
+}
+
+// BatchManager can optionally be implemented by a DNSManager that applies several changes atomically. Otherwise the
+// webhook applies the operations of a batch one by one, undoing the completed ones when one of them fails
+type BatchManager interface {
+
+	// ApplyBatch applies every operation or none of them. The operations are valid and consistent with the current
+	// records: added records do not exist, updated and deleted records do
+	ApplyBatch(ctx context.Context, operations []BatchOperation) error
+}
@@ -0,0 +1,47 @@
+package types
+
+import "time"
+
+// Statuses of an asynchronous change
+const (
+	// ChangePending the change is queued or being applied
+	ChangePending = "pending"
+
+	// ChangeApplied the change was applied
+	ChangeApplied = "applied"
+
+	// ChangeFailed the change was not applied, see Change.Error
+	ChangeFailed = "failed"
+)
+
+// Change reports the progress of a change of a record applied asynchronously, see the async mode of the hook
+type Change struct {
+	// ID identifies the change on /changes/{id}
+	ID string `json:"id"`
+
+	// Op the operation: add, update or delete, as on BatchOperation
+	Op string `json:"op"`
+
+	// Name the name of the record
+	Name string `json:"name"`
+
+	// Type the type of the record
+	Type string `json:"type"`
+
+	// Status pending, applied or failed
+	Status string `json:"status"`
+
+	// Error the reason the change failed
+	Error *Error `json:"error,omitempty"`
+
+	// SubmittedAt when the change was accepted
+	SubmittedAt time.Time `json:"submittedAt"`
+
+	// CompletedAt when the change was applied or failed
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// Done tells if the change is no longer pending
+func (c *Change) Done() bool {
+	return c.Status == ChangeApplied || c.Status == ChangeFailed
+}
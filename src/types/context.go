@@ -0,0 +1,141 @@
+package types
+
+import (
+	"context"
+	"io"
+)
+
+// ContextAdapter returns the ContextDNSManager view of a DNSManager: the manager itself when it implements
+// ContextDNSManager, or an adapter calling the context-free operations otherwise. The adapter checks the context is not
+// done before each call, but cannot interrupt a call in progress
+func ContextAdapter(manager DNSManager) ContextDNSManager {
+	if contextManager, ok := manager.(ContextDNSManager); ok {
+		return contextManager
+	}
+	return contextAdapter{manager}
+}
+
+// contextAdapter adapts a DNSManager to the ContextDNSManager interface
+type contextAdapter struct {
+	manager DNSManager
+}
+
+func (a contextAdapter) GetDNSRecordsContext(ctx context.Context) ([]DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.manager.GetDNSRecords()
+}
+
+func (a contextAdapter) GetDNSRecordContext(ctx context.Context, name, recordType string) (*DNSRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.manager.GetDNSRecord(name, recordType)
+}
+
+func (a contextAdapter) RemoveDNSRecordContext(ctx context.Context, name, recordType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.manager.RemoveDNSRecord(name, recordType)
+}
+
+func (a contextAdapter) AddDNSRecordContext(ctx context.Context, record DNSRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.manager.AddDNSRecord(record)
+}
+
+func (a contextAdapter) UpdateDNSRecordContext(ctx context.Context, record DNSRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.manager.UpdateDNSRecord(record)
+}
+
+// RRSetContextAdapter returns the ContextRRSetManager view of a RRSetManager: the manager itself when it implements
+// ContextRRSetManager, or an adapter calling the context-free operations otherwise. As ContextAdapter, it checks the
+// context is not done before each call
+func RRSetContextAdapter(manager RRSetManager) ContextRRSetManager {
+	if contextManager, ok := manager.(ContextRRSetManager); ok {
+		return contextManager
+	}
+	return rrSetContextAdapter{manager}
+}
+
+// rrSetContextAdapter adapts a RRSetManager to the ContextRRSetManager interface
+type rrSetContextAdapter struct {
+	manager RRSetManager
+}
+
+func (a rrSetContextAdapter) GetRRSetsContext(ctx context.Context) ([]RRSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.manager.GetRRSets()
+}
+
+func (a rrSetContextAdapter) GetRRSetContext(ctx context.Context, name, recordType string) (*RRSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.manager.GetRRSet(name, recordType)
+}
+
+func (a rrSetContextAdapter) ReplaceRRSetContext(ctx context.Context, set RRSet) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.manager.ReplaceRRSet(set)
+}
+
+func (a rrSetContextAdapter) RemoveRRSetContext(ctx context.Context, name, recordType string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.manager.RemoveRRSet(name, recordType)
+}
+
+// contextDNSManager exposes a ContextDNSManager through the DNSManager interface
+type contextDNSManager struct {
+	ContextDNSManager
+}
+
+// NewContextDNSManager returns a DNSManager backed by a ContextDNSManager, so that managers only need to implement the
+// context-aware operations to be given to the webhook. The context-free operations use context.Background()
+func NewContextDNSManager(manager ContextDNSManager) DNSManager {
+	return &contextDNSManager{ContextDNSManager: manager}
+}
+
+func (m *contextDNSManager) GetDNSRecords() ([]DNSRecord, error) {
+	return m.GetDNSRecordsContext(context.Background())
+}
+
+func (m *contextDNSManager) GetDNSRecord(name, recordType string) (*DNSRecord, error) {
+	return m.GetDNSRecordContext(context.Background(), name, recordType)
+}
+
+func (m *contextDNSManager) RemoveDNSRecord(name, recordType string) error {
+	return m.RemoveDNSRecordContext(context.Background(), name, recordType)
+}
+
+func (m *contextDNSManager) AddDNSRecord(record DNSRecord) error {
+	return m.AddDNSRecordContext(context.Background(), record)
+}
+
+func (m *contextDNSManager) UpdateDNSRecord(record DNSRecord) error {
+	return m.UpdateDNSRecordContext(context.Background(), record)
+}
+
+// Shutdown forwards the shutdown to the ContextDNSManager when it is a Shutdowner or an io.Closer
+func (m *contextDNSManager) Shutdown(ctx context.Context) error {
+	switch manager := m.ContextDNSManager.(type) {
+	case Shutdowner:
+		return manager.Shutdown(ctx)
+	case io.Closer:
+		return manager.Close()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestContextAdapter(t *testing.T) {
+	manager := NewContextDNSManager(&contextManagerMock{})
+	if got := ContextAdapter(manager); got != manager.(ContextDNSManager) {
+		t.Error("expected a ContextDNSManager to be returned as it is")
+	}
+
+	legacy := NewRRSetDNSManager(&rrSetManagerMock{sets: map[string]RRSet{}})
+	adapter := ContextAdapter(legacy)
+	if err := adapter.AddDNSRecordContext(context.Background(), DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	if record, err := adapter.GetDNSRecordContext(context.Background(), "test.com", "A"); err != nil || record == nil {
+		t.Errorf("expected the added record, got %+v, %v", record, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := adapter.GetDNSRecordsContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the adapter to honor a canceled context, got %v", err)
+	}
+	if err := adapter.RemoveDNSRecordContext(ctx, "test.com", "A"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the adapter to honor a canceled context, got %v", err)
+	}
+	if record, _ := legacy.GetDNSRecord("test.com", "A"); record == nil {
+		t.Error("the record must not be removed with a canceled context")
+	}
+}
+
+func TestRRSetContextAdapter(t *testing.T) {
+	mock := &rrSetManagerMock{sets: map[string]RRSet{}}
+	adapter := RRSetContextAdapter(mock)
+	if err := adapter.ReplaceRRSetContext(context.Background(), RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if set, err := adapter.GetRRSetContext(context.Background(), "test.com", "A"); err != nil || set == nil {
+		t.Errorf("expected the replaced set, got %+v, %v", set, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := adapter.RemoveRRSetContext(ctx, "test.com", "A"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the adapter to honor a canceled context, got %v", err)
+	}
+	if _, ok := mock.sets["test.com/A"]; !ok {
+		t.Error("the set must not be removed with a canceled context")
+	}
+}
+
+func TestNewContextDNSManager(t *testing.T) {
+	mock := &contextManagerMock{}
+	manager := NewContextDNSManager(mock)
+	if err := manager.AddDNSRecord(DNSRecord{Name: "test.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if mock.ctx == nil || mock.ctx.Err() != nil {
+		t.Errorf("expected a background context, got %v", mock.ctx)
+	}
+	if _, ok := manager.(ContextDNSManager); !ok {
+		t.Error("expected the DNSManager to keep implementing ContextDNSManager")
+	}
+}
+
+// contextManagerMock records the context of the last call
+type contextManagerMock struct {
+	ctx context.Context
+}
+
+func (m *contextManagerMock) GetDNSRecordsContext(ctx context.Context) ([]DNSRecord, error) {
+	m.ctx = ctx
+	return nil, nil
+}
+
+func (m *contextManagerMock) GetDNSRecordContext(ctx context.Context, name, recordType string) (*DNSRecord, error) {
+	m.ctx = ctx
+	return nil, nil
+}
+
+func (m *contextManagerMock) RemoveDNSRecordContext(ctx context.Context, name, recordType string) error {
+	m.ctx = ctx
+	return nil
+}
+
+func (m *contextManagerMock) AddDNSRecordContext(ctx context.Context, record DNSRecord) error {
+	m.ctx = ctx
+	return nil
+}
+
+func (m *contextManagerMock) UpdateDNSRecordContext(ctx context.Context, record DNSRecord) error {
+	m.ctx = ctx
+	return nil
+}
@@ -1,5 +1,7 @@
 package types
 
+import "context"
+
 // DNSManager defines the operations a DNS Manager provider should implement
 type DNSManager interface {
 
@@ -18,3 +20,76 @@ type DNSManager interface {
 	// UpdateDNSRecord updates an existing DNS record
 	UpdateDNSRecord(record DNSRecord) error
 }
+
+// Shutdowner can optionally be implemented by a DNSManager that needs to flush its state or release resources before
+// the webhook exits. A DNSManager implementing io.Closer is closed as well
+type Shutdowner interface {
+
+	// Shutdown is called once the webhook stops serving requests. ctx expires when the drain timeout is over
+	Shutdown(ctx context.Context) error
+}
+
+// RRSetManager can optionally be implemented by a DNSManager that supports several values for the same name and type.
+// The webhook then serves the RRSets endpoints straight from it. See NewRRSetDNSManager to implement only this interface
+type RRSetManager interface {
+
+	// GetRRSets retrieves all the RRSets being managed
+	GetRRSets() ([]RRSet, error)
+
+	// GetRRSet retrieves the RRSet identified by name and type. Returns nil when there is no such set
+	GetRRSet(name, recordType string) (*RRSet, error)
+
+	// ReplaceRRSet creates the RRSet or replaces all the values of an existing one
+	ReplaceRRSet(set RRSet) error
+
+	// RemoveRRSet removes the RRSet with all its values
+	RemoveRRSet(name, recordType string) error
+}
+
+// ContextRRSetManager defines the RRSet operations that take a context. It can optionally be implemented by a
+// DNSManager supporting several values for the same name and type, instead of or along with RRSetManager, to receive the
+// context of each request on the RRSets endpoints. See RRSetContextAdapter
+type ContextRRSetManager interface {
+
+	// GetRRSetsContext retrieves all the RRSets being managed
+	GetRRSetsContext(ctx context.Context) ([]RRSet, error)
+
+	// GetRRSetContext retrieves the RRSet identified by name and type. Returns nil when there is no such set
+	GetRRSetContext(ctx context.Context, name, recordType string) (*RRSet, error)
+
+	// ReplaceRRSetContext creates the RRSet or replaces all the values of an existing one
+	ReplaceRRSetContext(ctx context.Context, set RRSet) error
+
+	// RemoveRRSetContext removes the RRSet with all its values
+	RemoveRRSetContext(ctx context.Context, name, recordType string) error
+}
+
+// FilteredDNSManager can optionally be implemented by a DNSManager able to filter the records itself, e.g. by querying
+// its backend, instead of listing all of them to the webhook
+type FilteredDNSManager interface {
+
+	// FindDNSRecords retrieves the dns records matching the filter. The webhook filters the result again, so a manager
+	// may apply only some of the conditions
+	FindDNSRecords(ctx context.Context, filter RecordFilter) ([]DNSRecord, error)
+}
+
+// ContextDNSManager defines the operations of a DNS Manager provider that take a context. The webhook passes the
+// context of each request, canceled when the caller disconnects and carrying its deadline and values. See ContextAdapter
+// and NewContextDNSManager to convert between both interfaces
+type ContextDNSManager interface {
+
+	// GetDNSRecordsContext retrieves all the dns records being managed
+	GetDNSRecordsContext(ctx context.Context) ([]DNSRecord, error)
+
+	// GetDNSRecordContext retrieves the dns record identified by name
+	GetDNSRecordContext(ctx context.Context, name, recordType string) (*DNSRecord, error)
+
+	// RemoveDNSRecordContext removes a DNS record
+	RemoveDNSRecordContext(ctx context.Context, name, recordType string) error
+
+	// AddDNSRecordContext adds a new DNS record
+	AddDNSRecordContext(ctx context.Context, record DNSRecord) error
+
+	// UpdateDNSRecordContext updates an existing DNS record
+	UpdateDNSRecordContext(ctx context.Context, record DNSRecord) error
+}
@@ -1,6 +1,8 @@
 package types
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
@@ -12,29 +14,105 @@ type DNSRecord struct {
 	// Name the DNS host name
 	Name string `json:"name"`
 
+	// UnicodeName the Unicode form of an internationalized Name, which always holds the ASCII form. Only set on responses
+	UnicodeName string `json:"unicodeName,omitempty"`
+
 	// Value the value of this record
 	Value string `json:"value"`
 
 	// Type the record type
 	Type string `json:"type"`
+
+	// TTL the time to live of this record, in seconds. Zero means the default TTL of the manager
+	TTL int `json:"ttl,omitempty"`
+
+	// MX the structured value of a MX record. Equivalent to Value in the presentation format
+	MX *MXData `json:"mx,omitempty"`
+
+	// SRV the structured value of a SRV record. Equivalent to Value in the presentation format
+	SRV *SRVData `json:"srv,omitempty"`
+
+	// CAA the structured value of a CAA record. Equivalent to Value in the presentation format
+	CAA *CAAData `json:"caa,omitempty"`
 }
 
-// Check verifies if the DNS record satisfies certain conditions
+// MaxTTL is the greatest TTL a record may have, as defined by RFC 2181
+const MaxTTL = 2147483647
+
+// Check verifies if the DNS record satisfies certain conditions: required fields, the syntax of the name and a value
+// valid for the record type. Returns every violation found
 func (record *DNSRecord) Check() []string {
 	logrus.Infof("Record to check: '%v'", record)
+	return FieldMessages(record.FieldErrors())
+}
+
+// FieldErrors returns the violations found by Check, each one with the field it concerns
+func (record *DNSRecord) FieldErrors() []FieldError {
 	emptyValueErrorMessage := "the value of field '%s' cannot be empty"
-	var errs []string
+	var errs []FieldError
+
+	nameSet := strings.TrimSpace(record.Name) != ""
+	value := record.effectiveValue()
+	valueSet := strings.TrimSpace(value) != ""
+	typeSet := strings.TrimSpace(record.Type) != ""
+
+	if !nameSet {
+		errs = append(errs, FieldError{"name", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "name")})
+	}
 
-	if strings.TrimSpace(record.Name) == "" {
-		errs = append(errs, fmt.Sprintf(emptyValueErrorMessage, "name"))
+	if !valueSet {
+		errs = append(errs, FieldError{"value", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "value")})
 	}
 
-	if strings.TrimSpace(record.Value) == "" {
-		errs = append(errs, fmt.Sprintf(emptyValueErrorMessage, "value"))
+	if !typeSet {
+		errs = append(errs, FieldError{"type", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "type")})
 	}
 
-	if strings.TrimSpace(record.Type) == "" {
-		errs = append(errs, fmt.Sprintf(emptyValueErrorMessage, "type"))
+	if nameSet {
+		errs = append(errs, invalidField("name", validateName("name", record.Name, true))...)
+	}
+	if typeSet {
+		typeErrs := validateType(record.Type)
+		errs = append(errs, invalidField("type", typeErrs)...)
+		if valueSet && typeErrs == nil {
+			errs = append(errs, invalidField("value", validateValue(record.Type, value))...)
+		}
+	}
+	errs = append(errs, record.checkStructured()...)
+
+	if record.TTL < 0 || record.TTL > MaxTTL {
+		errs = append(errs, FieldError{"ttl", CodeOutOfRange, fmt.Sprintf("the value of field 'ttl' must be between 0 and %d", MaxTTL)})
+	}
+	return errs
+}
+
+// invalidField returns an INVALID_VALUE FieldError of the field for each message
+func invalidField(field string, messages []string) []FieldError {
+	var errs []FieldError
+	for _, message := range messages {
+		errs = append(errs, FieldError{field, CodeInvalidValue, message})
 	}
 	return errs
 }
+
+// ETag returns a strong entity tag of the content of the record: its canonical name and type, its TTL and its value.
+// Records differing only by the case of their name or by the representation of their value share the same tag
+func (record *DNSRecord) ETag() string {
+	canonical := record.Canonical()
+	canonical.SyncValue()
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s\n%d\n%s", canonical.Name, canonical.Type, canonical.TTL, canonical.Value)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// SyncUnicodeName sets UnicodeName to the Unicode form of Name when it holds A-labels, clearing it otherwise
+func (record *DNSRecord) SyncUnicodeName() {
+	record.UnicodeName = unicodeName(record.Name)
+}
+
+// unicodeName returns the Unicode form of the name when it differs from the name itself
+func unicodeName(name string) string {
+	if unicodeName, err := ToUnicode(name); err == nil && unicodeName != name {
+		return unicodeName
+	}
+	return ""
+}
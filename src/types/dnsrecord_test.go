@@ -1,11 +1,16 @@
 package types
 
-import "testing"
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
 
 func TestCheckDNSRecord(t *testing.T) {
 	invalidName := "the value of field 'name' cannot be empty"
 	invalidValue := "the value of field 'value' cannot be empty"
 	invalidType := "the value of field 'type' cannot be empty"
+	invalidTTL := "the value of field 'ttl' must be between 0 and 2147483647"
 
 	testCases := []struct {
 		name     string
@@ -19,20 +24,140 @@ func TestCheckDNSRecord(t *testing.T) {
 		{"validate spaces values", DNSRecord{Name: " ", Value: " ", Type: " "}, []string{invalidName, invalidValue, invalidType}},
 		{"validate nil attribute and empty values", DNSRecord{Value: "  ", Type: " "}, []string{invalidName, invalidValue, invalidType}},
 		{"validate all nil", DNSRecord{}, []string{invalidName, invalidValue, invalidType}},
+		{"valid ttl", DNSRecord{Name: "t.test.com", Value: "0.0.0.0", Type: "A", TTL: 3600}, nil},
+		{"negative ttl", DNSRecord{Name: "t.test.com", Value: "0.0.0.0", Type: "A", TTL: -1}, []string{invalidTTL}},
+		{"ttl greater than the max", DNSRecord{Name: "t.test.com", Value: "0.0.0.0", Type: "A", TTL: MaxTTL + 1}, []string{invalidTTL}},
 	}
 
 	for _, test := range testCases {
-		t.Run(test.name, func(t *testing.T) {
-			errs := test.record.Check()
-			if len(errs) != len(test.expected) {
-				t.Errorf("The error array length must be %d but got %d", len(test.expected), len(errs))
-				t.FailNow()
+		runCheckTest(t, test.name, test.record, test.expected)
+	}
+}
+
+func TestCheckDNSRecord_Types(t *testing.T) {
+	longLabel := strings.Repeat("a", 64)
+	longName := strings.Repeat("abcdefghi.", 26) + "com"
+	longString := strings.Repeat("a", 256)
+
+	testCases := []struct {
+		name     string
+		record   DNSRecord
+		expected []string
+	}{
+		{"valid A", DNSRecord{Name: "t.test.com", Value: "10.0.0.1", Type: "A"}, nil},
+		{"A with a word", DNSRecord{Name: "t.test.com", Value: "hello", Type: "A"}, []string{"the value of field 'value' must be an IPv4 address for records of type 'A'"}},
+		{"A with an IPv6", DNSRecord{Name: "t.test.com", Value: "::1", Type: "a"}, []string{"the value of field 'value' must be an IPv4 address for records of type 'A'"}},
+		{"valid AAAA", DNSRecord{Name: "t.test.com", Value: "2001:db8::1", Type: "AAAA"}, nil},
+		{"AAAA with an IPv4", DNSRecord{Name: "t.test.com", Value: "10.0.0.1", Type: "AAAA"}, []string{"the value of field 'value' must be an IPv6 address for records of type 'AAAA'"}},
+		{"valid CNAME", DNSRecord{Name: "www.test.com", Value: "t.test.com.", Type: "CNAME"}, nil},
+		{"CNAME to an IP", DNSRecord{Name: "www.test.com", Value: "10.0.0.1", Type: "CNAME"}, []string{"the value of field 'value' must be a host name, not an IP address, for records of type 'CNAME'"}},
+		{"NS with an invalid host", DNSRecord{Name: "test.com", Value: "ns_1.test.com", Type: "NS"}, []string{"the label 'ns_1' of field 'value' must only contain letters, digits and hyphens"}},
+		{"Unicode name", DNSRecord{Name: "café.test.com", Value: "10.0.0.1", Type: "A"}, nil},
+		{"A-label name", DNSRecord{Name: "xn--caf-dma.test.com", Value: "10.0.0.1", Type: "A"}, nil},
+		{"invalid A-label name", DNSRecord{Name: "xn--caf-dm.test.com", Value: "10.0.0.1", Type: "A"}, []string{"the label 'xn--caf-dm' of field 'name' is not a valid A-label"}},
+		{"mixed-script name", DNSRecord{Name: "pаypal.com", Value: "10.0.0.1", Type: "A"}, []string{"the value of field 'name' is invalid internationalized name 'pаypal.com': the label 'pаypal' mixes the scripts Cyrillic, Latin"}},
+		{"valid PTR", DNSRecord{Name: "1.0.0.10.in-addr.arpa", Value: "t.test.com", Type: "PTR"}, nil},
+		{"valid MX", DNSRecord{Name: "test.com", Value: "10 mail.test.com", Type: "MX"}, nil},
+		{"null MX", DNSRecord{Name: "test.com", Value: "0 .", Type: "MX"}, nil},
+		{"MX without preference", DNSRecord{Name: "test.com", Value: "mail.test.com", Type: "MX"}, []string{"the value of field 'value' must be in the format '<preference> <host>' for records of type 'MX'"}},
+		{"MX with invalid preference and host", DNSRecord{Name: "test.com", Value: "70000 10.0.0.1", Type: "MX"}, []string{"the MX preference must be an integer between 0 and 65535", "the MX host must be a host name, not an IP address"}},
+		{"valid TXT", DNSRecord{Name: "_dmarc.test.com", Value: "v=DMARC1; p=none", Type: "TXT"}, nil},
+		{"valid quoted TXT", DNSRecord{Name: "test.com", Value: `"v=spf1 \"a\"" "-all"`, Type: "TXT"}, nil},
+		{"long unquoted TXT", DNSRecord{Name: "test.com", Value: longString, Type: "TXT"}, []string{"the value of field 'value' must be at most 255 characters long when not quoted; split longer values in quoted strings for records of type 'TXT'"}},
+		{"long quoted TXT", DNSRecord{Name: "test.com", Value: `"` + longString + `"`, Type: "TXT"}, []string{"each quoted string of a TXT value must have at most 255 characters"}},
+		{"unterminated TXT", DNSRecord{Name: "test.com", Value: `"v=spf1`, Type: "TXT"}, []string{"the TXT value has an unterminated quoted string"}},
+		{"TXT with text between quotes", DNSRecord{Name: "test.com", Value: `"a" b`, Type: "TXT"}, []string{"the TXT value must be a sequence of quoted strings"}},
+		{"valid SRV", DNSRecord{Name: "_sip._tcp.test.com", Value: "10 60 5060 sip.test.com", Type: "SRV"}, nil},
+		{"SRV with invalid port", DNSRecord{Name: "_sip._tcp.test.com", Value: "10 60 port sip.test.com", Type: "SRV"}, []string{"the SRV port must be an integer between 0 and 65535"}},
+		{"SRV missing fields", DNSRecord{Name: "_sip._tcp.test.com", Value: "10 sip.test.com", Type: "SRV"}, []string{"the value of field 'value' must be in the format '<priority> <weight> <port> <target>' for records of type 'SRV'"}},
+		{"valid CAA", DNSRecord{Name: "test.com", Value: `0 issue "letsencrypt.org"`, Type: "CAA"}, nil},
+		{"CAA with invalid flags and tag", DNSRecord{Name: "test.com", Value: `256 is-sue "letsencrypt.org"`, Type: "CAA"}, []string{"the CAA flags must be an integer between 0 and 255", "the CAA tag must only contain letters and digits"}},
+		{"unknown type", DNSRecord{Name: "test.com", Value: "anything", Type: "SPF"}, nil},
+		{"invalid type", DNSRecord{Name: "test.com", Value: "10.0.0.1", Type: "A A"}, []string{"the value of field 'type' must only contain letters and digits"}},
+		{"wildcard name", DNSRecord{Name: "*.test.com", Value: "10.0.0.1", Type: "A"}, nil},
+		{"wildcard not leftmost", DNSRecord{Name: "a.*.test.com", Value: "10.0.0.1", Type: "A"}, []string{"the label '*' of field 'name' must only contain letters, digits and hyphens"}},
+		{"empty label", DNSRecord{Name: "a..test.com", Value: "10.0.0.1", Type: "A"}, []string{"the labels of field 'name' must have between 1 and 63 characters, got ''"}},
+		{"long label", DNSRecord{Name: longLabel + ".com", Value: "10.0.0.1", Type: "A"}, []string{"the labels of field 'name' must have between 1 and 63 characters, got '" + longLabel + "'"}},
+		{"long name", DNSRecord{Name: longName, Value: "10.0.0.1", Type: "A"}, []string{"the value of field 'name' must have at most 253 characters"}},
+		{"hyphen on label edges", DNSRecord{Name: "-a.b-.test.com", Value: "10.0.0.1", Type: "A"}, []string{"the label '-a' of field 'name' cannot start or end with a hyphen", "the label 'b-' of field 'name' cannot start or end with a hyphen"}},
+		{"invalid characters", DNSRecord{Name: "a b.test.com", Value: "10.0.0.1", Type: "A"}, []string{"the label 'a b' of field 'name' must only contain letters, digits and hyphens"}},
+	}
+
+	for _, test := range testCases {
+		runCheckTest(t, test.name, test.record, test.expected)
+	}
+}
+
+func runCheckTest(t *testing.T, name string, record DNSRecord, expected []string) {
+	t.Run(name, func(t *testing.T) {
+		errs := record.Check()
+		if len(errs) != len(expected) {
+			t.Errorf("The error array length must be %d but got %d: %v", len(expected), len(errs), errs)
+			t.FailNow()
+		}
+		for i, err := range expected {
+			if errs[i] != err {
+				t.Errorf("Expected message was %s but got %s", err, errs[i])
 			}
-			for i, err := range test.expected {
-				if errs[i] != err {
-					t.Errorf("Expected message was %s but got %s", err, errs[i])
-				}
+		}
+	})
+}
+
+func TestDNSRecord_FieldErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		record   DNSRecord
+		expected []FieldError
+	}{
+		{"valid record", DNSRecord{Name: "t.test.com", Value: "0.0.0.0", Type: "A"}, nil},
+		{"required fields", DNSRecord{Name: "t.test.com"}, []FieldError{
+			{"value", CodeRequired, "the value of field 'value' cannot be empty"},
+			{"type", CodeRequired, "the value of field 'type' cannot be empty"},
+		}},
+		{"invalid value", DNSRecord{Name: "t.test.com", Value: "::1", Type: "A"}, []FieldError{
+			{"value", CodeInvalidValue, "the value of field 'value' must be an IPv4 address for records of type 'A'"},
+		}},
+		{"invalid name and ttl", DNSRecord{Name: "-t.test.com", Value: "0.0.0.0", Type: "A", TTL: -1}, []FieldError{
+			{"name", CodeInvalidValue, "the label '-t' of field 'name' cannot start or end with a hyphen"},
+			{"ttl", CodeOutOfRange, "the value of field 'ttl' must be between 0 and 2147483647"},
+		}},
+		{"structured field of another type", DNSRecord{Name: "t.test.com", Value: "0.0.0.0", Type: "A", MX: &MXData{Preference: 10, Host: "mail.test.com"}}, []FieldError{
+			{"mx", CodeInvalidValue, "the field 'mx' is only allowed on records of type 'MX'"},
+		}},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.record.FieldErrors(); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("FieldErrors() = %v, want %v", got, test.expected)
 			}
 		})
 	}
 }
+
+func TestDNSRecord_ETag(t *testing.T) {
+	record := DNSRecord{Name: "mail.test.com", Type: "MX", TTL: 300, Value: "10 mx.test.com"}
+	tag := record.ETag()
+	if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) != 34 {
+		t.Fatalf("expected a quoted strong tag, got %s", tag)
+	}
+	same := []DNSRecord{
+		{Name: "Mail.Test.com.", Type: "mx", TTL: 300, Value: "10 mx.test.com"},
+		{Name: "mail.test.com", Type: "MX", TTL: 300, MX: &MXData{Preference: 10, Host: "mx.test.com"}},
+	}
+	for _, other := range same {
+		if other.ETag() != tag {
+			t.Errorf("expected %+v to share the tag %s, got %s", other, tag, other.ETag())
+		}
+	}
+	different := []DNSRecord{
+		{Name: "mail.test.com", Type: "MX", TTL: 600, Value: "10 mx.test.com"},
+		{Name: "mail.test.com", Type: "MX", TTL: 300, Value: "20 mx.test.com"},
+		{Name: "smtp.test.com", Type: "MX", TTL: 300, Value: "10 mx.test.com"},
+	}
+	for _, other := range different {
+		if other.ETag() == tag {
+			t.Errorf("expected %+v to have a tag other than %s", other, tag)
+		}
+	}
+}
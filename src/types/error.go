@@ -1,17 +1,161 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
 	"net/http"
 )
 
+// Errors managers may return, or wrap, to signal why an operation failed. The hook answers them with the matching
+// HTTP status code and error code, and errors.Is matches a types.Error with the error code of a sentinel, so clients
+// can check them the same way
+var (
+	// ErrNotFound the record does not exist. Answered with 404 Not Found
+	ErrNotFound = errors.New("record not found")
+
+	// ErrConflict the operation conflicts with the current state, e.g. the record already exists. Answered with 409 Conflict
+	ErrConflict = errors.New("conflict with the current state of the record")
+
+	// ErrUnsupportedType the manager does not support the record type. Answered with 422 Unprocessable Entity
+	ErrUnsupportedType = errors.New("unsupported record type")
+
+	// ErrReadOnly the record or its zone cannot be changed. Answered with 403 Forbidden
+	ErrReadOnly = errors.New("read-only record")
+
+	// ErrRateLimited the backend refused the operation because of too many requests. Answered with 429 Too Many Requests
+	ErrRateLimited = errors.New("rate limited by the backend")
+
+	// ErrBackendUnavailable the backend could not be reached. Answered with 503 Service Unavailable
+	ErrBackendUnavailable = errors.New("backend unavailable")
+)
+
+// Error codes identifying the kind of an Error on its ErrorCode field. Unlike messages, they do not change between
+// versions, so clients may rely on them
+const (
+	// CodeBadRequest the request is malformed, e.g. its body is not valid JSON
+	CodeBadRequest = "BAD_REQUEST"
+
+	// CodeUnsupportedMediaType the content type of the request body is not supported
+	CodeUnsupportedMediaType = "UNSUPPORTED_MEDIA_TYPE"
+
+	// CodeInvalidValue the request holds invalid values, detailed per field on Fields
+	CodeInvalidValue = "INVALID_VALUE"
+
+	// CodeUnauthorized the request could not be authenticated
+	CodeUnauthorized = "UNAUTHORIZED"
+
+	// CodeForbidden the request is not allowed
+	CodeForbidden = "FORBIDDEN"
+
+	// CodePolicyDenied the request was denied by the authorization policy
+	CodePolicyDenied = "POLICY_DENIED"
+
+	// CodeNotFound the resource does not exist
+	CodeNotFound = "NOT_FOUND"
+
+	// CodeRecordNotFound the record does not exist
+	CodeRecordNotFound = "RECORD_NOT_FOUND"
+
+	// CodeRRSetNotFound the RRSet does not exist
+	CodeRRSetNotFound = "RRSET_NOT_FOUND"
+
+	// CodeConflict the request conflicts with the current state of the record
+	CodeConflict = "CONFLICT"
+
+	// CodePreconditionFailed the record does not match the conditions of the request, e.g. its If-Match header
+	CodePreconditionFailed = "PRECONDITION_FAILED"
+
+	// CodeRequestInProgress a request with the same idempotency key is still being served
+	CodeRequestInProgress = "REQUEST_IN_PROGRESS"
+
+	// CodeIdempotencyKeyReused the idempotency key was already used by a different request
+	CodeIdempotencyKeyReused = "IDEMPOTENCY_KEY_REUSED"
+
+	// CodeFailedDependency the operation was not applied, or was undone, because another one failed
+	CodeFailedDependency = "FAILED_DEPENDENCY"
+
+	// CodeUnsupportedType the manager does not support the record type
+	CodeUnsupportedType = "UNSUPPORTED_TYPE"
+
+	// CodeUnsupportedOperation the manager does not support the operation
+	CodeUnsupportedOperation = "UNSUPPORTED_OPERATION"
+
+	// CodeReadOnly the record cannot be changed
+	CodeReadOnly = "READ_ONLY"
+
+	// CodeRateLimited the backend refused the operation because of too many requests
+	CodeRateLimited = "RATE_LIMITED"
+
+	// CodeBackendUnavailable the backend could not be reached
+	CodeBackendUnavailable = "BACKEND_UNAVAILABLE"
+
+	// CodeUnavailable the webhook cannot serve the request for now
+	CodeUnavailable = "UNAVAILABLE"
+
+	// CodeShuttingDown the webhook is shutting down
+	CodeShuttingDown = "SHUTTING_DOWN"
+
+	// CodeQueueFull too many changes are waiting to be applied asynchronously
+	CodeQueueFull = "QUEUE_FULL"
+
+	// CodeInternalError an unexpected error
+	CodeInternalError = "INTERNAL_ERROR"
+
+	// CodeUnexpectedResponse the response was not an error of the hook, e.g. the 404 of a proxy or of a wrong base path.
+	// Only set by clients
+	CodeUnexpectedResponse = "UNEXPECTED_RESPONSE"
+)
+
+// Codes of the validation errors of a single field, see FieldError
+const (
+	// CodeRequired the field cannot be empty
+	CodeRequired = "REQUIRED"
+
+	// CodeOutOfRange the number is out of the accepted range
+	CodeOutOfRange = "OUT_OF_RANGE"
+
+	// CodeDuplicated the value is repeated
+	CodeDuplicated = "DUPLICATED"
+)
+
+// sentinels maps the sentinel errors to their HTTP status codes, error codes and messages. errorCodes are the error
+// codes matching the sentinel, the first one being the code of its answers
+var sentinels = []struct {
+	err        error
+	code       int
+	errorCodes []string
+	message    string
+}{
+	{ErrNotFound, http.StatusNotFound, []string{CodeRecordNotFound, CodeRRSetNotFound}, "Record not found"},
+	{ErrConflict, http.StatusConflict, []string{CodeConflict}, "The operation conflicts with the current state of the record"},
+	{ErrUnsupportedType, http.StatusUnprocessableEntity, []string{CodeUnsupportedType}, "Unsupported record type"},
+	{ErrReadOnly, http.StatusForbidden, []string{CodeReadOnly}, "The record cannot be changed"},
+	{ErrRateLimited, http.StatusTooManyRequests, []string{CodeRateLimited}, "Too many requests to the DNS backend, try again later"},
+	{ErrBackendUnavailable, http.StatusServiceUnavailable, []string{CodeBackendUnavailable}, "The DNS backend is unavailable"},
+}
+
 // Error groups together information that defines an error. Should always be used to
 type Error struct {
-	Message string   `json:"message"`
-	Code    int      `json:"code"`
-	Details []string `json:"details,omitempty"`
-	Err     error    `json:"-"`
+	Message   string       `json:"message"`
+	Code      int          `json:"code"`
+	ErrorCode string       `json:"errorCode,omitempty"`
+	Details   []string     `json:"details,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+	Record    *DNSRecord   `json:"record,omitempty"`
+	Err       error        `json:"-"`
+}
+
+// FieldError describes why the value of a field is not valid
+type FieldError struct {
+	// Field the name of the field, as on its JSON form
+	Field string `json:"field"`
+
+	// Code the kind of the violation, e.g. REQUIRED or INVALID_VALUE
+	Code string `json:"code"`
+
+	// Message the description of the violation
+	Message string `json:"message"`
 }
 
 // Error() gives a string representing the error; also, forces the Error type to comply with the error interface
@@ -21,22 +165,123 @@ func (e *Error) Error() string {
 	return msg
 }
 
+// Unwrap returns the inner error, so errors.Is and errors.As inspect it
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is tells if the error matches target, one of the sentinel errors, by its error code: RECORD_NOT_FOUND and
+// RRSET_NOT_FOUND match ErrNotFound, CONFLICT matches ErrConflict, and so on. Errors without error code, like the ones
+// of older versions of the hook, are matched by their status code instead, except for ErrReadOnly, whose 403 is also
+// the code of the errors of the authorization policies. This lets clients check the errors decoded from the responses
+// of the hook
+func (e *Error) Is(target error) bool {
+	for _, sentinel := range sentinels {
+		if sentinel.err != target {
+			continue
+		}
+		if e.ErrorCode == "" {
+			return target != ErrReadOnly && e.Code == sentinel.code
+		}
+		for _, errorCode := range sentinel.errorCodes {
+			if e.ErrorCode == errorCode {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// AsError returns the Error describing err: the Error on its chain, or an Error with the status code of the sentinel
+// error it wraps, detailed by the message of err when it adds to the sentinel. Any other error is described as an
+// internal server error, holding err as its inner error
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	for _, sentinel := range sentinels {
+		if errors.Is(err, sentinel.err) {
+			e = &Error{Message: sentinel.message, Code: sentinel.code, ErrorCode: sentinel.errorCodes[0], Err: err}
+			if err != sentinel.err {
+				e.Details = []string{err.Error()}
+			}
+			return e
+		}
+	}
+	return InternalServerError("An internal server error occurred, please contact the system administrator.", err)
+}
+
 // BadRequestError create an Error instance with http.StatusBadRequest code
 func BadRequestError(message string, err error, details ...string) *Error {
-	return &Error{Message: message, Err: err, Code: http.StatusBadRequest, Details: details}
+	return &Error{Message: message, Err: err, Code: http.StatusBadRequest, ErrorCode: CodeBadRequest, Details: details}
 }
 
 // BadRequestError create an Error instance with http.StatusNotFound code
 func NotFoundError(message string, err error, details ...string) *Error {
-	return &Error{Message: message, Err: err, Code: http.StatusNotFound, Details: details}
+	return &Error{Message: message, Err: err, Code: http.StatusNotFound, ErrorCode: CodeNotFound, Details: details}
+}
+
+// UnauthorizedError create an Error instance with http.StatusUnauthorized code
+func UnauthorizedError(message string, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusUnauthorized, ErrorCode: CodeUnauthorized, Details: details}
+}
+
+// ForbiddenError create an Error instance with http.StatusForbidden code
+func ForbiddenError(message string, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusForbidden, ErrorCode: CodeForbidden, Details: details}
+}
+
+// ConflictError create an Error instance with http.StatusConflict code, holding the current record
+func ConflictError(message string, record *DNSRecord, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusConflict, ErrorCode: CodeConflict, Details: details, Record: record}
+}
+
+// PreconditionFailedError create an Error instance with http.StatusPreconditionFailed code
+func PreconditionFailedError(message string, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusPreconditionFailed, ErrorCode: CodePreconditionFailed, Details: details}
+}
+
+// UnprocessableEntityError create an Error instance with http.StatusUnprocessableEntity code
+func UnprocessableEntityError(message string, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusUnprocessableEntity, ErrorCode: CodeInvalidValue, Details: details}
+}
+
+// ServiceUnavailableError create an Error instance with http.StatusServiceUnavailable code. Its error code is
+// CodeUnavailable, not the one of ErrBackendUnavailable, as the webhook itself may be unavailable
+func ServiceUnavailableError(message string, err error, details ...string) *Error {
+	return &Error{Message: message, Err: err, Code: http.StatusServiceUnavailable, ErrorCode: CodeUnavailable, Details: details}
 }
 
 // BadRequestError create an Error instance with http.StatusInternalServerError code
 func InternalServerError(message string, err error, details ...string) *Error {
-	return &Error{Message: message, Err: err, Code: http.StatusInternalServerError, Details: details}
+	return &Error{Message: message, Err: err, Code: http.StatusInternalServerError, ErrorCode: CodeInternalError, Details: details}
+}
+
+// ValidationError create an Error instance with http.StatusBadRequest code describing the violations of each field
+func ValidationError(message string, fields []FieldError) *Error {
+	return &Error{Message: message, Code: http.StatusBadRequest, ErrorCode: CodeInvalidValue, Details: FieldMessages(fields), Fields: fields}
+}
+
+// WithErrorCode sets the error code of the error, returning it
+func (e *Error) WithErrorCode(errorCode string) *Error {
+	e.ErrorCode = errorCode
+	return e
+}
+
+// FieldMessages returns the messages of the field errors, nil when there are none
+func FieldMessages(fields []FieldError) []string {
+	var messages []string
+	for _, field := range fields {
+		messages = append(messages, field.Message)
+	}
+	return messages
 }
 
 // PanicIfError is just a wrapper to a panic call that propagates error when it's not nil
+//
+// Deprecated: the hook handlers return their errors instead of panicking
 func PanicIfError(e error) {
 	if e != nil {
 		logrus.Errorf(e.Error())
@@ -45,6 +290,8 @@ func PanicIfError(e error) {
 }
 
 // Panic wraps a panic call propagating the given error parameter
+//
+// Deprecated: the hook handlers return their errors instead of panicking
 func Panic(e Error) {
 	logrus.Errorf(e.Error())
 	panic(e)
@@ -126,6 +126,26 @@ func TestErrorConstructorFunctions(t *testing.T) {
 			want:        want{code: http.StatusNotFound},
 			createError: NotFoundError,
 		},
+		{
+			name: "unauthorized",
+			args: args{
+				message: "unauthorized",
+				err:     errors.New("401"),
+				details: []string{"invalid", "token"},
+			},
+			want:        want{code: http.StatusUnauthorized},
+			createError: UnauthorizedError,
+		},
+		{
+			name: "forbidden",
+			args: args{
+				message: "forbidden",
+				err:     errors.New("403"),
+				details: []string{"policy"},
+			},
+			want:        want{code: http.StatusForbidden},
+			createError: ForbiddenError,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -151,3 +171,89 @@ func TestErrorConstructorFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestError_Is(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		target error
+		want   bool
+	}{
+		{"record not found error code matches ErrNotFound", NotFoundError("not found", nil).WithErrorCode(CodeRecordNotFound), ErrNotFound, true},
+		{"RRSet not found error code matches ErrNotFound", NotFoundError("not found", nil).WithErrorCode(CodeRRSetNotFound), ErrNotFound, true},
+		{"other not found error codes do not match ErrNotFound", NotFoundError("change not found", nil), ErrNotFound, false},
+		{"unexpected responses do not match ErrNotFound", &Error{Code: http.StatusNotFound, ErrorCode: CodeUnexpectedResponse}, ErrNotFound, false},
+		{"not found code without error code matches ErrNotFound", &Error{Code: http.StatusNotFound}, ErrNotFound, true},
+		{"conflict error code matches ErrConflict", ConflictError("conflict", nil, nil), ErrConflict, true},
+		{"request in progress does not match ErrConflict", ConflictError("in progress", nil, nil).WithErrorCode(CodeRequestInProgress), ErrConflict, false},
+		{"reused idempotency key does not match ErrUnsupportedType", UnprocessableEntityError("reused", nil).WithErrorCode(CodeIdempotencyKeyReused), ErrUnsupportedType, false},
+		{"unsupported type error code matches ErrUnsupportedType", UnprocessableEntityError("unsupported", nil).WithErrorCode(CodeUnsupportedType), ErrUnsupportedType, true},
+		{"conflict code matches ErrConflict", &Error{Code: http.StatusConflict}, ErrConflict, true},
+		{"unprocessable code matches ErrUnsupportedType", &Error{Code: http.StatusUnprocessableEntity}, ErrUnsupportedType, true},
+		{"too many requests code matches ErrRateLimited", &Error{Code: http.StatusTooManyRequests}, ErrRateLimited, true},
+		{"unavailable code matches ErrBackendUnavailable", &Error{Code: http.StatusServiceUnavailable}, ErrBackendUnavailable, true},
+		{"unavailable webhook does not match ErrBackendUnavailable", ServiceUnavailableError("unavailable", nil), ErrBackendUnavailable, false},
+		{"shutdown does not match ErrBackendUnavailable", ServiceUnavailableError("shutting down", nil).WithErrorCode(CodeShuttingDown), ErrBackendUnavailable, false},
+		{"full queue does not match ErrBackendUnavailable", ServiceUnavailableError("full", nil).WithErrorCode(CodeQueueFull), ErrBackendUnavailable, false},
+		{"other codes do not match", BadRequestError("bad", nil), ErrNotFound, false},
+		{"forbidden code does not match ErrReadOnly", ForbiddenError("forbidden", nil), ErrReadOnly, false},
+		{"inner sentinel matches", ForbiddenError("forbidden", ErrReadOnly), ErrReadOnly, true},
+		{"read-only error code matches ErrReadOnly", ForbiddenError("forbidden", nil).WithErrorCode(CodeReadOnly), ErrReadOnly, true},
+		{"inner error matches", InternalServerError("internal", ErrBackendUnavailable), ErrBackendUnavailable, true},
+		{"wrapped error matches", fmt.Errorf("getting record: %w", NotFoundError("not found", nil).WithErrorCode(CodeRecordNotFound)), ErrNotFound, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, tt.target); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestError_As(t *testing.T) {
+	inner := BadRequestError("bad", nil)
+	err := InternalServerError("internal", fmt.Errorf("wrapped: %w", inner))
+	var target *Error
+	if !errors.As(err.Unwrap(), &target) || target != inner {
+		t.Errorf("errors.As() must find the inner error, got %v", target)
+	}
+}
+
+func TestAsError(t *testing.T) {
+	typed := BadRequestError("bad", nil)
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    int
+		wantDetails []string
+	}{
+		{"types.Error", typed, http.StatusBadRequest, nil},
+		{"validation error", ValidationError("invalid", []FieldError{{"ttl", CodeOutOfRange, "out of range"}}), http.StatusBadRequest,
+			[]string{"out of range"}},
+		{"wrapped types.Error", fmt.Errorf("wrapped: %w", typed), http.StatusBadRequest, nil},
+		{"not found", ErrNotFound, http.StatusNotFound, nil},
+		{"conflict", ErrConflict, http.StatusConflict, nil},
+		{"unsupported type", ErrUnsupportedType, http.StatusUnprocessableEntity, nil},
+		{"read-only", ErrReadOnly, http.StatusForbidden, nil},
+		{"rate limited", ErrRateLimited, http.StatusTooManyRequests, nil},
+		{"backend unavailable", ErrBackendUnavailable, http.StatusServiceUnavailable, nil},
+		{"wrapped sentinel", fmt.Errorf("zone 'example.com': %w", ErrReadOnly), http.StatusForbidden,
+			[]string{"zone 'example.com': read-only record"}},
+		{"unknown error", errors.New("boom"), http.StatusInternalServerError, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AsError(tt.err)
+			if got.Code != tt.wantCode {
+				t.Errorf("AsError().Code = %v, want %v", got.Code, tt.wantCode)
+			}
+			if !reflect.DeepEqual(got.Details, tt.wantDetails) {
+				t.Errorf("AsError().Details = %v, want %v", got.Details, tt.wantDetails)
+			}
+			if !errors.Is(got, tt.err) && !errors.Is(tt.err, got) {
+				t.Errorf("AsError() must be on the chain of %v or hold it on its own", tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package types
+
+import "time"
+
+// EventReset is the operation of the event sent to watchers that missed some events, e.g. because they resumed from
+// an event no longer kept by the webhook. They must list the records again
+const EventReset = "reset"
+
+// RecordEvent reports a change of the records made through the webhook, streamed by /records/watch and delivered to
+// the subscriptions
+type RecordEvent struct {
+	// ID identifies the event, resuming a watch right after it
+	ID string `json:"id"`
+
+	// Op the operation: add, update or delete, as on BatchOperation, or reset
+	Op string `json:"op"`
+
+	// Name the name of the changed record
+	Name string `json:"name,omitempty"`
+
+	// Type the type of the changed record
+	Type string `json:"type,omitempty"`
+
+	// Record the record after the change, when added or updated
+	Record *DNSRecord `json:"record,omitempty"`
+
+	// Before the record before the change, when updated or deleted
+	Before *DNSRecord `json:"before,omitempty"`
+
+	// RRSet the RRSet after the change, for changes made on /rrsets
+	RRSet *RRSet `json:"rrset,omitempty"`
+
+	// RRSetBefore the RRSet before the change, for changes made on /rrsets
+	RRSetBefore *RRSet `json:"rrsetBefore,omitempty"`
+
+	// Time when the change was made
+	Time time.Time `json:"time"`
+}
@@ -0,0 +1,154 @@
+package types
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Query parameters filtering the listings of records, see RecordFilter
+const (
+	// QueryType the record types to keep, separated by commas, e.g. "A,AAAA"
+	QueryType = "type"
+
+	// QueryName a glob pattern the names must match
+	QueryName = "name"
+
+	// QueryNamePrefix a prefix of the names
+	QueryNamePrefix = "namePrefix"
+
+	// QueryNameSuffix a suffix of the names
+	QueryNameSuffix = "nameSuffix"
+
+	// QueryValue a glob pattern the values must match
+	QueryValue = "value"
+
+	// QueryZone the zone holding the names
+	QueryZone = "zone"
+)
+
+// Query parameters paginating and sorting the listings of records
+const (
+	// QuerySort the field sorting the records: name, type or value, preceded by "-" for the descending order
+	QuerySort = "sort"
+
+	// QueryLimit the greatest number of records of a page
+	QueryLimit = "limit"
+
+	// QueryCursor the position of a page, as given by the previous one
+	QueryCursor = "cursor"
+
+	// HeaderNextCursor holds the cursor of the next page on the responses of paginated listings
+	HeaderNextCursor = "X-Next-Cursor"
+)
+
+// RecordFilter selects the records of a listing. Every condition set must hold; an empty filter keeps every record.
+// Names are compared ignoring case and the trailing dot
+type RecordFilter struct {
+	// Types the record types to keep, any type when empty
+	Types []string
+
+	// Name a glob pattern the names must match, as defined by path.Match, e.g. "www*.example.com"
+	Name string
+
+	// NamePrefix a prefix of the names, e.g. "www."
+	NamePrefix string
+
+	// NameSuffix a suffix of the names, e.g. ".example.com"
+	NameSuffix string
+
+	// Value a glob pattern the values must match, in the presentation format, e.g. "10.0.*"
+	Value string
+
+	// Zone keeps the names equal to the zone or under it, e.g. "example.com" keeps "example.com" and "www.example.com"
+	Zone string
+}
+
+// ParseRecordFilter reads the filter from the query parameters of a listing
+func ParseRecordFilter(query url.Values) (RecordFilter, error) {
+	filter := RecordFilter{
+		Name:       query.Get(QueryName),
+		NamePrefix: query.Get(QueryNamePrefix),
+		NameSuffix: query.Get(QueryNameSuffix),
+		Value:      query.Get(QueryValue),
+		Zone:       query.Get(QueryZone),
+	}
+	for _, value := range query[QueryType] {
+		for _, recordType := range strings.Split(value, ",") {
+			if recordType = strings.ToUpper(strings.TrimSpace(recordType)); recordType != "" {
+				filter.Types = append(filter.Types, recordType)
+			}
+		}
+	}
+	return filter, filter.Check()
+}
+
+// Check verifies the glob patterns of the filter are valid
+func (f *RecordFilter) Check() error {
+	for param, pattern := range map[string]string{QueryName: f.Name, QueryValue: f.Value} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("the pattern '%s' of '%s' is not valid: %v", pattern, param, err)
+		}
+	}
+	return nil
+}
+
+// IsZero tells if the filter keeps every record
+func (f *RecordFilter) IsZero() bool {
+	return len(f.Types) == 0 && f.Name == "" && f.NamePrefix == "" && f.NameSuffix == "" && f.Value == "" && f.Zone == ""
+}
+
+// Matches tells if the record satisfies every condition of the filter. The value of the record must be in the
+// presentation format, see DNSRecord.SyncValue
+func (f *RecordFilter) Matches(record DNSRecord) bool {
+	if len(f.Types) > 0 && !containsFold(f.Types, record.Type) {
+		return false
+	}
+	name := canonicalName(record.Name)
+	if f.Name != "" {
+		if matched, _ := path.Match(canonicalName(f.Name), name); !matched {
+			return false
+		}
+	}
+	if !strings.HasPrefix(name, strings.ToLower(f.NamePrefix)) || !strings.HasSuffix(name, canonicalName(f.NameSuffix)) {
+		return false
+	}
+	if f.Value != "" {
+		if matched, _ := path.Match(f.Value, record.Value); !matched {
+			return false
+		}
+	}
+	if zone := canonicalName(f.Zone); zone != "" && name != zone && !strings.HasSuffix(name, "."+zone) {
+		return false
+	}
+	return true
+}
+
+// Query adds the conditions of the filter to the query parameters of a listing
+func (f *RecordFilter) Query(query url.Values) {
+	if len(f.Types) > 0 {
+		query.Set(QueryType, strings.Join(f.Types, ","))
+	}
+	for param, value := range map[string]string{QueryName: f.Name, QueryNamePrefix: f.NamePrefix,
+		QueryNameSuffix: f.NameSuffix, QueryValue: f.Value, QueryZone: f.Zone} {
+		if value != "" {
+			query.Set(param, value)
+		}
+	}
+}
+
+// canonicalName returns the name in lower case without the trailing dot
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// containsFold tells if the values hold s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package types
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestRecordFilter_Matches(t *testing.T) {
+	record := DNSRecord{Name: "WWW.Example.com.", Type: "a", Value: "10.0.0.1"}
+	tests := []struct {
+		name   string
+		filter RecordFilter
+		want   bool
+	}{
+		{"empty", RecordFilter{}, true},
+		{"type", RecordFilter{Types: []string{"AAAA", "A"}}, true},
+		{"other type", RecordFilter{Types: []string{"AAAA"}}, false},
+		{"name", RecordFilter{Name: "*.example.com"}, true},
+		{"other name", RecordFilter{Name: "*.example.org"}, false},
+		{"prefix", RecordFilter{NamePrefix: "www."}, true},
+		{"suffix", RecordFilter{NameSuffix: "example.COM."}, true},
+		{"other suffix", RecordFilter{NameSuffix: ".org"}, false},
+		{"value", RecordFilter{Value: "10.0.0.?"}, true},
+		{"other value", RecordFilter{Value: "10.1.*"}, false},
+		{"zone", RecordFilter{Zone: "example.com"}, true},
+		{"name of the zone", RecordFilter{Zone: "www.example.com"}, true},
+		{"zone sharing a suffix", RecordFilter{Zone: "ample.com"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(record); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRecordFilter(t *testing.T) {
+	filter := RecordFilter{Types: []string{"A", "aaaa"}, Name: "www*", NamePrefix: "w", NameSuffix: ".com", Value: "10.*", Zone: "example.com"}
+	query := url.Values{}
+	filter.Query(query)
+	query.Add(QueryType, " mx ")
+	want := filter
+	want.Types = []string{"A", "AAAA", "MX"}
+	got, err := ParseRecordFilter(query)
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("want %+v, got %+v (%v)", want, got, err)
+	}
+
+	if _, err := ParseRecordFilter(url.Values{QueryValue: {"[a-"}}); err == nil {
+		t.Error("expected the invalid pattern to be rejected")
+	}
+}
@@ -0,0 +1,11 @@
+package types
+
+// Headers of idempotent requests
+const (
+	// HeaderIdempotencyKey identifies a request changing records, so its retries are applied once. The response of the
+	// first request is replayed to the retries carrying the same key
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	// HeaderIdempotentReplayed is set on the responses replayed to retries of an idempotent request
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
@@ -0,0 +1,131 @@
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// idnaProfile converts names following UTS #46 without transitional processing. Underscores and wildcards are accepted,
+// as on owner names; the remaining host name rules are checked by validateName
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false), idna.StrictDomainName(false))
+
+// scriptSets the combinations of scripts a label may mix, as the Highly Restrictive level of UTS #39. Any single script
+// is allowed as well
+var scriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// ToASCII converts a domain name into its ASCII form, turning the labels holding other characters into A-labels
+// (punycode), e.g. "café.example.com" into "xn--caf-dma.example.com". Names that are not valid internationalized
+// names, like those holding disallowed code points or mixing scripts, are rejected
+func ToASCII(name string) (string, error) {
+	if isASCII(name) {
+		return name, nil
+	}
+	absolute := strings.HasSuffix(name, ".")
+	ascii, err := idnaProfile.ToASCII(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized name '%s': %v", name, err)
+	}
+	unicodeName, err := idnaProfile.ToUnicode(ascii)
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized name '%s': %v", name, err)
+	}
+	for _, label := range strings.Split(unicodeName, ".") {
+		if err := checkScripts(label); err != nil {
+			return "", fmt.Errorf("invalid internationalized name '%s': %v", name, err)
+		}
+	}
+	if absolute {
+		ascii += "."
+	}
+	return ascii, nil
+}
+
+// ToUnicode converts the A-labels of a domain name into their Unicode form, e.g. "xn--caf-dma.example.com" into
+// "café.example.com". Names without A-labels are returned as they are
+func ToUnicode(name string) (string, error) {
+	if !strings.Contains(strings.ToLower(name), "xn--") {
+		return name, nil
+	}
+	absolute := strings.HasSuffix(name, ".")
+	unicodeName, err := idnaProfile.ToUnicode(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid internationalized name '%s': %v", name, err)
+	}
+	if absolute {
+		unicodeName += "."
+	}
+	return unicodeName, nil
+}
+
+// isALabel tells if the label, starting with "xn--", is the valid ASCII form of an internationalized label
+func isALabel(label string) bool {
+	unicodeLabel, err := ToUnicode(label)
+	if err != nil || isASCII(unicodeLabel) {
+		return false
+	}
+	ascii, err := ToASCII(unicodeLabel)
+	return err == nil && strings.EqualFold(ascii, label)
+}
+
+// checkScripts rejects labels mixing scripts that are not usually written together, a common way of spoofing names
+func checkScripts(label string) error {
+	scripts := map[string]bool{}
+	for _, r := range label {
+		for name, table := range unicode.Scripts {
+			if name != "Common" && name != "Inherited" && unicode.Is(table, r) {
+				scripts[name] = true
+				break
+			}
+		}
+	}
+	if len(scripts) <= 1 {
+		return nil
+	}
+	for _, set := range scriptSets {
+		if containsScripts(set, scripts) {
+			return nil
+		}
+	}
+	var names []string
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("the label '%s' mixes the scripts %s", label, strings.Join(names, ", "))
+}
+
+// containsScripts tells if every script is on the set
+func containsScripts(set []string, scripts map[string]bool) bool {
+	for name := range scripts {
+		found := false
+		for _, allowed := range set {
+			if name == allowed {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// isASCII tells if the string only holds ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package types
+
+import "testing"
+
+func TestToASCII(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"example.com", "example.com", false},
+		{"café.example.com", "xn--caf-dma.example.com", false},
+		{"Café.example.com.", "xn--caf-dma.example.com.", false},
+		{"ＥＸＡＭＰＬＥ.com", "example.com", false},
+		{"日本語.jp", "xn--wgv71a119e.jp", false},
+		{"ひらがな漢字.jp", "xn--v8j0cwa6gy22xv2ya.jp", false},
+		{"_dmarc.café.com", "_dmarc.xn--caf-dma.com", false},
+		{"*.café.com", "*.xn--caf-dma.com", false},
+		{"pаypal.com", "", true}, // Cyrillic 'а' among Latin letters
+		{"a b.com", "", true},    // disallowed code point
+		{"אa.com", "", true},     // right-to-left label breaking the Bidi rule
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToASCII(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToASCII() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ToASCII() = '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"example.com", "example.com", false},
+		{"xn--caf-dma.example.com.", "café.example.com.", false},
+		{"xn--wgv71a119e.jp", "日本語.jp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ToUnicode(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ToUnicode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ToUnicode() = '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSRecord_SyncUnicodeName(t *testing.T) {
+	record := DNSRecord{Name: "xn--caf-dma.example.com", UnicodeName: "stale"}
+	record.SyncUnicodeName()
+	if record.UnicodeName != "café.example.com" {
+		t.Errorf("want the Unicode name, got '%s'", record.UnicodeName)
+	}
+	record = DNSRecord{Name: "example.com", UnicodeName: "stale"}
+	record.SyncUnicodeName()
+	if record.UnicodeName != "" {
+		t.Errorf("want no Unicode name for ASCII names, got '%s'", record.UnicodeName)
+	}
+}
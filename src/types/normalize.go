@@ -0,0 +1,83 @@
+package types
+
+import "strings"
+
+// NameNormalizer turns names into their canonical form: lowercase, in the ASCII form of internationalized names,
+// without surrounding spaces and without the trailing dot. When DefaultZone is set, names are relative to it unless
+// they end with a dot, as on zone files: "app" and "app.example.com" become "app.example.com" on the "example.com"
+// zone, "@" is the zone apex and "app.other.org." is kept as "app.other.org"
+type NameNormalizer struct {
+	// DefaultZone the zone relative names belong to. Every name not ending with a dot is absolute when empty
+	DefaultZone string
+}
+
+// Check verifies the default zone is a valid domain name
+func (n NameNormalizer) Check() []string {
+	if n.zone() == "" {
+		return nil
+	}
+	return validateName("defaultZone", n.zone(), false)
+}
+
+// Name returns the canonical form of the name
+func (n NameNormalizer) Name(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return name
+	}
+	// names that cannot be converted are kept, to be reported by Check
+	if ascii, err := ToASCII(name); err == nil {
+		name = strings.ToLower(ascii)
+	}
+	if strings.HasSuffix(name, ".") {
+		return strings.TrimSuffix(name, ".")
+	}
+	zone := n.zone()
+	switch {
+	case zone == "":
+		return name
+	case name == "@":
+		return zone
+	case name == zone || strings.HasSuffix(name, "."+zone):
+		return name
+	}
+	return name + "." + zone
+}
+
+// Type returns the canonical form of the record type: uppercase, without surrounding spaces
+func (n NameNormalizer) Type(recordType string) string {
+	return strings.ToUpper(strings.TrimSpace(recordType))
+}
+
+// Record returns a copy of the record with its name and type in the canonical form. UnicodeName is cleared, as it is
+// derived from the name
+func (n NameNormalizer) Record(record DNSRecord) DNSRecord {
+	record.Name = n.Name(record.Name)
+	record.UnicodeName = ""
+	record.Type = n.Type(record.Type)
+	return record
+}
+
+// RRSet returns a copy of the set with its name and type in the canonical form. UnicodeName is cleared, as it is
+// derived from the name
+func (n NameNormalizer) RRSet(set RRSet) RRSet {
+	set.Name = n.Name(set.Name)
+	set.UnicodeName = ""
+	set.Type = n.Type(set.Type)
+	return set
+}
+
+// zone returns the canonical form of the default zone
+func (n NameNormalizer) zone() string {
+	zone := strings.ToLower(strings.TrimSpace(n.DefaultZone))
+	if ascii, err := ToASCII(zone); err == nil {
+		zone = strings.ToLower(ascii)
+	}
+	return strings.TrimSuffix(zone, ".")
+}
+
+// Canonical returns a copy of the record with its name lowercase and without the trailing dot, and its type uppercase.
+// See NameNormalizer to resolve relative names against a default zone
+func (record *DNSRecord) Canonical() DNSRecord {
+	return NameNormalizer{}.Record(*record)
+}
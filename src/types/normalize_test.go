@@ -0,0 +1,54 @@
+package types
+
+import "testing"
+
+func TestNameNormalizer_Name(t *testing.T) {
+	tests := []struct {
+		zone string
+		name string
+		want string
+	}{
+		{"", "App.Example.com", "app.example.com"},
+		{"", "app.example.com.", "app.example.com"},
+		{"", " app.example.com ", "app.example.com"},
+		{"", "app", "app"},
+		{"", "", ""},
+		{"Example.com.", "app", "app.example.com"},
+		{"example.com", "App.Example.COM", "app.example.com"},
+		{"example.com", "example.com", "example.com"},
+		{"example.com", "@", "example.com"},
+		{"example.com", "app.other.org.", "app.other.org"},
+		{"example.com", "app.other.org", "app.other.org.example.com"},
+		{"example.com", "myexample.com", "myexample.com.example.com"},
+		{"", "Café.Example.com.", "xn--caf-dma.example.com"},
+		{"café.com", "app", "app.xn--caf-dma.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone+"/"+tt.name, func(t *testing.T) {
+			if got := (NameNormalizer{DefaultZone: tt.zone}).Name(tt.name); got != tt.want {
+				t.Errorf("Name() = '%s', want '%s'", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNameNormalizer_Check(t *testing.T) {
+	if errs := (NameNormalizer{DefaultZone: "example.com."}).Check(); errs != nil {
+		t.Errorf("expected a valid zone, got %v", errs)
+	}
+	if errs := (NameNormalizer{DefaultZone: "exa mple.com"}).Check(); errs == nil {
+		t.Error("expected an invalid zone")
+	}
+}
+
+func TestDNSRecord_Canonical(t *testing.T) {
+	record := DNSRecord{Name: "App.Example.com.", Type: "cname", Value: "Target.Example.com."}
+	got := record.Canonical()
+	want := DNSRecord{Name: "app.example.com", Type: "CNAME", Value: "Target.Example.com."}
+	if got != want {
+		t.Errorf("Canonical() = %+v, want %+v", got, want)
+	}
+	if record.Name != "App.Example.com." {
+		t.Error("Canonical() must not modify the record")
+	}
+}
@@ -0,0 +1,28 @@
+package types
+
+import "encoding/json"
+
+const (
+	// MergePatchContentType is the media type of the JSON Merge Patches defined by RFC 7396
+	MergePatchContentType = "application/merge-patch+json"
+
+	// JSONPatchContentType is the media type of the JSON Patches defined by RFC 6902
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+// PatchOperation is an operation of a JSON Patch, as defined by RFC 6902. Paths are JSON pointers to the fields of the
+// record, e.g. "/ttl" or "/mx/preference"
+type PatchOperation struct {
+	// Op the operation: add, remove, replace, move, copy or test
+	Op string `json:"op"`
+
+	// Path the location the operation applies to
+	Path string `json:"path"`
+
+	// From the location the value is taken from, on move and copy operations
+	From string `json:"from,omitempty"`
+
+	// Value the JSON value to add, to replace with or to compare to, required on add, replace and test operations.
+	// A missing value is empty, unlike the JSON null
+	Value json.RawMessage `json:"value,omitempty"`
+}
@@ -0,0 +1,68 @@
+package types
+
+import "net/http"
+
+// ProblemContentType is the media type of the problem details defined by RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// problemTypePrefix prefixes the error codes to build the URIs identifying the problem types
+const problemTypePrefix = "urn:bindman:error:"
+
+// Problem represents an Error as the problem details defined by RFC 7807. The error code, the details and the field
+// errors are sent as extension members
+type Problem struct {
+	// Type the URI identifying the kind of problem, built from the error code, e.g. "urn:bindman:error:RECORD_NOT_FOUND"
+	Type string `json:"type"`
+
+	// Title the description of the status code
+	Title string `json:"title"`
+
+	// Status the HTTP status code
+	Status int `json:"status"`
+
+	// Detail the message of the error
+	Detail string `json:"detail,omitempty"`
+
+	// Instance the path of the request that failed
+	Instance string `json:"instance,omitempty"`
+
+	// ErrorCode the error code, see Error
+	ErrorCode string `json:"errorCode,omitempty"`
+
+	// Details the details of the error
+	Details []string `json:"details,omitempty"`
+
+	// Fields the errors of each field
+	Fields []FieldError `json:"fields,omitempty"`
+
+	// Record the current record, on conflicts
+	Record *DNSRecord `json:"record,omitempty"`
+}
+
+// Problem returns the problem details of the error. instance identifies the request that failed
+func (e *Error) Problem(instance string) Problem {
+	problemType := "about:blank"
+	if e.ErrorCode != "" {
+		problemType = problemTypePrefix + e.ErrorCode
+	}
+	return Problem{
+		Type:      problemType,
+		Title:     http.StatusText(e.Code),
+		Status:    e.Code,
+		Detail:    e.Message,
+		Instance:  instance,
+		ErrorCode: e.ErrorCode,
+		Details:   e.Details,
+		Fields:    e.Fields,
+		Record:    e.Record,
+	}
+}
+
+// ToError returns the Error described by the problem details
+func (p *Problem) ToError() *Error {
+	message := p.Detail
+	if message == "" {
+		message = p.Title
+	}
+	return &Error{Message: message, Code: p.Status, ErrorCode: p.ErrorCode, Details: p.Details, Fields: p.Fields, Record: p.Record}
+}
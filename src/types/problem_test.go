@@ -0,0 +1,37 @@
+package types
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestError_Problem(t *testing.T) {
+	fields := []FieldError{{"ttl", CodeOutOfRange, "out of range"}}
+	tests := []struct {
+		name string
+		err  *Error
+		want Problem
+	}{
+		{"with error code", ValidationError("Invalid record", fields), Problem{
+			Type: "urn:bindman:error:INVALID_VALUE", Title: "Bad Request", Status: http.StatusBadRequest, Detail: "Invalid record",
+			Instance: "/records", ErrorCode: CodeInvalidValue, Details: []string{"out of range"}, Fields: fields,
+		}},
+		{"without error code", &Error{Message: "Gone", Code: http.StatusGone}, Problem{
+			Type: "about:blank", Title: "Gone", Status: http.StatusGone, Detail: "Gone", Instance: "/records",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.err.Problem("/records")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Problem() = %#v, want %#v", got, tt.want)
+			}
+			back := got.ToError()
+			if back.Code != tt.err.Code || back.ErrorCode != tt.err.ErrorCode || back.Message != tt.err.Message ||
+				!reflect.DeepEqual(back.Fields, tt.err.Fields) {
+				t.Errorf("ToError() = %#v, want %#v", back, tt.err)
+			}
+		})
+	}
+}
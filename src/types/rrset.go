@@ -0,0 +1,134 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RRSet groups the values of all the records sharing a name and a type, like the addresses of a round-robin A record
+type RRSet struct {
+	// Name the DNS host name
+	Name string `json:"name"`
+
+	// UnicodeName the Unicode form of an internationalized Name, which always holds the ASCII form. Only set on responses
+	UnicodeName string `json:"unicodeName,omitempty"`
+
+	// Type the record type
+	Type string `json:"type"`
+
+	// TTL the time to live of the records, in seconds. Zero means the default TTL of the manager
+	TTL int `json:"ttl,omitempty"`
+
+	// Values the values of the records, in the presentation format
+	Values []string `json:"values"`
+}
+
+// RRSetChange adds and removes values of a RRSet as a single operation
+type RRSetChange struct {
+	// Add the values to add to the set. Values already on the set are ignored
+	Add []string `json:"add,omitempty"`
+
+	// Remove the values to remove from the set. Values not on the set are ignored
+	Remove []string `json:"remove,omitempty"`
+
+	// TTL the new TTL of the set. Zero keeps the current one
+	TTL int `json:"ttl,omitempty"`
+}
+
+// Check verifies if the RRSet satisfies the same conditions as its records. A set must have at least one value and no
+// duplicated ones. Returns every violation found
+func (set *RRSet) Check() []string {
+	return FieldMessages(set.FieldErrors())
+}
+
+// FieldErrors returns the violations found by Check, each one with the field it concerns
+func (set *RRSet) FieldErrors() []FieldError {
+	emptyValueErrorMessage := "the value of field '%s' cannot be empty"
+	var errs []FieldError
+
+	typeSet := strings.TrimSpace(set.Type) != ""
+	if strings.TrimSpace(set.Name) == "" {
+		errs = append(errs, FieldError{"name", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "name")})
+	} else {
+		errs = append(errs, invalidField("name", validateName("name", set.Name, true))...)
+	}
+	if !typeSet {
+		errs = append(errs, FieldError{"type", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "type")})
+	} else if typeErrs := validateType(set.Type); typeErrs != nil {
+		errs = append(errs, invalidField("type", typeErrs)...)
+		typeSet = false
+	}
+	if set.TTL < 0 || set.TTL > MaxTTL {
+		errs = append(errs, FieldError{"ttl", CodeOutOfRange, fmt.Sprintf("the value of field 'ttl' must be between 0 and %d", MaxTTL)})
+	}
+
+	if len(set.Values) == 0 {
+		errs = append(errs, FieldError{"values", CodeRequired, "the field 'values' must have at least one value"})
+	}
+	seen := map[string]bool{}
+	for _, value := range set.Values {
+		switch {
+		case strings.TrimSpace(value) == "":
+			errs = append(errs, FieldError{"values", CodeRequired, fmt.Sprintf(emptyValueErrorMessage, "values")})
+		case seen[value]:
+			errs = append(errs, FieldError{"values", CodeDuplicated, fmt.Sprintf("the value '%s' is duplicated", value)})
+		case typeSet:
+			errs = append(errs, invalidField("values", validateValue(set.Type, value))...)
+		}
+		seen[value] = true
+	}
+	return errs
+}
+
+// SyncUnicodeName sets UnicodeName to the Unicode form of Name when it holds A-labels, clearing it otherwise
+func (set *RRSet) SyncUnicodeName() {
+	set.UnicodeName = unicodeName(set.Name)
+}
+
+// Records returns one DNSRecord per value of the set
+func (set *RRSet) Records() []DNSRecord {
+	records := make([]DNSRecord, len(set.Values))
+	for i, value := range set.Values {
+		records[i] = DNSRecord{Name: set.Name, Type: set.Type, TTL: set.TTL, Value: value}
+		records[i].SyncValue()
+	}
+	return records
+}
+
+// Apply returns the values of the set after the change
+func (set *RRSet) Apply(change RRSetChange) RRSet {
+	result := RRSet{Name: set.Name, Type: set.Type, TTL: set.TTL}
+	if change.TTL != 0 {
+		result.TTL = change.TTL
+	}
+	removed := map[string]bool{}
+	for _, value := range change.Remove {
+		removed[value] = true
+	}
+	present := map[string]bool{}
+	for _, value := range append(append([]string{}, set.Values...), change.Add...) {
+		if !removed[value] && !present[value] {
+			result.Values = append(result.Values, value)
+			present[value] = true
+		}
+	}
+	return result
+}
+
+// GroupRRSets groups records sharing a name and a type into RRSets, keeping the order in which they first appear.
+// The TTL of a set is the one of its first record
+func GroupRRSets(records []DNSRecord) []RRSet {
+	var sets []RRSet
+	index := map[string]int{}
+	for _, record := range records {
+		key := record.Name + "\n" + strings.ToUpper(record.Type)
+		i, ok := index[key]
+		if !ok {
+			i = len(sets)
+			index[key] = i
+			sets = append(sets, RRSet{Name: record.Name, Type: record.Type, TTL: record.TTL})
+		}
+		sets[i].Values = append(sets[i].Values, record.Value)
+	}
+	return sets
+}
@@ -0,0 +1,145 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRRSet_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     RRSet
+		wantErr int
+	}{
+		{"valid set", RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.2"}}, 0},
+		{"no values", RRSet{Name: "test.com", Type: "A"}, 1},
+		{"empty name and type", RRSet{Values: []string{"10.0.0.1"}}, 2},
+		{"invalid values", RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1", "::1", "x"}}, 2},
+		{"duplicated value", RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.1"}}, 1},
+		{"empty value", RRSet{Name: "test.com", Type: "TXT", Values: []string{" "}}, 1},
+		{"invalid ttl", RRSet{Name: "test.com", Type: "A", TTL: -1, Values: []string{"10.0.0.1"}}, 1},
+		{"invalid type skips value checks", RRSet{Name: "test.com", Type: "A_", Values: []string{"x"}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if errs := tt.set.Check(); len(errs) != tt.wantErr {
+				t.Errorf("Check() = %v, want %d errors", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRRSet_Apply(t *testing.T) {
+	set := RRSet{Name: "test.com", Type: "A", TTL: 60, Values: []string{"10.0.0.1", "10.0.0.2"}}
+	tests := []struct {
+		name   string
+		change RRSetChange
+		want   RRSet
+	}{
+		{"add values", RRSetChange{Add: []string{"10.0.0.3", "10.0.0.1"}},
+			RRSet{Name: "test.com", Type: "A", TTL: 60, Values: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}}},
+		{"remove values", RRSetChange{Remove: []string{"10.0.0.1", "10.0.0.9"}},
+			RRSet{Name: "test.com", Type: "A", TTL: 60, Values: []string{"10.0.0.2"}}},
+		{"remove every value", RRSetChange{Remove: []string{"10.0.0.1", "10.0.0.2"}},
+			RRSet{Name: "test.com", Type: "A", TTL: 60}},
+		{"change the ttl", RRSetChange{TTL: 300},
+			RRSet{Name: "test.com", Type: "A", TTL: 300, Values: []string{"10.0.0.1", "10.0.0.2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.Apply(tt.change); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if len(set.Values) != 2 {
+		t.Errorf("Apply() must not modify the set, got %+v", set)
+	}
+}
+
+func TestGroupRRSets(t *testing.T) {
+	records := []DNSRecord{
+		{Name: "a.test.com", Type: "A", Value: "10.0.0.1", TTL: 60},
+		{Name: "b.test.com", Type: "A", Value: "10.0.0.3"},
+		{Name: "a.test.com", Type: "a", Value: "10.0.0.2", TTL: 120},
+		{Name: "a.test.com", Type: "TXT", Value: "text"},
+	}
+	want := []RRSet{
+		{Name: "a.test.com", Type: "A", TTL: 60, Values: []string{"10.0.0.1", "10.0.0.2"}},
+		{Name: "b.test.com", Type: "A", Values: []string{"10.0.0.3"}},
+		{Name: "a.test.com", Type: "TXT", Values: []string{"text"}},
+	}
+	if got := GroupRRSets(records); !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupRRSets() = %+v, want %+v", got, want)
+	}
+}
+
+func TestNewRRSetDNSManager(t *testing.T) {
+	sets := &rrSetManagerMock{sets: map[string]RRSet{}}
+	manager := NewRRSetDNSManager(sets)
+
+	if err := manager.AddDNSRecord(DNSRecord{Name: "test.com", Type: "MX", MX: &MXData{Preference: 10, Host: "mail.test.com"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sets.sets["test.com/MX"].Values; !reflect.DeepEqual(got, []string{"10 mail.test.com"}) {
+		t.Errorf("expected the set to hold the record value, got %v", got)
+	}
+
+	sets.sets["test.com/A"] = RRSet{Name: "test.com", Type: "A", Values: []string{"10.0.0.1", "10.0.0.2"}}
+	record, err := manager.GetDNSRecord("test.com", "A")
+	if err != nil || record == nil || record.Value != "10.0.0.1" {
+		t.Errorf("expected the first value of the set, got %+v, %v", record, err)
+	}
+	if record, err := manager.GetDNSRecord("missing.com", "A"); err != nil || record != nil {
+		t.Errorf("expected no record, got %+v, %v", record, err)
+	}
+	records, err := manager.GetDNSRecords()
+	if err != nil || len(records) != 3 {
+		t.Errorf("expected one record per value, got %+v, %v", records, err)
+	}
+
+	if err := manager.UpdateDNSRecord(DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sets.sets["test.com/A"].Values; !reflect.DeepEqual(got, []string{"10.0.0.3"}) {
+		t.Errorf("expected the update to replace the set, got %v", got)
+	}
+	if err := manager.RemoveDNSRecord("test.com", "A"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sets.sets["test.com/A"]; ok {
+		t.Error("expected the set to be removed")
+	}
+	if _, ok := manager.(RRSetManager); !ok {
+		t.Error("expected the DNSManager to keep implementing RRSetManager")
+	}
+}
+
+type rrSetManagerMock struct {
+	sets map[string]RRSet
+}
+
+func (m *rrSetManagerMock) GetRRSets() ([]RRSet, error) {
+	var sets []RRSet
+	for _, set := range m.sets {
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+func (m *rrSetManagerMock) GetRRSet(name, recordType string) (*RRSet, error) {
+	if set, ok := m.sets[name+"/"+recordType]; ok {
+		return &set, nil
+	}
+	return nil, nil
+}
+
+func (m *rrSetManagerMock) ReplaceRRSet(set RRSet) error {
+	m.sets[set.Name+"/"+set.Type] = set
+	return nil
+}
+
+func (m *rrSetManagerMock) RemoveRRSet(name, recordType string) error {
+	delete(m.sets, name+"/"+recordType)
+	return nil
+}
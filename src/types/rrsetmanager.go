@@ -0,0 +1,72 @@
+package types
+
+import (
+	"context"
+	"io"
+)
+
+// rrSetDNSManager exposes a RRSetManager through the single-value DNSManager interface
+type rrSetDNSManager struct {
+	RRSetManager
+}
+
+// NewRRSetDNSManager returns a DNSManager backed by a RRSetManager, so that managers supporting several values per name
+// and type only need to implement the RRSet operations. The records of a set are listed one per value; a single record
+// is its set with the first value; adding or updating a record replaces the whole set by that single value
+func NewRRSetDNSManager(manager RRSetManager) DNSManager {
+	return &rrSetDNSManager{RRSetManager: manager}
+}
+
+// GetDNSRecords lists one record per value of every RRSet
+func (m *rrSetDNSManager) GetDNSRecords() ([]DNSRecord, error) {
+	sets, err := m.GetRRSets()
+	if err != nil {
+		return nil, err
+	}
+	var records []DNSRecord
+	for i := range sets {
+		records = append(records, sets[i].Records()...)
+	}
+	return records, nil
+}
+
+// GetDNSRecord returns the first value of the RRSet identified by name and type
+func (m *rrSetDNSManager) GetDNSRecord(name, recordType string) (*DNSRecord, error) {
+	set, err := m.GetRRSet(name, recordType)
+	if err != nil || set == nil || len(set.Values) == 0 {
+		return nil, err
+	}
+	record := set.Records()[0]
+	return &record, nil
+}
+
+// RemoveDNSRecord removes the whole RRSet
+func (m *rrSetDNSManager) RemoveDNSRecord(name, recordType string) error {
+	return m.RemoveRRSet(name, recordType)
+}
+
+// AddDNSRecord creates a RRSet with the record value
+func (m *rrSetDNSManager) AddDNSRecord(record DNSRecord) error {
+	return m.ReplaceRRSet(singleValueRRSet(record))
+}
+
+// UpdateDNSRecord replaces the RRSet by the record value
+func (m *rrSetDNSManager) UpdateDNSRecord(record DNSRecord) error {
+	return m.ReplaceRRSet(singleValueRRSet(record))
+}
+
+// singleValueRRSet returns the RRSet made of the record only
+func singleValueRRSet(record DNSRecord) RRSet {
+	return RRSet{Name: record.Name, Type: record.Type, TTL: record.TTL, Values: []string{record.effectiveValue()}}
+}
+
+// Shutdown forwards the shutdown to the RRSetManager when it is a Shutdowner or an io.Closer
+func (m *rrSetDNSManager) Shutdown(ctx context.Context) error {
+	switch manager := m.RRSetManager.(type) {
+	case Shutdowner:
+		return manager.Shutdown(ctx)
+	case io.Closer:
+		return manager.Close()
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package types
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Headers carrying the HMAC signature of a request
+const (
+	// HeaderKeyID identifies the secret used to sign the request
+	HeaderKeyID = "X-Bindman-Key-Id"
+
+	// HeaderTimestamp the unix time, in seconds, when the request was signed
+	HeaderTimestamp = "X-Bindman-Timestamp"
+
+	// HeaderNonce a random value that makes every signed request unique
+	HeaderNonce = "X-Bindman-Nonce"
+
+	// HeaderSignature the hex encoded signature of the request
+	HeaderSignature = "X-Bindman-Signature"
+)
+
+// Sign computes the hex encoded HMAC-SHA256 signature of a request. The signed content is the method, the request URI
+// (path and query), the timestamp, the nonce and the SHA-256 of the body, separated by new lines
+func Sign(secret []byte, method, requestURI, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	content := strings.Join([]string{strings.ToUpper(method), requestURI, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n")
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature tells if signature is the valid signature of the request described by the other parameters
+func VerifySignature(signature string, secret []byte, method, requestURI, timestamp, nonce string, body []byte) bool {
+	expected := Sign(secret, method, requestURI, timestamp, nonce, body)
+	return hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected))
+}
@@ -0,0 +1,42 @@
+package types
+
+import "testing"
+
+func TestSign(t *testing.T) {
+	secret := []byte("secret")
+	signature := Sign(secret, "post", "/records", "1570000000", "nonce", []byte(`{"name":"a"}`))
+
+	if len(signature) != 64 {
+		t.Fatalf("expected a hex encoded SHA-256 HMAC, got %s", signature)
+	}
+	if signature != Sign(secret, "POST", "/records", "1570000000", "nonce", []byte(`{"name":"a"}`)) {
+		t.Error("the method must be case insensitive")
+	}
+
+	tests := []struct {
+		name       string
+		secret     string
+		method     string
+		requestURI string
+		timestamp  string
+		nonce      string
+		body       string
+		want       bool
+	}{
+		{"same request", "secret", "POST", "/records", "1570000000", "nonce", `{"name":"a"}`, true},
+		{"other secret", "other", "POST", "/records", "1570000000", "nonce", `{"name":"a"}`, false},
+		{"other method", "secret", "PUT", "/records", "1570000000", "nonce", `{"name":"a"}`, false},
+		{"other path", "secret", "POST", "/records/a/A", "1570000000", "nonce", `{"name":"a"}`, false},
+		{"other timestamp", "secret", "POST", "/records", "1570000001", "nonce", `{"name":"a"}`, false},
+		{"other nonce", "secret", "POST", "/records", "1570000000", "other", `{"name":"a"}`, false},
+		{"other body", "secret", "POST", "/records", "1570000000", "nonce", `{"name":"b"}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := VerifySignature(signature, []byte(tt.secret), tt.method, tt.requestURI, tt.timestamp, tt.nonce, []byte(tt.body))
+			if got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
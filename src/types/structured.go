@@ -0,0 +1,202 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MXData holds the fields of a MX record value
+type MXData struct {
+	// Preference the preference of this exchange among the others of the same name; lower values are preferred
+	Preference uint16 `json:"preference"`
+
+	// Host the host name of the mail exchange
+	Host string `json:"host"`
+}
+
+// String renders the MX data in its presentation format, e.g. "10 mail.example.com"
+func (mx MXData) String() string {
+	return fmt.Sprintf("%d %s", mx.Preference, mx.Host)
+}
+
+// ParseMX parses a MX value in its presentation format
+func ParseMX(value string) (*MXData, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid MX value '%s': expected '<preference> <host>'", value)
+	}
+	preference, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MX preference '%s'", fields[0])
+	}
+	return &MXData{Preference: uint16(preference), Host: fields[1]}, nil
+}
+
+// SRVData holds the fields of a SRV record value
+type SRVData struct {
+	// Priority the priority of the target; lower values are preferred
+	Priority uint16 `json:"priority"`
+
+	// Weight the relative weight among targets with the same priority
+	Weight uint16 `json:"weight"`
+
+	// Port the port of the service on the target
+	Port uint16 `json:"port"`
+
+	// Target the host name providing the service
+	Target string `json:"target"`
+}
+
+// String renders the SRV data in its presentation format, e.g. "10 60 5060 sip.example.com"
+func (srv SRVData) String() string {
+	return fmt.Sprintf("%d %d %d %s", srv.Priority, srv.Weight, srv.Port, srv.Target)
+}
+
+// ParseSRV parses a SRV value in its presentation format
+func ParseSRV(value string) (*SRVData, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("invalid SRV value '%s': expected '<priority> <weight> <port> <target>'", value)
+	}
+	var numbers [3]uint16
+	for i, name := range []string{"priority", "weight", "port"} {
+		n, err := strconv.ParseUint(fields[i], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SRV %s '%s'", name, fields[i])
+		}
+		numbers[i] = uint16(n)
+	}
+	return &SRVData{Priority: numbers[0], Weight: numbers[1], Port: numbers[2], Target: fields[3]}, nil
+}
+
+// CAAData holds the fields of a CAA record value
+type CAAData struct {
+	// Flags the CAA flags; 128 marks the property as critical
+	Flags uint8 `json:"flags"`
+
+	// Tag the property tag, e.g. issue, issuewild or iodef
+	Tag string `json:"tag"`
+
+	// Value the property value, unquoted
+	Value string `json:"value"`
+}
+
+// String renders the CAA data in its presentation format, e.g. `0 issue "letsencrypt.org"`
+func (caa CAAData) String() string {
+	value := strings.Replace(strings.Replace(caa.Value, `\`, `\\`, -1), `"`, `\"`, -1)
+	return fmt.Sprintf(`%d %s "%s"`, caa.Flags, caa.Tag, value)
+}
+
+// ParseCAA parses a CAA value in its presentation format. The property value may be quoted or not
+func ParseCAA(value string) (*CAAData, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("invalid CAA value '%s': expected '<flags> <tag> <value>'", value)
+	}
+	flags, err := strconv.ParseUint(fields[0], 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CAA flags '%s'", fields[0])
+	}
+	property := strings.TrimSpace(fields[2])
+	if strings.HasPrefix(property, `"`) {
+		strs, err := splitCharacterStrings(property)
+		if err != nil || len(strs) != 1 {
+			return nil, fmt.Errorf("invalid CAA property value %s", property)
+		}
+		property = strs[0]
+	}
+	return &CAAData{Flags: uint8(flags), Tag: fields[1], Value: property}, nil
+}
+
+// structuredValue returns the presentation format of the structured field matching the record type, and the name of
+// every structured field set on the record
+func (record *DNSRecord) structuredValue() (string, []string) {
+	var value string
+	var fields []string
+	recordType := strings.ToUpper(strings.TrimSpace(record.Type))
+	if record.MX != nil {
+		fields = append(fields, "mx")
+		if recordType == "MX" {
+			value = record.MX.String()
+		}
+	}
+	if record.SRV != nil {
+		fields = append(fields, "srv")
+		if recordType == "SRV" {
+			value = record.SRV.String()
+		}
+	}
+	if record.CAA != nil {
+		fields = append(fields, "caa")
+		if recordType == "CAA" {
+			value = record.CAA.String()
+		}
+	}
+	return value, fields
+}
+
+// effectiveValue returns the record value, rendered from the structured field when Value is empty
+func (record *DNSRecord) effectiveValue() string {
+	if strings.TrimSpace(record.Value) == "" {
+		value, _ := record.structuredValue()
+		return value
+	}
+	return record.Value
+}
+
+// checkStructured verifies the structured fields match the record type and agree with Value
+func (record *DNSRecord) checkStructured() []FieldError {
+	var errs []FieldError
+	rendered, fields := record.structuredValue()
+	recordType := strings.ToUpper(strings.TrimSpace(record.Type))
+	for _, field := range fields {
+		if field != strings.ToLower(recordType) {
+			errs = append(errs, FieldError{field, CodeInvalidValue,
+				fmt.Sprintf("the field '%s' is only allowed on records of type '%s'", field, strings.ToUpper(field))})
+		}
+	}
+	if rendered == "" || strings.TrimSpace(record.Value) == "" {
+		return errs
+	}
+
+	var parsed fmt.Stringer
+	var err error
+	switch recordType {
+	case "MX":
+		parsed, err = ParseMX(record.Value)
+	case "SRV":
+		parsed, err = ParseSRV(record.Value)
+	case "CAA":
+		parsed, err = ParseCAA(record.Value)
+	}
+	if err == nil && parsed.String() != rendered {
+		errs = append(errs, FieldError{"value", CodeInvalidValue,
+			fmt.Sprintf("the fields 'value' and '%s' do not match", strings.ToLower(recordType))})
+	}
+	return errs
+}
+
+// SyncValue keeps Value and the structured field of the record type consistent: Value is rendered from the structured
+// field when empty, and the structured field is parsed from Value when nil. Values that cannot be parsed are left for
+// Check to report
+func (record *DNSRecord) SyncValue() {
+	if strings.TrimSpace(record.Value) == "" {
+		record.Value, _ = record.structuredValue()
+		return
+	}
+	switch strings.ToUpper(strings.TrimSpace(record.Type)) {
+	case "MX":
+		if record.MX == nil {
+			record.MX, _ = ParseMX(record.Value)
+		}
+	case "SRV":
+		if record.SRV == nil {
+			record.SRV, _ = ParseSRV(record.Value)
+		}
+	case "CAA":
+		if record.CAA == nil {
+			record.CAA, _ = ParseCAA(record.Value)
+		}
+	}
+}
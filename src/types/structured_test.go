@@ -0,0 +1,110 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseStructured(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		parse   func(string) (interface{ String() string }, error)
+		want    string
+		wantErr bool
+	}{
+		{"MX", "10   mail.test.com.", func(v string) (interface{ String() string }, error) { return ParseMX(v) }, "10 mail.test.com.", false},
+		{"MX missing host", "10", func(v string) (interface{ String() string }, error) { return ParseMX(v) }, "", true},
+		{"MX invalid preference", "-1 mail.test.com", func(v string) (interface{ String() string }, error) { return ParseMX(v) }, "", true},
+		{"SRV", "10 60 5060 sip.test.com", func(v string) (interface{ String() string }, error) { return ParseSRV(v) }, "10 60 5060 sip.test.com", false},
+		{"SRV invalid port", "10 60 99999 sip.test.com", func(v string) (interface{ String() string }, error) { return ParseSRV(v) }, "", true},
+		{"CAA quoted", `0 issue "letsencrypt.org"`, func(v string) (interface{ String() string }, error) { return ParseCAA(v) }, `0 issue "letsencrypt.org"`, false},
+		{"CAA unquoted", `128 iodef mailto:security@test.com`, func(v string) (interface{ String() string }, error) { return ParseCAA(v) }, `128 iodef "mailto:security@test.com"`, false},
+		{"CAA escaped quotes", `0 issue "a\"b"`, func(v string) (interface{ String() string }, error) { return ParseCAA(v) }, `0 issue "a\"b"`, false},
+		{"CAA invalid flags", `256 issue "letsencrypt.org"`, func(v string) (interface{ String() string }, error) { return ParseCAA(v) }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.parse(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parse error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.String() != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestDNSRecord_SyncValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		record DNSRecord
+		want   DNSRecord
+	}{
+		{
+			"value rendered from the structured field",
+			DNSRecord{Name: "test.com", Type: "MX", MX: &MXData{Preference: 10, Host: "mail.test.com"}},
+			DNSRecord{Name: "test.com", Type: "MX", Value: "10 mail.test.com", MX: &MXData{Preference: 10, Host: "mail.test.com"}},
+		},
+		{
+			"structured field parsed from the value",
+			DNSRecord{Name: "_sip._tcp.test.com", Type: "srv", Value: "10 60 5060 sip.test.com"},
+			DNSRecord{Name: "_sip._tcp.test.com", Type: "srv", Value: "10 60 5060 sip.test.com", SRV: &SRVData{Priority: 10, Weight: 60, Port: 5060, Target: "sip.test.com"}},
+		},
+		{
+			"invalid value left as is",
+			DNSRecord{Name: "test.com", Type: "CAA", Value: "issue"},
+			DNSRecord{Name: "test.com", Type: "CAA", Value: "issue"},
+		},
+		{
+			"other types are not changed",
+			DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1"},
+			DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.record.SyncValue()
+			if !reflect.DeepEqual(tt.record, tt.want) {
+				t.Errorf("want %+v, got %+v", tt.want, tt.record)
+			}
+		})
+	}
+}
+
+func TestCheckDNSRecord_Structured(t *testing.T) {
+	testCases := []struct {
+		name     string
+		record   DNSRecord
+		expected []string
+	}{
+		{"only the structured field", DNSRecord{Name: "test.com", Type: "MX", MX: &MXData{Preference: 10, Host: "mail.test.com"}}, nil},
+		{"structured field and value agree", DNSRecord{Name: "test.com", Type: "CAA", Value: `0 issue letsencrypt.org`, CAA: &CAAData{Tag: "issue", Value: "letsencrypt.org"}}, nil},
+		{"structured field and value disagree", DNSRecord{Name: "test.com", Type: "MX", Value: "20 mail.test.com", MX: &MXData{Preference: 10, Host: "mail.test.com"}}, []string{"the fields 'value' and 'mx' do not match"}},
+		{"invalid structured field", DNSRecord{Name: "_sip._tcp.test.com", Type: "SRV", SRV: &SRVData{Target: "10.0.0.1"}}, []string{"the SRV target must be a host name, not an IP address"}},
+		{"structured field of another type", DNSRecord{Name: "test.com", Type: "A", Value: "10.0.0.1", MX: &MXData{Host: "mail.test.com"}}, []string{"the field 'mx' is only allowed on records of type 'MX'"}},
+	}
+	for _, test := range testCases {
+		runCheckTest(t, test.name, test.record, test.expected)
+	}
+}
+
+func TestDNSRecord_JSON(t *testing.T) {
+	record := DNSRecord{Name: "test.com", Type: "MX", Value: "10 mail.test.com", MX: &MXData{Preference: 10, Host: "mail.test.com"}}
+	data, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"name":"test.com","value":"10 mail.test.com","type":"MX","mx":{"preference":10,"host":"mail.test.com"}}` {
+		t.Errorf("unexpected JSON %s", data)
+	}
+	var got DNSRecord
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, record) {
+		t.Errorf("want %+v, got %+v", record, got)
+	}
+}
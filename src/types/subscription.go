@@ -0,0 +1,132 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// subscriptionVerbs the verbs a subscription may be notified of, named as on the policies of the hook
+	subscriptionVerbs = []string{"add", "update", "remove"}
+
+	// subscriptionIDRegexp the IDs of subscriptions, which are part of their urls
+	subscriptionIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+)
+
+// Subscription registers a URL notified of the changes of the records made through the webhook. Each change matching
+// the filter is POSTed to the URL as a RecordEvent, signed with the secret as described by VerifyNotification
+type Subscription struct {
+	// ID identifies the subscription. Generated when empty
+	ID string `json:"id" yaml:"id"`
+
+	// URL the http or https address the events are POSTed to
+	URL string `json:"url" yaml:"url"`
+
+	// Filter selects the changes the subscription is notified of
+	Filter SubscriptionFilter `json:"filter" yaml:"filter"`
+
+	// Secret signs the notifications. Never returned by the webhook
+	Secret string `json:"secret,omitempty" yaml:"secret"`
+
+	// Owner the caller that registered the subscription, which must be allowed to list the records it is notified of.
+	// Empty for subscriptions registered by configuration. Set by the webhook
+	Owner string `json:"owner,omitempty" yaml:"-"`
+}
+
+// SubscriptionFilter selects changes of records. An empty list matches any value
+type SubscriptionFilter struct {
+	// Zones the zones the names must be equal to or under, e.g. "example.com" matches "example.com" and "www.example.com"
+	Zones []string `json:"zones,omitempty" yaml:"zones"`
+
+	// Types the record types, e.g. A and CNAME
+	Types []string `json:"types,omitempty" yaml:"types"`
+
+	// Verbs the operations: add, update and remove
+	Verbs []string `json:"verbs,omitempty" yaml:"verbs"`
+}
+
+// DeadLetter keeps an event whose delivery to a subscription failed on every attempt
+type DeadLetter struct {
+	// Subscription the ID of the subscription
+	Subscription string `json:"subscription"`
+
+	// Event the undelivered event
+	Event RecordEvent `json:"event"`
+
+	// Attempts how many times the delivery was attempted
+	Attempts int `json:"attempts"`
+
+	// Error the failure of the last attempt
+	Error string `json:"error"`
+
+	// Time when the delivery was given up
+	Time time.Time `json:"time"`
+}
+
+// Check verifies if the subscription has a valid URL, a secret and a valid filter. Returns every violation found
+func (s *Subscription) Check() []string {
+	return FieldMessages(s.FieldErrors())
+}
+
+// FieldErrors returns the violations found by Check, each one with the field it concerns
+func (s *Subscription) FieldErrors() []FieldError {
+	var errs []FieldError
+	if s.ID != "" && !subscriptionIDRegexp.MatchString(s.ID) {
+		errs = append(errs, FieldError{"id", CodeInvalidValue,
+			"the value of field 'id' must have at most 64 letters, digits, dots, hyphens and underscores"})
+	}
+	if strings.TrimSpace(s.URL) == "" {
+		errs = append(errs, FieldError{"url", CodeRequired, "the value of field 'url' cannot be empty"})
+	} else if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, FieldError{"url", CodeInvalidValue, fmt.Sprintf("the url '%s' must be an absolute http or https url", s.URL)})
+	}
+	if s.Secret == "" {
+		errs = append(errs, FieldError{"secret", CodeRequired, "the value of field 'secret' cannot be empty"})
+	}
+	for _, zone := range s.Filter.Zones {
+		errs = append(errs, invalidField("filter.zones", validateName("filter.zones", zone, false))...)
+	}
+	for _, recordType := range s.Filter.Types {
+		errs = append(errs, invalidField("filter.types", validateType(recordType))...)
+	}
+	for _, verb := range s.Filter.Verbs {
+		if !containsFold(subscriptionVerbs, verb) {
+			errs = append(errs, FieldError{"filter.verbs", CodeInvalidValue,
+				fmt.Sprintf("the verb '%s' must be one of %s", verb, strings.Join(subscriptionVerbs, ", "))})
+		}
+	}
+	return errs
+}
+
+// Matches tells if the filter selects the change of the record identified by name and type made with the verb
+func (f *SubscriptionFilter) Matches(verb, name, recordType string) bool {
+	if len(f.Verbs) > 0 && !containsFold(f.Verbs, verb) {
+		return false
+	}
+	if len(f.Types) > 0 && !containsFold(f.Types, recordType) {
+		return false
+	}
+	if len(f.Zones) == 0 {
+		return true
+	}
+	name = canonicalName(name)
+	for _, zone := range f.Zones {
+		if zone = canonicalName(zone); name == zone || strings.HasSuffix(name, "."+zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyNotification tells if the notification received from the webhook was signed with the secret of the
+// subscription. Notifications are signed as the requests of the HMAC authentication, see Sign, with the ID of the
+// subscription as key id. The request URI must be the one of the subscription URL, as sent by the webhook
+func VerifyNotification(secret []byte, r *http.Request, body []byte) bool {
+	signature := r.Header.Get(HeaderSignature)
+	return signature != "" && VerifySignature(signature, secret, r.Method, r.URL.RequestURI(),
+		r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), body)
+}
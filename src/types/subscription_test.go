@@ -0,0 +1,78 @@
+package types
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubscription_Check(t *testing.T) {
+	tests := []struct {
+		name         string
+		subscription Subscription
+		wantFields   []string
+	}{
+		{"valid", Subscription{ID: "cmdb-1", URL: "https://cmdb.example.com/dns", Secret: "s",
+			Filter: SubscriptionFilter{Zones: []string{"example.com"}, Types: []string{"A"}, Verbs: []string{"add", "Remove"}}}, nil},
+		{"missing fields", Subscription{}, []string{"url", "secret"}},
+		{"relative url", Subscription{URL: "/dns", Secret: "s"}, []string{"url"}},
+		{"other scheme", Subscription{URL: "ftp://example.com", Secret: "s"}, []string{"url"}},
+		{"invalid id", Subscription{ID: "a/b", URL: "http://example.com", Secret: "s"}, []string{"id"}},
+		{"invalid filter", Subscription{URL: "http://example.com", Secret: "s",
+			Filter: SubscriptionFilter{Zones: []string{"exa mple.com"}, Types: []string{"A-"}, Verbs: []string{"delete"}}},
+			[]string{"filter.zones", "filter.types", "filter.verbs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fields []string
+			for _, err := range tt.subscription.FieldErrors() {
+				fields = append(fields, err.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("want errors on %v, got %v", tt.wantFields, tt.subscription.Check())
+			}
+		})
+	}
+}
+
+func TestSubscriptionFilter_Matches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter SubscriptionFilter
+		want   bool
+	}{
+		{"empty", SubscriptionFilter{}, true},
+		{"zone", SubscriptionFilter{Zones: []string{"example.org", "Example.com."}}, true},
+		{"other zone", SubscriptionFilter{Zones: []string{"ample.com"}}, false},
+		{"type", SubscriptionFilter{Types: []string{"a"}}, true},
+		{"other type", SubscriptionFilter{Types: []string{"CNAME"}}, false},
+		{"verb", SubscriptionFilter{Verbs: []string{"update"}}, true},
+		{"other verb", SubscriptionFilter{Verbs: []string{"add", "remove"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches("update", "www.example.com", "A"); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyNotification(t *testing.T) {
+	body := []byte(`{"op":"add"}`)
+	r := httptest.NewRequest("POST", "/dns?team=a", strings.NewReader(string(body)))
+	r.Header.Set(HeaderTimestamp, "1700000000")
+	r.Header.Set(HeaderNonce, "n1")
+	r.Header.Set(HeaderSignature, Sign([]byte("secret"), "POST", "/dns?team=a", "1700000000", "n1", body))
+
+	if !VerifyNotification([]byte("secret"), r, body) {
+		t.Errorf("expected a valid signature")
+	}
+	if VerifyNotification([]byte("other"), r, body) {
+		t.Errorf("expected an invalid signature with another secret")
+	}
+	if VerifyNotification([]byte("secret"), r, []byte(`{"op":"delete"}`)) {
+		t.Errorf("expected an invalid signature with another body")
+	}
+}
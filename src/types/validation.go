@@ -0,0 +1,242 @@
+package types
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// maxNameLength is the greatest length of a domain name in its text form, without the trailing dot
+	maxNameLength = 253
+
+	// maxLabelLength is the greatest length of a domain name label
+	maxLabelLength = 63
+
+	// maxCharacterStringLength is the greatest length of a character-string, as used on TXT records
+	maxCharacterStringLength = 255
+
+	// maxTXTLength is the greatest length of the whole value of a TXT record
+	maxTXTLength = 65535
+
+	// valueMessage describes a value that is not valid for the record type
+	valueMessage = "the value of field 'value' must be %s for records of type '%s'"
+)
+
+var (
+	labelRegexp      = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	typeRegexp       = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	caaTagRegexp     = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	recordValidators = map[string]func(value string) []string{
+		"A":     validateA,
+		"AAAA":  validateAAAA,
+		"CNAME": validateTarget("CNAME"),
+		"NS":    validateTarget("NS"),
+		"PTR":   validateTarget("PTR"),
+		"MX":    validateMX,
+		"TXT":   validateTXT,
+		"SRV":   validateSRV,
+		"CAA":   validateCAA,
+	}
+)
+
+// validateName checks the syntax of a domain name as defined by RFC 1123: at most 253 characters, labels between 1 and
+// 63 characters made of letters, digits and hyphens, not starting or ending with a hyphen. Owner names additionally
+// accept labels starting with an underscore, like '_dmarc', and a leftmost wildcard label. Internationalized names are
+// checked on their ASCII form, see ToASCII
+func validateName(field, name string, owner bool) []string {
+	var errs []string
+	if !isASCII(name) {
+		ascii, err := ToASCII(name)
+		if err != nil {
+			return []string{fmt.Sprintf("the value of field '%s' is %v", field, err)}
+		}
+		name = ascii
+	}
+	name = strings.TrimSuffix(name, ".")
+	if len(name) > maxNameLength {
+		errs = append(errs, fmt.Sprintf("the value of field '%s' must have at most %d characters", field, maxNameLength))
+	}
+	for i, label := range strings.Split(name, ".") {
+		if owner && i == 0 && label == "*" {
+			continue
+		}
+		if len(label) == 0 || len(label) > maxLabelLength {
+			errs = append(errs, fmt.Sprintf("the labels of field '%s' must have between 1 and %d characters, got '%s'", field, maxLabelLength, label))
+			continue
+		}
+		chars := label
+		if owner {
+			chars = strings.TrimPrefix(label, "_")
+		}
+		if !labelRegexp.MatchString(chars) {
+			errs = append(errs, fmt.Sprintf("the label '%s' of field '%s' must only contain letters, digits and hyphens", label, field))
+		} else if strings.HasPrefix(chars, "-") || strings.HasSuffix(label, "-") {
+			errs = append(errs, fmt.Sprintf("the label '%s' of field '%s' cannot start or end with a hyphen", label, field))
+		} else if strings.HasPrefix(strings.ToLower(label), "xn--") {
+			if !isALabel(label) {
+				errs = append(errs, fmt.Sprintf("the label '%s' of field '%s' is not a valid A-label", label, field))
+			}
+		}
+	}
+	return errs
+}
+
+// validateType checks the record type is made of letters and digits only
+func validateType(recordType string) []string {
+	if !typeRegexp.MatchString(recordType) {
+		return []string{"the value of field 'type' must only contain letters and digits"}
+	}
+	return nil
+}
+
+// validateValue checks the value is valid for the record type. Values of unknown types are not checked
+func validateValue(recordType, value string) []string {
+	if validator, ok := recordValidators[strings.ToUpper(recordType)]; ok {
+		return validator(value)
+	}
+	return nil
+}
+
+func validateA(value string) []string {
+	if ip := net.ParseIP(value); ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+		return []string{fmt.Sprintf(valueMessage, "an IPv4 address", "A")}
+	}
+	return nil
+}
+
+func validateAAAA(value string) []string {
+	if ip := net.ParseIP(value); ip == nil || !strings.Contains(value, ":") {
+		return []string{fmt.Sprintf(valueMessage, "an IPv6 address", "AAAA")}
+	}
+	return nil
+}
+
+// validateTarget returns a validator of values that are host names, like the ones of CNAME records
+func validateTarget(recordType string) func(value string) []string {
+	return func(value string) []string {
+		if net.ParseIP(value) != nil {
+			return []string{fmt.Sprintf(valueMessage, "a host name, not an IP address,", recordType)}
+		}
+		return validateName("value", value, false)
+	}
+}
+
+// validateHost checks a host name that is part of a record value. "." is accepted, as it means 'no host' on MX and SRV
+func validateHost(field, host string) []string {
+	if host == "." {
+		return nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{fmt.Sprintf("the %s must be a host name, not an IP address", field)}
+	}
+	return validateName("value", host, false)
+}
+
+// validateUint checks the field is an unsigned integer of the given bit size
+func validateUint(field, value string, bitSize int) []string {
+	if _, err := strconv.ParseUint(value, 10, bitSize); err != nil {
+		return []string{fmt.Sprintf("the %s must be an integer between 0 and %d", field, uint64(1)<<uint(bitSize)-1)}
+	}
+	return nil
+}
+
+func validateMX(value string) []string {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return []string{fmt.Sprintf(valueMessage, "in the format '<preference> <host>'", "MX")}
+	}
+	errs := validateUint("MX preference", fields[0], 16)
+	return append(errs, validateHost("MX host", fields[1])...)
+}
+
+func validateSRV(value string) []string {
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return []string{fmt.Sprintf(valueMessage, "in the format '<priority> <weight> <port> <target>'", "SRV")}
+	}
+	errs := validateUint("SRV priority", fields[0], 16)
+	errs = append(errs, validateUint("SRV weight", fields[1], 16)...)
+	errs = append(errs, validateUint("SRV port", fields[2], 16)...)
+	return append(errs, validateHost("SRV target", fields[3])...)
+}
+
+func validateCAA(value string) []string {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) != 3 {
+		return []string{fmt.Sprintf(valueMessage, "in the format '<flags> <tag> <value>'", "CAA")}
+	}
+	errs := validateUint("CAA flags", fields[0], 8)
+	if !caaTagRegexp.MatchString(fields[1]) {
+		errs = append(errs, "the CAA tag must only contain letters and digits")
+	}
+	if strings.HasPrefix(fields[2], `"`) {
+		if _, err := splitCharacterStrings(fields[2]); err != nil {
+			errs = append(errs, fmt.Sprintf("the CAA value %s", err))
+		}
+	}
+	return errs
+}
+
+// validateTXT checks a TXT value. Quoted values may hold several character-strings, e.g. "v=spf1" "-all"; an unquoted
+// value is a single character-string. Each character-string has at most 255 characters
+func validateTXT(value string) []string {
+	if len(value) > maxTXTLength {
+		return []string{fmt.Sprintf(valueMessage, fmt.Sprintf("at most %d characters long", maxTXTLength), "TXT")}
+	}
+	if !strings.HasPrefix(value, `"`) {
+		if len(value) > maxCharacterStringLength {
+			return []string{fmt.Sprintf(valueMessage, fmt.Sprintf("at most %d characters long when not quoted; split longer values in quoted strings", maxCharacterStringLength), "TXT")}
+		}
+		return nil
+	}
+
+	strs, err := splitCharacterStrings(value)
+	if err != nil {
+		return []string{fmt.Sprintf("the TXT value %s", err)}
+	}
+	var errs []string
+	for _, str := range strs {
+		if len(str) > maxCharacterStringLength {
+			errs = append(errs, fmt.Sprintf("each quoted string of a TXT value must have at most %d characters", maxCharacterStringLength))
+		}
+	}
+	return errs
+}
+
+// splitCharacterStrings splits a sequence of quoted character-strings, separated by white spaces, into their contents.
+// Backslashes escape the next character
+func splitCharacterStrings(value string) ([]string, error) {
+	var strs []string
+	for i := 0; i < len(value); {
+		switch {
+		case value[i] == ' ' || value[i] == '\t':
+			i++
+		case value[i] == '"':
+			var str strings.Builder
+			closed := false
+			for i++; i < len(value); i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				} else if value[i] == '"' {
+					closed = true
+					i++
+					break
+				}
+				str.WriteByte(value[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("has an unterminated quoted string")
+			}
+			if i < len(value) && value[i] != ' ' && value[i] != '\t' {
+				return nil, fmt.Errorf("must separate quoted strings with spaces")
+			}
+			strs = append(strs, str.String())
+		default:
+			return nil, fmt.Errorf("must be a sequence of quoted strings")
+		}
+	}
+	return strs, nil
+}